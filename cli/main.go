@@ -46,35 +46,117 @@ func init() {
 		},
 	})
 
-	rootCmd.AddCommand(&cobra.Command{
-		Use:   "bellpush",
-		Short: "Enable/Disable a bell push",
+	bellPushCmd := &cobra.Command{
+		Use:   "bellpush INDEX STATE",
+		Short: "Enable/Disable a bell push; temporarily with --for",
 		Args:  cobra.ExactArgs(2),
-		Run: func(cmd *cobra.Command, args []string) {
-			idx, err := strconv.ParseInt(args[0], 10, 32)
+	}
+	bellPushFor := bellPushCmd.Flags().Duration("for", 0, "Revert the state after the given duration, i.e. 2h")
+	bellPushCmd.Run = func(cmd *cobra.Command, args []string) {
+		idx, err := strconv.ParseInt(args[0], 10, 32)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: Invalid bell push index: %s: %s\n", os.Args[0], args[0], err)
+			os.Exit(3)
+		}
+
+		state, err := parseEnabled(args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: Invalid state: %s: %s\n", os.Args[0], args[1], err)
+			os.Exit(3)
+		}
+
+		if *bellPushFor < 0 {
+			fmt.Fprintf(os.Stderr, "%s: Invalid duration: %s\n", os.Args[0], *bellPushFor)
+			os.Exit(3)
+		}
+
+		doWithController(func(ctx context.Context, ctrl controller.ControllerClient) error {
+			r, err := ctrl.SetState(ctx, &controller.EnabledState{
+				Target:         controller.Target_BELL_PUSH,
+				State:          state,
+				Index:          int32(idx),
+				DurationMillis: bellPushFor.Milliseconds(),
+			})
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "%s: Invalid bell push index: %s: %s\n", os.Args[0], args[0], err)
-				os.Exit(3)
+				return err
+			}
+
+			if !r.Ok {
+				fmt.Fprintf(os.Stderr, "%s: Failed to set bell push state: %s\n", os.Args[0], r.Error)
+			}
+
+			return nil
+		})
+	}
+	rootCmd.AddCommand(bellPushCmd)
+
+	bellCmd := &cobra.Command{
+		Use:   "bell INDEX STATE",
+		Short: "Enable/Disable a bell; temporarily with --for",
+		Args:  cobra.ExactArgs(2),
+	}
+	bellFor := bellCmd.Flags().Duration("for", 0, "Revert the state after the given duration, i.e. 2h")
+	bellCmd.Run = func(cmd *cobra.Command, args []string) {
+		idx, err := strconv.ParseInt(args[0], 10, 32)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: Invalid bell index: %s: %s\n", os.Args[0], args[0], err)
+			os.Exit(3)
+		}
+
+		state, err := parseEnabled(args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: Invalid state: %s: %s\n", os.Args[0], args[1], err)
+			os.Exit(3)
+		}
+
+		if *bellFor < 0 {
+			fmt.Fprintf(os.Stderr, "%s: Invalid duration: %s\n", os.Args[0], *bellFor)
+			os.Exit(3)
+		}
+
+		doWithController(func(ctx context.Context, ctrl controller.ControllerClient) error {
+			r, err := ctrl.SetState(ctx, &controller.EnabledState{
+				Target:         controller.Target_BELL,
+				Index:          int32(idx),
+				State:          state,
+				DurationMillis: bellFor.Milliseconds(),
+			})
+
+			if err != nil {
+				return err
 			}
 
-			state, err := parseEnabled(args[1])
+			if !r.Ok {
+				fmt.Fprintf(os.Stderr, "%s: Failed to set bell state: %s\n", os.Args[0], r.Error)
+			}
+
+			return nil
+		})
+	}
+	rootCmd.AddCommand(bellCmd)
+
+	rootCmd.AddCommand(&cobra.Command{
+		Use:   "route INDEX [BELL...]",
+		Short: "Set the bells rung by a bell push; all bells if no bell is given",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			idx, err := strconv.ParseInt(args[0], 10, 32)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "%s: Invalid state: %s: %s\n", os.Args[0], args[1], err)
+				fmt.Fprintf(os.Stderr, "%s: Invalid bell push index: %s: %s\n", os.Args[0], args[0], err)
 				os.Exit(3)
 			}
 
 			doWithController(func(ctx context.Context, ctrl controller.ControllerClient) error {
-				r, err := ctrl.SetState(ctx, &controller.EnabledState{
-					Target: controller.Target_BELL_PUSH,
-					State:  state,
-					Index:  int32(idx),
+				r, err := ctrl.SetRouting(ctx, &controller.Routing{
+					Index: int32(idx),
+					Bells: args[1:],
 				})
 				if err != nil {
 					return err
 				}
 
 				if !r.Ok {
-					fmt.Fprintf(os.Stderr, "%s: Failed to set bell push state: %s\n", os.Args[0], r.Error)
+					fmt.Fprintf(os.Stderr, "%s: Failed to set bells: %s\n", os.Args[0], r.Error)
 				}
 
 				return nil
@@ -83,35 +165,39 @@ func init() {
 	})
 
 	rootCmd.AddCommand(&cobra.Command{
-		Use:   "bell",
-		Short: "Enable/Disable a bell",
-		Args:  cobra.ExactArgs(2),
+		Use:   "schedule bell|bellpush INDEX [RULE...]",
+		Short: "Set the rules defining when a bell or bell push is quiet, i.e. \"Mon-Fri 20:00-07:00\"; removes the schedule if no rule is given",
+		Args:  cobra.MinimumNArgs(2),
 		Run: func(cmd *cobra.Command, args []string) {
-			idx, err := strconv.ParseInt(args[0], 10, 32)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "%s: Invalid bell push index: %s: %s\n", os.Args[0], args[0], err)
+			var target controller.Target
+			switch args[0] {
+			case "bell":
+				target = controller.Target_BELL
+			case "bellpush":
+				target = controller.Target_BELL_PUSH
+			default:
+				fmt.Fprintf(os.Stderr, "%s: Invalid target: %s\n", os.Args[0], args[0])
 				os.Exit(3)
 			}
 
-			state, err := parseEnabled(args[1])
+			idx, err := strconv.ParseInt(args[1], 10, 32)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "%s: Invalid state: %s: %s\n", os.Args[0], args[1], err)
+				fmt.Fprintf(os.Stderr, "%s: Invalid index: %s: %s\n", os.Args[0], args[1], err)
 				os.Exit(3)
 			}
 
 			doWithController(func(ctx context.Context, ctrl controller.ControllerClient) error {
-				r, err := ctrl.SetState(ctx, &controller.EnabledState{
-					Target: controller.Target_BELL,
+				r, err := ctrl.SetSchedule(ctx, &controller.Schedule{
+					Target: target,
 					Index:  int32(idx),
-					State:  state,
+					Rules:  args[2:],
 				})
-
 				if err != nil {
 					return err
 				}
 
 				if !r.Ok {
-					fmt.Fprintf(os.Stderr, "%s: Failed to set bell state: %s\n", os.Args[0], r.Error)
+					fmt.Fprintf(os.Stderr, "%s: Failed to set schedule: %s\n", os.Args[0], r.Error)
 				}
 
 				return nil
@@ -130,6 +216,126 @@ func init() {
 		},
 	})
 
+	messagesCmd := &cobra.Command{
+		Use:   "messages",
+		Short: "List messages recorded by the mailbox",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			doWithController(func(ctx context.Context, ctrl controller.ControllerClient) error {
+				l, err := ctrl.ListMessages(ctx, &controller.Empty{})
+				if err != nil {
+					return err
+				}
+
+				for _, m := range l.Messages {
+					fmt.Printf("%s\t%s\t%s\n", m.Id, time.Unix(m.RecordedAt, 0).Format(time.RFC3339), time.Duration(m.DurationMillis)*time.Millisecond)
+				}
+
+				return nil
+			})
+		},
+	}
+
+	messagesCmd.AddCommand(&cobra.Command{
+		Use:   "fetch ID FILE",
+		Short: "Save a message as WAV file",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			doWithController(func(ctx context.Context, ctrl controller.ControllerClient) error {
+				m, err := ctrl.FetchMessage(ctx, &controller.MessageID{Id: args[0]})
+				if err != nil {
+					return err
+				}
+
+				return os.WriteFile(args[1], m.Wav, 0644)
+			})
+		},
+	})
+
+	messagesCmd.AddCommand(&cobra.Command{
+		Use:   "delete ID",
+		Short: "Delete a message",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			doWithController(func(ctx context.Context, ctrl controller.ControllerClient) error {
+				r, err := ctrl.DeleteMessage(ctx, &controller.MessageID{Id: args[0]})
+				if err != nil {
+					return err
+				}
+
+				if !r.Ok {
+					fmt.Fprintf(os.Stderr, "%s: Failed to delete message: %s\n", os.Args[0], r.Error)
+				}
+
+				return nil
+			})
+		},
+	})
+
+	rootCmd.AddCommand(messagesCmd)
+
+	rootCmd.AddCommand(&cobra.Command{
+		Use:   "history",
+		Short: "List the most recent calls placed by the phone bells",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			doWithController(func(ctx context.Context, ctrl controller.ControllerClient) error {
+				h, err := ctrl.History(ctx, &controller.Empty{})
+				if err != nil {
+					return err
+				}
+
+				for _, c := range h.Calls {
+					details := c.Callee
+					if c.Error != "" {
+						details = c.Error
+					}
+					fmt.Printf("%s\t%s\t%s\t%s\t%s\n", time.Unix(c.Time, 0).Format(time.RFC3339), c.Bell, c.BellPush, c.Result, details)
+				}
+
+				return nil
+			})
+		},
+	})
+
+	traceCmd := &cobra.Command{
+		Use:       "trace [off|log|text|pcapng]",
+		Short:     "Show or change how SIP messages are traced",
+		Args:      cobra.MaximumNArgs(1),
+		ValidArgs: []string{"off", "log", "text", "pcapng"},
+	}
+	traceCmd.Run = func(cmd *cobra.Command, args []string) {
+		doWithController(func(ctx context.Context, ctrl controller.ControllerClient) error {
+			if len(args) == 1 {
+				r, err := ctrl.SetTrace(ctx, &controller.TraceMode{
+					Mode: args[0],
+				})
+				if err != nil {
+					return err
+				}
+
+				if !r.Ok {
+					fmt.Fprintf(os.Stderr, "%s: Failed to set trace mode: %s\n", os.Args[0], r.Error)
+					return nil
+				}
+			}
+
+			t, err := ctrl.Trace(ctx, &controller.Empty{})
+			if err != nil {
+				return err
+			}
+
+			if t.File != "" {
+				fmt.Printf("%s\t%s\n", t.Mode, t.File)
+			} else {
+				fmt.Printf("%s\n", t.Mode)
+			}
+
+			return nil
+		})
+	}
+	rootCmd.AddCommand(traceCmd)
+
 	rootCmd.AddCommand(&cobra.Command{
 		Use:   "info",
 		Short: "Display info on the current sate",
@@ -143,12 +349,49 @@ func init() {
 
 				fmt.Printf("Bell Pushes\n")
 				for _, p := range i.BellPushes {
-					fmt.Printf("\t%20s: %s\n", p.Label, formatEnabled(p.Enabled))
+					fmt.Printf("\t%20s: %s%s -> %s%s\n", p.Label, formatEnabled(p.Enabled), formatRemaining(p), formatBells(p.Bells), formatSchedule(p))
 				}
 
 				fmt.Printf("\nBells\n")
 				for _, b := range i.Bells {
-					fmt.Printf("\t%20s: %s\n", b.Label, formatEnabled(b.Enabled))
+					fmt.Printf("\t%20s: %s%s%s\n", b.Label, formatEnabled(b.Enabled), formatRemaining(b), formatSchedule(b))
+				}
+
+				if r := i.Registration; r != nil {
+					fmt.Printf("\nSIP Registration\n")
+					fmt.Printf("\t%20s: %s\n", "Registrar", r.Registrar)
+					fmt.Printf("\t%20s: %s\n", "State", r.State)
+					if r.ExpiresAt > 0 {
+						fmt.Printf("\t%20s: %s\n", "Expires", time.Unix(r.ExpiresAt, 0).Format(time.RFC3339))
+					}
+					if r.Error != "" {
+						fmt.Printf("\t%20s: %s\n", "Error", r.Error)
+					}
+				}
+
+				h, err := ctrl.Health(ctx, &controller.Empty{})
+				if err != nil {
+					return err
+				}
+
+				if h.Probing {
+					fmt.Printf("\nSIP Server Health\n")
+					fmt.Printf("\t%20s: %s\n", "Server", h.Server)
+					switch {
+					case h.LastProbe == 0:
+						fmt.Printf("\t%20s: %s\n", "State", "unknown")
+					case h.Healthy:
+						fmt.Printf("\t%20s: %s\n", "State", "reachable")
+					default:
+						fmt.Printf("\t%20s: %s\n", "State", "WARNING: unreachable")
+					}
+					if h.LastSuccess > 0 {
+						fmt.Printf("\t%20s: %s\n", "Last success", time.Unix(h.LastSuccess, 0).Format(time.RFC3339))
+						fmt.Printf("\t%20s: %s\n", "Latency", time.Duration(h.LatencyMillis)*time.Millisecond)
+					}
+					if h.Error != "" {
+						fmt.Printf("\t%20s: %s\n", "Error", h.Error)
+					}
 				}
 
 				return nil
@@ -184,6 +427,37 @@ func formatEnabled(b bool) string {
 	return disabledValue
 }
 
+// formatRemaining formats the time left until a temporary state change is reverted; the empty string if the
+// state is permanent.
+func formatRemaining(i *controller.ItemState) string {
+	if i.Until == 0 {
+		return ""
+	}
+
+	remaining := (time.Duration(i.RemainingMillis) * time.Millisecond).Round(time.Second)
+	return fmt.Sprintf(" for %s (until %s)", remaining, time.Unix(i.Until, 0).Format(time.RFC3339))
+}
+
+func formatBells(bells []string) string {
+	if len(bells) == 0 {
+		return "all bells"
+	}
+
+	return strings.Join(bells, ", ")
+}
+
+func formatSchedule(i *controller.ItemState) string {
+	if len(i.Schedule) == 0 {
+		return ""
+	}
+
+	s := fmt.Sprintf(", quiet %s", strings.Join(i.Schedule, "; "))
+	if i.Quiet {
+		s += " (now quiet)"
+	}
+	return s
+}
+
 type withController func(context.Context, controller.ControllerClient) error
 
 func doWithController(cb withController) {
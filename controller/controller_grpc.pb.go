@@ -21,6 +21,15 @@ type ControllerClient interface {
 	SetState(ctx context.Context, in *EnabledState, opts ...grpc.CallOption) (*Result, error)
 	Ring(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error)
 	Info(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*StateInfo, error)
+	ListMessages(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*MessageList, error)
+	FetchMessage(ctx context.Context, in *MessageID, opts ...grpc.CallOption) (*Message, error)
+	DeleteMessage(ctx context.Context, in *MessageID, opts ...grpc.CallOption) (*Result, error)
+	Health(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*HealthState, error)
+	History(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*CallHistory, error)
+	SetTrace(ctx context.Context, in *TraceMode, opts ...grpc.CallOption) (*Result, error)
+	Trace(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*TraceState, error)
+	SetRouting(ctx context.Context, in *Routing, opts ...grpc.CallOption) (*Result, error)
+	SetSchedule(ctx context.Context, in *Schedule, opts ...grpc.CallOption) (*Result, error)
 }
 
 type controllerClient struct {
@@ -58,6 +67,87 @@ func (c *controllerClient) Info(ctx context.Context, in *Empty, opts ...grpc.Cal
 	return out, nil
 }
 
+func (c *controllerClient) ListMessages(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*MessageList, error) {
+	out := new(MessageList)
+	err := c.cc.Invoke(ctx, "/controller.Controller/ListMessages", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerClient) FetchMessage(ctx context.Context, in *MessageID, opts ...grpc.CallOption) (*Message, error) {
+	out := new(Message)
+	err := c.cc.Invoke(ctx, "/controller.Controller/FetchMessage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerClient) DeleteMessage(ctx context.Context, in *MessageID, opts ...grpc.CallOption) (*Result, error) {
+	out := new(Result)
+	err := c.cc.Invoke(ctx, "/controller.Controller/DeleteMessage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerClient) Health(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*HealthState, error) {
+	out := new(HealthState)
+	err := c.cc.Invoke(ctx, "/controller.Controller/Health", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerClient) History(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*CallHistory, error) {
+	out := new(CallHistory)
+	err := c.cc.Invoke(ctx, "/controller.Controller/History", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerClient) SetTrace(ctx context.Context, in *TraceMode, opts ...grpc.CallOption) (*Result, error) {
+	out := new(Result)
+	err := c.cc.Invoke(ctx, "/controller.Controller/SetTrace", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerClient) Trace(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*TraceState, error) {
+	out := new(TraceState)
+	err := c.cc.Invoke(ctx, "/controller.Controller/Trace", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerClient) SetRouting(ctx context.Context, in *Routing, opts ...grpc.CallOption) (*Result, error) {
+	out := new(Result)
+	err := c.cc.Invoke(ctx, "/controller.Controller/SetRouting", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerClient) SetSchedule(ctx context.Context, in *Schedule, opts ...grpc.CallOption) (*Result, error) {
+	out := new(Result)
+	err := c.cc.Invoke(ctx, "/controller.Controller/SetSchedule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ControllerServer is the server API for Controller service.
 // All implementations must embed UnimplementedControllerServer
 // for forward compatibility
@@ -65,6 +155,15 @@ type ControllerServer interface {
 	SetState(context.Context, *EnabledState) (*Result, error)
 	Ring(context.Context, *Empty) (*Empty, error)
 	Info(context.Context, *Empty) (*StateInfo, error)
+	ListMessages(context.Context, *Empty) (*MessageList, error)
+	FetchMessage(context.Context, *MessageID) (*Message, error)
+	DeleteMessage(context.Context, *MessageID) (*Result, error)
+	Health(context.Context, *Empty) (*HealthState, error)
+	History(context.Context, *Empty) (*CallHistory, error)
+	SetTrace(context.Context, *TraceMode) (*Result, error)
+	Trace(context.Context, *Empty) (*TraceState, error)
+	SetRouting(context.Context, *Routing) (*Result, error)
+	SetSchedule(context.Context, *Schedule) (*Result, error)
 	mustEmbedUnimplementedControllerServer()
 }
 
@@ -81,6 +180,33 @@ func (UnimplementedControllerServer) Ring(context.Context, *Empty) (*Empty, erro
 func (UnimplementedControllerServer) Info(context.Context, *Empty) (*StateInfo, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Info not implemented")
 }
+func (UnimplementedControllerServer) ListMessages(context.Context, *Empty) (*MessageList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMessages not implemented")
+}
+func (UnimplementedControllerServer) FetchMessage(context.Context, *MessageID) (*Message, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FetchMessage not implemented")
+}
+func (UnimplementedControllerServer) DeleteMessage(context.Context, *MessageID) (*Result, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteMessage not implemented")
+}
+func (UnimplementedControllerServer) Health(context.Context, *Empty) (*HealthState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Health not implemented")
+}
+func (UnimplementedControllerServer) History(context.Context, *Empty) (*CallHistory, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method History not implemented")
+}
+func (UnimplementedControllerServer) SetTrace(context.Context, *TraceMode) (*Result, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetTrace not implemented")
+}
+func (UnimplementedControllerServer) Trace(context.Context, *Empty) (*TraceState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Trace not implemented")
+}
+func (UnimplementedControllerServer) SetRouting(context.Context, *Routing) (*Result, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetRouting not implemented")
+}
+func (UnimplementedControllerServer) SetSchedule(context.Context, *Schedule) (*Result, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetSchedule not implemented")
+}
 func (UnimplementedControllerServer) mustEmbedUnimplementedControllerServer() {}
 
 // UnsafeControllerServer may be embedded to opt out of forward compatibility for this service.
@@ -148,6 +274,168 @@ func _Controller_Info_Handler(srv interface{}, ctx context.Context, dec func(int
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Controller_ListMessages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServer).ListMessages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/controller.Controller/ListMessages",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServer).ListMessages(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Controller_FetchMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MessageID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServer).FetchMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/controller.Controller/FetchMessage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServer).FetchMessage(ctx, req.(*MessageID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Controller_DeleteMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MessageID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServer).DeleteMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/controller.Controller/DeleteMessage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServer).DeleteMessage(ctx, req.(*MessageID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Controller_Health_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServer).Health(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/controller.Controller/Health",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServer).Health(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Controller_History_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServer).History(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/controller.Controller/History",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServer).History(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Controller_SetTrace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TraceMode)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServer).SetTrace(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/controller.Controller/SetTrace",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServer).SetTrace(ctx, req.(*TraceMode))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Controller_Trace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServer).Trace(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/controller.Controller/Trace",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServer).Trace(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Controller_SetRouting_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Routing)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServer).SetRouting(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/controller.Controller/SetRouting",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServer).SetRouting(ctx, req.(*Routing))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Controller_SetSchedule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Schedule)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServer).SetSchedule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/controller.Controller/SetSchedule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServer).SetSchedule(ctx, req.(*Schedule))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Controller_ServiceDesc is the grpc.ServiceDesc for Controller service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -167,6 +455,42 @@ var Controller_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Info",
 			Handler:    _Controller_Info_Handler,
 		},
+		{
+			MethodName: "ListMessages",
+			Handler:    _Controller_ListMessages_Handler,
+		},
+		{
+			MethodName: "FetchMessage",
+			Handler:    _Controller_FetchMessage_Handler,
+		},
+		{
+			MethodName: "DeleteMessage",
+			Handler:    _Controller_DeleteMessage_Handler,
+		},
+		{
+			MethodName: "Health",
+			Handler:    _Controller_Health_Handler,
+		},
+		{
+			MethodName: "History",
+			Handler:    _Controller_History_Handler,
+		},
+		{
+			MethodName: "SetTrace",
+			Handler:    _Controller_SetTrace_Handler,
+		},
+		{
+			MethodName: "Trace",
+			Handler:    _Controller_Trace_Handler,
+		},
+		{
+			MethodName: "SetRouting",
+			Handler:    _Controller_SetRouting_Handler,
+		},
+		{
+			MethodName: "SetSchedule",
+			Handler:    _Controller_SetSchedule_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "controller/controller.proto",
@@ -8,6 +8,7 @@ require (
 	github.com/halimath/raspidoor/controller v0.0.0
 	github.com/halimath/raspidoor/systemd v0.0.0
 	github.com/warthog618/gpiod v0.8.0
+	golang.org/x/net v0.0.0-20210813160813-60bc85c4be6d
 	google.golang.org/grpc v1.43.0
 )
 
@@ -21,7 +22,6 @@ require (
 	github.com/golang/protobuf v1.5.2 // indirect
 	github.com/halimath/assertthat-go v0.0.0-20220327081729-20de7e695323 // indirect
 	github.com/kr/pretty v0.3.0 // indirect
-	golang.org/x/net v0.0.0-20210813160813-60bc85c4be6d // indirect
 	golang.org/x/sys v0.0.0-20220317061510-51cd9980dadf // indirect
 	golang.org/x/text v0.3.7 // indirect
 	google.golang.org/genproto v0.0.0-20211208223120-3a66f561d7aa // indirect
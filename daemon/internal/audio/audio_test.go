@@ -0,0 +1,184 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestG711_roundTrip(t *testing.T) {
+	codecs := map[string]struct {
+		encode func([]int16) []byte
+		decode func([]byte) []int16
+	}{
+		"ulaw": {EncodeULaw, DecodeULaw},
+		"alaw": {EncodeALaw, DecodeALaw},
+	}
+
+	samples := []int16{0, 1, -1, 100, -100, 1000, -1000, 10000, -10000, 32767, -32768}
+
+	for name, c := range codecs {
+		t.Run(name, func(t *testing.T) {
+			decoded := c.decode(c.encode(samples))
+
+			for i, s := range samples {
+				// G.711 quantizes logarithmically; the error is bounded by ~1/16 of the magnitude.
+				diff := int(decoded[i]) - int(s)
+				if diff < 0 {
+					diff = -diff
+				}
+				limit := abs(int(s))/16 + 16
+				if diff > limit {
+					t.Errorf("sample %d: expected ~%d but got %d", i, s, decoded[i])
+				}
+			}
+		})
+	}
+}
+
+func TestG711_knownValues(t *testing.T) {
+	// Silence encodes to 0xff (µ-law) and 0xd5 (A-law) as defined by G.711.
+	if b := EncodeULaw([]int16{0})[0]; b != 0xff {
+		t.Errorf("expected µ-law silence to be 0xff but got %#x", b)
+	}
+	if b := EncodeALaw([]int16{0})[0]; b != 0xd5 {
+		t.Errorf("expected A-law silence to be 0xd5 but got %#x", b)
+	}
+}
+
+func TestWAV_roundTrip(t *testing.T) {
+	samples := []int16{0, 1, -1, 32767, -32768}
+
+	var b bytes.Buffer
+	if err := WriteWAV(&b, samples); err != nil {
+		t.Fatal(err)
+	}
+
+	if b.Len() != wavHeaderBytes+2*len(samples) {
+		t.Errorf("unexpected size %d", b.Len())
+	}
+
+	read, err := ReadWAV(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(read) != len(samples) {
+		t.Fatalf("expected %d samples but got %d", len(samples), len(read))
+	}
+	for i := range samples {
+		if read[i] != samples[i] {
+			t.Errorf("sample %d: expected %d but got %d", i, samples[i], read[i])
+		}
+	}
+}
+
+func TestReadWAV_unsupportedFormat(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteWAV(&b, []int16{0}); err != nil {
+		t.Fatal(err)
+	}
+
+	data := b.Bytes()
+	// Patch the sample rate to 44.1 kHz.
+	data[24], data[25], data[26], data[27] = 0x44, 0xac, 0, 0
+
+	if _, err := ReadWAV(bytes.NewReader(data)); !errors.Is(err, ErrUnsupportedWAV) {
+		t.Errorf("expected unsupported format but got %v", err)
+	}
+
+	if _, err := ReadWAV(bytes.NewReader([]byte("not a wav file"))); !errors.Is(err, ErrInvalidWAV) {
+		t.Errorf("expected invalid WAV but got %v", err)
+	}
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+func TestFrames(t *testing.T) {
+	frames := Frames(make([]int16, 400), 20*time.Millisecond)
+
+	if len(frames) != 3 {
+		t.Fatalf("expected 3 frames but got %d", len(frames))
+	}
+
+	for i, f := range frames {
+		if len(f) != 160 {
+			t.Errorf("expected frame %d to contain 160 samples but got %d", i, len(f))
+		}
+	}
+}
+
+func TestFileDevice(t *testing.T) {
+	d := NewFileDevice([]int16{1, 2, 3})
+
+	frame := make([]int16, 2)
+	start := time.Now()
+	for _, want := range [][]int16{{1, 2}, {3, 0}, {0, 0}} {
+		if err := d.Capture(context.Background(), frame); err != nil {
+			t.Fatal(err)
+		}
+		if frame[0] != want[0] || frame[1] != want[1] {
+			t.Errorf("expected %v but got %v", want, frame)
+		}
+	}
+
+	// Three frames of 2 samples last 750µs.
+	if elapsed := time.Since(start); elapsed < 750*time.Microsecond {
+		t.Errorf("expected capturing to be paced but took %s", elapsed)
+	}
+
+	d.Playback([]int16{4, 5})
+	d.Playback([]int16{6})
+	if got := d.Recorded(); len(got) != 3 || got[0] != 4 || got[2] != 6 {
+		t.Errorf("unexpected recording: %v", got)
+	}
+}
+
+func TestLoopback(t *testing.T) {
+	l := NewLoopback()
+
+	l.Playback([]int16{1, 2, 3})
+
+	frame := make([]int16, 2)
+	for _, want := range [][]int16{{1, 2}, {3, 0}} {
+		if err := l.Capture(context.Background(), frame); err != nil {
+			t.Fatal(err)
+		}
+		if frame[0] != want[0] || frame[1] != want[1] {
+			t.Errorf("expected %v but got %v", want, frame)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Capture(ctx, make([]int16, SampleRate)); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled but got %v", err)
+	}
+}
+
+func TestLoopback_concurrentCapture(t *testing.T) {
+	l := NewLoopback()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			frame := make([]int16, 8)
+			for j := 0; j < 5; j++ {
+				if err := l.Capture(context.Background(), frame); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,163 @@
+package audio
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// loopbackBufferSize bounds the number of samples buffered by a Loopback device.
+const loopbackBufferSize = SampleRate
+
+// Device is a sound device capturing and playing back mono audio at SampleRate, i.e. a microphone and a
+// speaker attached via ALSA.
+type Device interface {
+	// Capture fills frame with the next samples captured. It blocks until the samples are available - i.e.
+	// for the duration of the frame - and returns ctx's error if ctx is done before.
+	Capture(ctx context.Context, frame []int16) error
+
+	// Playback queues frame for playback.
+	Playback(frame []int16) error
+
+	Close() error
+}
+
+// FileDevice is a Device capturing samples from memory (i.e. read from a WAV file) and recording all samples
+// played back. Once all samples have been captured, silence is captured. Capturing is paced in real time.
+type FileDevice struct {
+	// captureLock serializes capturing including pacing.
+	captureLock sync.Mutex
+	pacer       pacer
+
+	lock     sync.Mutex
+	samples  []int16
+	recorded []int16
+}
+
+var _ Device = &FileDevice{}
+
+// NewFileDevice creates a FileDevice capturing samples.
+func NewFileDevice(samples []int16) *FileDevice {
+	return &FileDevice{samples: samples}
+}
+
+func (d *FileDevice) Capture(ctx context.Context, frame []int16) error {
+	d.captureLock.Lock()
+	defer d.captureLock.Unlock()
+
+	if err := d.pacer.wait(ctx, len(frame)); err != nil {
+		return err
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	n := copy(frame, d.samples)
+	d.samples = d.samples[n:]
+	silence(frame[n:])
+
+	return nil
+}
+
+func (d *FileDevice) Playback(frame []int16) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.recorded = append(d.recorded, frame...)
+	return nil
+}
+
+// Recorded returns all samples played back so far.
+func (d *FileDevice) Recorded() []int16 {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return append([]int16(nil), d.recorded...)
+}
+
+func (d *FileDevice) Close() error { return nil }
+
+// Loopback is a Device capturing the samples played back, i.e. to echo a caller's voice. Silence is captured
+// if no samples have been played back. Capturing is paced in real time.
+type Loopback struct {
+	// captureLock serializes capturing including pacing.
+	captureLock sync.Mutex
+	pacer       pacer
+
+	lock     sync.Mutex
+	buffered []int16
+}
+
+var _ Device = &Loopback{}
+
+// NewLoopback creates a Loopback device.
+func NewLoopback() *Loopback {
+	return &Loopback{}
+}
+
+func (l *Loopback) Capture(ctx context.Context, frame []int16) error {
+	l.captureLock.Lock()
+	defer l.captureLock.Unlock()
+
+	if err := l.pacer.wait(ctx, len(frame)); err != nil {
+		return err
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	n := copy(frame, l.buffered)
+	l.buffered = l.buffered[n:]
+	silence(frame[n:])
+
+	return nil
+}
+
+func (l *Loopback) Playback(frame []int16) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.buffered = append(l.buffered, frame...)
+	if len(l.buffered) > loopbackBufferSize {
+		// Drop the oldest samples if the capturing side falls behind.
+		l.buffered = l.buffered[len(l.buffered)-loopbackBufferSize:]
+	}
+
+	return nil
+}
+
+func (l *Loopback) Close() error { return nil }
+
+// pacer paces capturing of frames in real time. It must not be used concurrently.
+type pacer struct {
+	next time.Time
+}
+
+// wait blocks until the frame of n samples following the previous one is complete.
+func (p *pacer) wait(ctx context.Context, n int) error {
+	d := time.Duration(n) * time.Second / SampleRate
+
+	now := time.Now()
+	if now.Sub(p.next) > d {
+		// Start over after a pause instead of catching up.
+		p.next = now
+	}
+	p.next = p.next.Add(d)
+
+	t := time.NewTimer(time.Until(p.next))
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// silence fills samples with silence.
+func silence(samples []int16) {
+	for i := range samples {
+		samples[i] = 0
+	}
+}
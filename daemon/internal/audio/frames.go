@@ -0,0 +1,21 @@
+package audio
+
+import "time"
+
+// Frames splits samples into frames of d each. The last frame is padded with silence.
+func Frames(samples []int16, d time.Duration) [][]int16 {
+	size := int(int64(SampleRate) * int64(d) / int64(time.Second))
+	if size <= 0 {
+		return nil
+	}
+
+	frames := make([][]int16, 0, (len(samples)+size-1)/size)
+	for len(samples) > 0 {
+		frame := make([]int16, size)
+		n := copy(frame, samples)
+		samples = samples[n:]
+		frames = append(frames, frame)
+	}
+
+	return frames
+}
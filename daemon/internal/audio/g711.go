@@ -0,0 +1,151 @@
+package audio
+
+import "strings"
+
+const (
+	ulawBias = 0x84
+	ulawClip = 32635
+)
+
+// EncodeULaw encodes 16 bit linear PCM samples using G.711 µ-law (PCMU).
+func EncodeULaw(samples []int16) []byte {
+	b := make([]byte, len(samples))
+	for i, s := range samples {
+		b[i] = linearToULaw(s)
+	}
+	return b
+}
+
+// DecodeULaw decodes G.711 µ-law (PCMU) encoded data to 16 bit linear PCM samples.
+func DecodeULaw(data []byte) []int16 {
+	s := make([]int16, len(data))
+	for i, b := range data {
+		s[i] = ulawToLinear(b)
+	}
+	return s
+}
+
+// EncodeALaw encodes 16 bit linear PCM samples using G.711 A-law (PCMA).
+func EncodeALaw(samples []int16) []byte {
+	b := make([]byte, len(samples))
+	for i, s := range samples {
+		b[i] = linearToALaw(s)
+	}
+	return b
+}
+
+// DecodeALaw decodes G.711 A-law (PCMA) encoded data to 16 bit linear PCM samples.
+func DecodeALaw(data []byte) []int16 {
+	s := make([]int16, len(data))
+	for i, b := range data {
+		s[i] = alawToLinear(b)
+	}
+	return s
+}
+
+func linearToULaw(sample int16) byte {
+	s := int(sample)
+
+	sign := 0
+	if s < 0 {
+		sign = 0x80
+		s = -s
+	}
+	if s > ulawClip {
+		s = ulawClip
+	}
+	s += ulawBias
+
+	exponent := 7
+	for mask := 0x4000; s&mask == 0 && exponent > 0; mask >>= 1 {
+		exponent--
+	}
+	mantissa := (s >> (exponent + 3)) & 0x0f
+
+	return ^byte(sign | exponent<<4 | mantissa)
+}
+
+func ulawToLinear(b byte) int16 {
+	b = ^b
+
+	exponent := int(b>>4) & 0x07
+	mantissa := int(b) & 0x0f
+	s := ((mantissa << 3) + ulawBias) << exponent
+	s -= ulawBias
+
+	if b&0x80 != 0 {
+		return int16(-s)
+	}
+	return int16(s)
+}
+
+func linearToALaw(sample int16) byte {
+	s := int(sample)
+
+	sign := 0x80
+	if s < 0 {
+		sign = 0
+		s = -s - 1
+	}
+	if s > 32767 {
+		s = 32767
+	}
+
+	var b int
+	if s < 256 {
+		b = s >> 4
+	} else {
+		exponent := 7
+		for mask := 0x4000; s&mask == 0 && exponent > 1; mask >>= 1 {
+			exponent--
+		}
+		b = exponent<<4 | (s>>(exponent+3))&0x0f
+	}
+
+	return byte(sign|b) ^ 0x55
+}
+
+func alawToLinear(b byte) int16 {
+	b ^= 0x55
+
+	exponent := int(b>>4) & 0x07
+	mantissa := int(b) & 0x0f
+
+	var s int
+	if exponent == 0 {
+		s = mantissa<<4 + 8
+	} else {
+		s = (mantissa<<4 + 0x108) << (exponent - 1)
+	}
+
+	if b&0x80 == 0 {
+		return int16(-s)
+	}
+	return int16(s)
+}
+
+// Encoder returns the function encoding samples using the RTP payload format name (PCMU or PCMA). It
+// returns false if the format is not supported.
+func Encoder(name string) (func([]int16) []byte, bool) {
+	switch strings.ToUpper(name) {
+	case "PCMU":
+		return EncodeULaw, true
+	case "PCMA":
+		return EncodeALaw, true
+	default:
+		return nil, false
+	}
+}
+
+// Decoder returns the function decoding data encoded using the RTP payload format name (PCMU or PCMA). It
+// returns false if the format is not supported.
+func Decoder(name string) (func([]byte) []int16, bool) {
+	switch strings.ToUpper(name) {
+	case "PCMU":
+		return DecodeULaw, true
+	case "PCMA":
+		return DecodeALaw, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,139 @@
+// Package audio implements reading and writing of audio samples as well as the G.711 codecs used for
+// telephony. All samples are 16 bit linear PCM mono sampled at SampleRate.
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// SampleRate is the sample rate of all audio handled by raspidoor which is the sample rate of G.711.
+const SampleRate = 8000
+
+const (
+	wavFormatPCM   = 1
+	bitsPerSample  = 16
+	wavHeaderBytes = 44
+)
+
+var (
+	ErrInvalidWAV     = errors.New("invalid WAV file")
+	ErrUnsupportedWAV = errors.New("unsupported WAV format")
+)
+
+// ReadWAVFile reads the samples from the WAV file name. See ReadWAV for the supported formats.
+func ReadWAVFile(name string) ([]int16, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadWAV(f)
+}
+
+// ReadWAV reads the samples from WAV data read from r. Only uncompressed 16 bit PCM mono data sampled at
+// SampleRate is supported; use a tool like sox to convert other files.
+func ReadWAV(r io.Reader) ([]int16, error) {
+	var riff struct {
+		ID     [4]byte
+		Size   uint32
+		Format [4]byte
+	}
+	if err := binary.Read(r, binary.LittleEndian, &riff); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidWAV, err)
+	}
+	if string(riff.ID[:]) != "RIFF" || string(riff.Format[:]) != "WAVE" {
+		return nil, fmt.Errorf("%w: missing RIFF/WAVE header", ErrInvalidWAV)
+	}
+
+	var formatSeen bool
+	for {
+		var chunk struct {
+			ID   [4]byte
+			Size uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &chunk); err != nil {
+			return nil, fmt.Errorf("%w: missing data chunk", ErrInvalidWAV)
+		}
+
+		switch string(chunk.ID[:]) {
+		case "fmt ":
+			if err := readWAVFormat(io.LimitReader(r, int64(chunk.Size))); err != nil {
+				return nil, err
+			}
+			formatSeen = true
+		case "data":
+			if !formatSeen {
+				return nil, fmt.Errorf("%w: data chunk before fmt chunk", ErrInvalidWAV)
+			}
+
+			samples := make([]int16, chunk.Size/2)
+			if err := binary.Read(r, binary.LittleEndian, samples); err != nil {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidWAV, err)
+			}
+			return samples, nil
+		default:
+			// Skip unknown chunks (i.e. LIST); chunks are padded to an even size.
+			if _, err := io.CopyN(io.Discard, r, int64(chunk.Size+chunk.Size%2)); err != nil {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidWAV, err)
+			}
+		}
+	}
+}
+
+func readWAVFormat(r io.Reader) error {
+	var format struct {
+		AudioFormat   uint16
+		Channels      uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+	}
+	if err := binary.Read(r, binary.LittleEndian, &format); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidWAV, err)
+	}
+
+	if format.AudioFormat != wavFormatPCM || format.Channels != 1 || format.SampleRate != SampleRate || format.BitsPerSample != bitsPerSample {
+		return fmt.Errorf("%w: expected 16 bit PCM mono at %d Hz but got format %d with %d channels, %d bits at %d Hz",
+			ErrUnsupportedWAV, SampleRate, format.AudioFormat, format.Channels, format.BitsPerSample, format.SampleRate)
+	}
+
+	// Skip any extension of the format chunk.
+	_, err := io.Copy(io.Discard, r)
+	return err
+}
+
+// WriteWAV writes samples as WAV data to w.
+func WriteWAV(w io.Writer, samples []int16) error {
+	dataSize := uint32(len(samples) * 2)
+
+	var b bytes.Buffer
+	b.Grow(wavHeaderBytes + int(dataSize))
+
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(wavHeaderBytes-8)+dataSize)
+	b.WriteString("WAVEfmt ")
+	for _, v := range []interface{}{
+		uint32(16),
+		uint16(wavFormatPCM),
+		uint16(1),
+		uint32(SampleRate),
+		uint32(SampleRate * bitsPerSample / 8),
+		uint16(bitsPerSample / 8),
+		uint16(bitsPerSample),
+	} {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, dataSize)
+	binary.Write(&b, binary.LittleEndian, samples)
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
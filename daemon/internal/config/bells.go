@@ -0,0 +1,249 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/halimath/raspidoor/daemon/internal/gatekeeper"
+	"github.com/halimath/raspidoor/daemon/internal/gpio"
+	"github.com/halimath/raspidoor/daemon/internal/sip"
+)
+
+const (
+	// Labels of the bells created from the externalBell and sip settings if no bells are configured.
+	legacyExternalBellLabel = "External Bell"
+	legacyPhoneBellLabel    = "SIP Phone"
+)
+
+type (
+	// Bell defines a bell rung when a bell push is pressed. Each bell has a type which defines the settings
+	// used; settings of other types are ignored.
+	Bell struct {
+		// A human readable label for the bell; must be unique
+		Label string
+
+		// The bell's type; either gpio (a relay switching an external chime) or sip (a phone call)
+		Type string
+
+		// gpio: GPIO number (not the physical pin) to connect the relay to
+		GPIO int
+
+		// gpio: Duration to ring the bell (keep the relay closed)
+		RingDuration time.Duration
+
+		// sip: The callee's SIP address; ignored if Callees is set
+		Callee string
+
+		// sip: The callees to ring (hunt group)
+		Callees []SIPCallee
+
+		// sip: How to ring the callees; either parallel (default) or sequential
+		Strategy string
+
+		// sip: Max duration to ring the callees; defaults to SIP.MaxRingingTime
+		MaxRingingTime time.Duration
+
+		// sip: How to handle busy or unavailable callees
+		Retry SIPRetry
+
+		// Rules defining when the bell is quiet, i.e. "Mon-Fri 20:00-07:00"; optional
+		Schedule []string
+	}
+
+	// bellEnv contains the resources shared by the bells.
+	bellEnv struct {
+		disableGPIO bool
+
+		// maxRingingTime is the default max ringing time of sip bells.
+		maxRingingTime time.Duration
+
+		caller       sip.URI
+		secure       bool
+		transport    sip.Transport
+		authHandlers []sip.AuthenticationHandler
+
+		opener *gatekeeper.DoorOpener
+	}
+
+	// ringerFactory creates a bell of a particular type from its settings.
+	ringerFactory func(b Bell, env *bellEnv) (gatekeeper.BellOptions, error)
+)
+
+// ringerFactories contains the factories of all supported bell types keyed by type.
+var ringerFactories = map[string]ringerFactory{
+	"gpio": newGPIOBell,
+	"sip":  newSIPBell,
+}
+
+// bells returns the configured bells. If no bells are configured, an external bell and a SIP phone are
+// created from the externalBell and sip settings.
+func (c Config) bells() []Bell {
+	if len(c.Bells) > 0 {
+		return c.Bells
+	}
+
+	return []Bell{
+		{
+			Label:        legacyExternalBellLabel,
+			Type:         "gpio",
+			GPIO:         c.ExternalBell.GPIO,
+			RingDuration: c.ExternalBell.RingDuration,
+			Schedule:     c.ExternalBell.Schedule,
+		},
+		{
+			Label:          legacyPhoneBellLabel,
+			Type:           "sip",
+			Callee:         c.SIP.Callee,
+			Callees:        c.SIP.Callees,
+			Strategy:       c.SIP.Strategy,
+			MaxRingingTime: c.SIP.MaxRingingTime,
+			Retry:          c.SIP.Retry,
+		},
+	}
+}
+
+// newBells creates the bells using the factory registered for each bell's type.
+func newBells(bells []Bell, env *bellEnv) ([]gatekeeper.BellOptions, error) {
+	labels := make(map[string]bool, len(bells))
+	result := make([]gatekeeper.BellOptions, len(bells))
+
+	for i, b := range bells {
+		if b.Label == "" {
+			return nil, fmt.Errorf("missing label for bell %d", i)
+		}
+		if labels[b.Label] {
+			return nil, fmt.Errorf("duplicate bell label: %s", b.Label)
+		}
+		labels[b.Label] = true
+
+		factory, ok := ringerFactories[strings.ToLower(b.Type)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported type for bell %s: %s", b.Label, b.Type)
+		}
+
+		opts, err := factory(b, env)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bell %s: %w", b.Label, err)
+		}
+
+		opts.Schedule, err = gatekeeper.ParseSchedule(b.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule for bell %s: %w", b.Label, err)
+		}
+		result[i] = opts
+	}
+
+	return result, nil
+}
+
+func newGPIOBell(b Bell, env *bellEnv) (gatekeeper.BellOptions, error) {
+	if env.disableGPIO {
+		return gatekeeper.NewExternalBell(b.Label, gpio.NewNOOPDigitalOutput(), b.RingDuration), nil
+	}
+
+	out, err := gpio.NewDigitalOutput(gpio.DefaultChip, b.GPIO)
+	if err != nil {
+		return gatekeeper.BellOptions{}, err
+	}
+
+	return gatekeeper.NewExternalBell(b.Label, out, b.RingDuration), nil
+}
+
+func newSIPBell(b Bell, env *bellEnv) (gatekeeper.BellOptions, error) {
+	if b.MaxRingingTime == 0 {
+		b.MaxRingingTime = env.maxRingingTime
+	}
+
+	callees, err := b.callees()
+	if err != nil {
+		return gatekeeper.BellOptions{}, err
+	}
+
+	strategy, err := b.strategy()
+	if err != nil {
+		return gatekeeper.BellOptions{}, err
+	}
+
+	retry, err := b.retryPolicy()
+	if err != nil {
+		return gatekeeper.BellOptions{}, err
+	}
+
+	if !env.secure {
+		for _, callee := range callees {
+			if callee.URI.Secure() {
+				return gatekeeper.BellOptions{}, fmt.Errorf("sips URIs require SIP transport tls")
+			}
+		}
+	}
+
+	return gatekeeper.NewPhoneBell(b.Label, env.caller, callees, strategy, retry, env.transport, env.authHandlers, env.opener), nil
+}
+
+// callees returns the callees to ring; either Callees or the single Callee.
+func (b Bell) callees() ([]gatekeeper.Callee, error) {
+	callees := b.Callees
+	if len(callees) == 0 {
+		callees = []SIPCallee{{Address: b.Callee}}
+	}
+
+	result := make([]gatekeeper.Callee, len(callees))
+	for i, c := range callees {
+		uri, err := sip.ParseURI(c.Address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid callee %s: %w", c.Address, err)
+		}
+
+		maxRingingTime := c.MaxRingingTime
+		if maxRingingTime == 0 {
+			maxRingingTime = b.MaxRingingTime
+		}
+
+		result[i] = gatekeeper.Callee{URI: uri, MaxRingingTime: maxRingingTime}
+	}
+
+	return result, nil
+}
+
+func (b Bell) strategy() (gatekeeper.Strategy, error) {
+	switch strings.ToLower(b.Strategy) {
+	case "", "parallel":
+		return gatekeeper.Parallel, nil
+	case "sequential":
+		return gatekeeper.Sequential, nil
+	default:
+		return 0, fmt.Errorf("unsupported SIP strategy: %s", b.Strategy)
+	}
+}
+
+func (b Bell) retryPolicy() (gatekeeper.RetryPolicy, error) {
+	if b.Retry.BusyRetries < 0 || b.Retry.UnavailableRetries < 0 {
+		return gatekeeper.RetryPolicy{}, fmt.Errorf("invalid SIP retries: must not be negative")
+	}
+
+	p := gatekeeper.RetryPolicy{
+		BusyRetries:        b.Retry.BusyRetries,
+		BusyDelay:          b.Retry.BusyDelay,
+		UnavailableRetries: b.Retry.UnavailableRetries,
+		MaxRetryAfter:      b.Retry.MaxRetryAfter,
+	}
+
+	if p.BusyDelay == 0 {
+		p.BusyDelay = defaultBusyDelay
+	}
+
+	if p.MaxRetryAfter == 0 {
+		p.MaxRetryAfter = defaultMaxRetryAfter
+	}
+
+	if b.Retry.BusyFallback != "" {
+		uri, err := sip.ParseURI(b.Retry.BusyFallback)
+		if err != nil {
+			return gatekeeper.RetryPolicy{}, fmt.Errorf("invalid busy fallback %s: %w", b.Retry.BusyFallback, err)
+		}
+		p.BusyFallback = &gatekeeper.Callee{URI: uri, MaxRingingTime: b.MaxRingingTime}
+	}
+
+	return p, nil
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func bellsConfig(bells ...Bell) Config {
+	return Config{
+		SIP: SIP{
+			Caller:         "sip:doorbell@registrar.example.com",
+			Callee:         "sip:callee@registrar.example.com",
+			MaxRingingTime: 15 * time.Second,
+			Server: SIPServer{
+				Host:      "registrar.example.com",
+				Transport: "udp",
+			},
+		},
+		Bells:       bells,
+		DisableGPIO: true,
+	}
+}
+
+func bellLabels(t *testing.T, c Config) []string {
+	opts, err := c.GatekeeperOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var labels []string
+	for _, b := range opts.Bells {
+		labels = append(labels, b.Label)
+	}
+	return labels
+}
+
+func TestGatekeeperOptions_bells(t *testing.T) {
+	c := bellsConfig(
+		Bell{Label: "Chime", Type: "gpio", GPIO: 25, RingDuration: time.Second},
+		Bell{Label: "Garden chime", Type: "GPIO", GPIO: 26, RingDuration: time.Second},
+		Bell{Label: "Alice", Type: "sip", Callee: "sip:alice@registrar.example.com"},
+		Bell{Label: "Bob", Type: "sip", Callees: []SIPCallee{{Address: "sip:bob@registrar.example.com"}}, Strategy: "sequential"},
+	)
+
+	if diff := deep.Equal(bellLabels(t, c), []string{"Chime", "Garden chime", "Alice", "Bob"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestGatekeeperOptions_legacyBells(t *testing.T) {
+	if diff := deep.Equal(bellLabels(t, bellsConfig()), []string{"External Bell", "SIP Phone"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestGatekeeperOptions_invalidBells(t *testing.T) {
+	tests := map[string]struct {
+		bells []Bell
+		err   string
+	}{
+		"missing label": {
+			bells: []Bell{{Type: "gpio"}},
+			err:   "missing label",
+		},
+		"duplicate label": {
+			bells: []Bell{{Label: "Chime", Type: "gpio"}, {Label: "Chime", Type: "gpio"}},
+			err:   "duplicate bell label",
+		},
+		"unsupported type": {
+			bells: []Bell{{Label: "Chime", Type: "zigbee"}},
+			err:   "unsupported type",
+		},
+		"invalid callee": {
+			bells: []Bell{{Label: "Phone", Type: "sip", Callee: "tel:+49301234"}},
+			err:   "invalid callee",
+		},
+		"invalid schedule": {
+			bells: []Bell{{Label: "Chime", Type: "gpio", Schedule: []string{"Mon-Fri 20:00"}}},
+			err:   "invalid schedule for bell Chime",
+		},
+		"sips callee": {
+			bells: []Bell{{Label: "Phone", Type: "sip", Callee: "sips:alice@registrar.example.com"}},
+			err:   "require SIP transport tls",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := bellsConfig(test.bells...).GatekeeperOptions()
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expected error containing %q but got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestGatekeeperOptions_timezone(t *testing.T) {
+	c := bellsConfig()
+	c.Timezone = "Mars/Olympus_Mons"
+
+	if _, err := c.GatekeeperOptions(); err == nil || !strings.Contains(err.Error(), "invalid timezone") {
+		t.Errorf("expected invalid timezone but got %v", err)
+	}
+}
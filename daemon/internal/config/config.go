@@ -1,17 +1,49 @@
 package config
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/halimath/raspidoor/daemon/internal/audio"
 	"github.com/halimath/raspidoor/daemon/internal/gatekeeper"
 	"github.com/halimath/raspidoor/daemon/internal/gpio"
+	"github.com/halimath/raspidoor/daemon/internal/mailbox"
 	"github.com/halimath/raspidoor/daemon/internal/sip"
 	"github.com/halimath/raspidoor/systemd/logging"
 
 	"github.com/halimath/appconf"
 )
 
+const (
+	// defaultMaxMessageDuration is the default max duration of a message recorded by the mailbox.
+	defaultMaxMessageDuration = 30 * time.Second
+
+	// maxMessageDuration bounds the duration of messages so that they can be fetched via the controller.
+	maxMessageDuration = 2 * time.Minute
+
+	// defaultBusyDelay is the default time to wait before ringing a busy callee again.
+	defaultBusyDelay = 5 * time.Second
+
+	// defaultMaxRetryAfter is the default max Retry-After to wait for before ringing an unavailable callee
+	// again.
+	defaultMaxRetryAfter = 30 * time.Second
+
+	// defaultTraceMaxSize is the default size after which a SIP trace file is rotated.
+	defaultTraceMaxSize = 10 << 20
+
+	// defaultTraceMaxFiles is the default number of rotated SIP trace files to keep.
+	defaultTraceMaxFiles = 5
+
+	// defaultStateFile is the default file to persist the enabled state of bells and bell pushes in.
+	defaultStateFile = "/var/lib/raspidoor/state.json"
+)
+
 type (
 	SIPServer struct {
 		// The SIP host (your phone router)
@@ -26,22 +58,146 @@ type (
 		// The SIP password to authenticate with
 		Password string
 
-		// Whether to dump protocol logs
+		// The transport protocol to use; either udp, tcp (default) or tls
+		Transport string
+
+		// The SIP URI of a proxy to send all requests to, i.e. sip:proxy.example.com; optional
+		OutboundProxy string
+
+		// TLS settings used with transport tls
+		TLS SIPTLS
+
+		// Registration settings
+		Registration SIPRegistration
+
+		// Health probe settings
+		Probe SIPProbe
+
+		// Tracing of SIP messages
+		Trace SIPTrace
+
+		// Whether to dump protocol logs; same as trace mode log. Deprecated: use Trace instead
 		Debug bool
 	}
 
+	// SIPTrace defines how to trace SIP messages. The mode can be changed at runtime via the controller.
+	SIPTrace struct {
+		// The trace mode; one of off (default), log (debug log), text or pcapng (rotating file)
+		Mode string
+
+		// Path of the trace file used with modes text and pcapng
+		File string
+
+		// The size in bytes after which the trace file is rotated; defaults to 10 MiB
+		MaxSize int64
+
+		// The number of rotated trace files to keep; defaults to 5
+		MaxFiles int
+	}
+
+	// SIPTLS defines the settings to establish TLS connections to the SIP server.
+	SIPTLS struct {
+		// Path of a PEM encoded CA bundle to verify the server's certificate; uses the system roots if empty
+		CAFile string
+
+		// Paths of a PEM encoded client certificate and private key to present to the server (optional)
+		CertFile string
+		KeyFile  string
+
+		// Whether to skip verification of the server's certificate; never use this in production
+		InsecureSkipVerify bool
+	}
+
+	// SIPRegistration defines whether and how to register at the SIP server.
+	SIPRegistration struct {
+		// Whether to register the caller's address at the SIP server
+		Enabled bool
+
+		// The requested expiry of the registration; defaults to 1h
+		Expires time.Duration
+	}
+
+	// SIPProbe defines whether and how to probe the SIP server's health.
+	SIPProbe struct {
+		// Whether to periodically send OPTIONS requests to the SIP server
+		Enabled bool
+
+		// The interval between two probes; defaults to 1m
+		Interval time.Duration
+
+		// Whether to blink the status LED in an error pattern while the SIP server is unreachable
+		StatusLED bool
+	}
+
+	// SIPInbound defines whether and how to accept incoming calls.
+	SIPInbound struct {
+		// Whether to accept incoming calls
+		Enabled bool
+
+		// The UDP and TCP address to listen on; defaults to :5060
+		Address string
+
+		// The IP addresses or networks (CIDR notation) calls are accepted from, i.e. the PBX; the caller's SIP
+		// address is not considered as it is not authenticated
+		Allow []string
+
+		// The PIN callers must enter before each command; defaults to the door opener's PIN
+		PIN string
+	}
+
+	// SIPCallee defines a callee of a hunt group.
+	SIPCallee struct {
+		// The callee's SIP address
+		Address string
+
+		// Max duration to ring the callee; defaults to SIP.MaxRingingTime
+		MaxRingingTime time.Duration
+	}
+
+	// SIPRetry defines how to handle callees that are busy or temporarily unavailable.
+	SIPRetry struct {
+		// How often to ring a busy callee again; defaults to 0
+		BusyRetries int
+
+		// The time to wait before ringing a busy callee again; defaults to 5s
+		BusyDelay time.Duration
+
+		// How often to ring a callee again after the server responded 503 Service Unavailable with a
+		// Retry-After header; defaults to 0
+		UnavailableRetries int
+
+		// The max Retry-After to wait for; longer delays are not worth waiting for a door bell. Defaults to 30s
+		MaxRetryAfter time.Duration
+
+		// The SIP address of a callee to ring if all callees are busy (optional)
+		BusyFallback string
+	}
+
 	SIP struct {
 		// The caller's SIP address
 		Caller string
 
-		// The callee's SIP address
+		// The callee's SIP address; ignored if Callees is set. Only used if no bells are configured
 		Callee string
 
-		// Max duration to ring the users phone
+		// The callees to ring (hunt group); only used if no bells are configured
+		Callees []SIPCallee
+
+		// How to ring the callees; either parallel (default; the first callee to answer wins) or sequential.
+		// Only used if no bells are configured
+		Strategy string
+
+		// Max duration to ring the users phone; the default for sip bells
 		MaxRingingTime time.Duration
 
+		// How to handle busy or unavailable callees; only used if no bells are configured
+		Retry SIPRetry
+
 		// Server settings
 		Server SIPServer
+
+		// Incoming calls
+		Inbound SIPInbound
 	}
 
 	// StatusLED defines the config for the status led.
@@ -53,13 +209,53 @@ type (
 		BlinkDuration time.Duration
 	}
 
-	// ExternalBell is anything that can be switched on and off.
+	// ExternalBell is anything that can be switched on and off. Only used if no bells are configured.
 	ExternalBell struct {
 		// GPIO number (not the physical pin) to connect the status LED on
 		GPIO int
 
 		// Duration to ring the external bell (keep the relay open) when a bell push is pressed
 		RingDuration time.Duration
+
+		// Rules defining when the external bell is quiet, i.e. "20:00-07:00"; optional
+		Schedule []string
+	}
+
+	// DoorOpener defines the relay opening the door when the callee answering a phone bell enters the PIN.
+	DoorOpener struct {
+		// Whether to enable the door opener
+		Enabled bool
+
+		// GPIO number (not the physical pin) to connect the door opener's relay to
+		GPIO int
+
+		// Duration to keep the relay closed when opening the door
+		Duration time.Duration
+
+		// The sequence of DTMF digits (0-9, *, A-D) to enter followed by # to open the door
+		PIN string
+	}
+
+	// Intercom defines the sound device used to talk to the visitor once the SIP phone call has been answered.
+	Intercom struct {
+		// The sound device; empty to disable the intercom. Currently only loopback is supported which echoes
+		// the callee's voice (useful to test the audio path)
+		Device string
+	}
+
+	// Mailbox defines where and how to record messages if nobody answers the SIP phone call.
+	Mailbox struct {
+		// Whether to record messages; requires the intercom
+		Enabled bool
+
+		// The spool directory to store messages in
+		Directory string
+
+		// Path of a WAV file (16 bit PCM, mono, 8 kHz) to play before recording; optional
+		Prompt string
+
+		// Max duration of a single message; defaults to 30s and must not exceed 2m
+		MaxDuration time.Duration
 	}
 
 	// BellPush defines the individual bell pushes the system should react on.
@@ -69,6 +265,15 @@ type (
 
 		// GPIO number (not the physical pin) to connect the bell push IN to
 		GPIO int
+
+		// Path of a WAV file (16 bit PCM, mono, 8 kHz) to play to callees answering a phone bell; optional
+		Announcement string
+
+		// Labels of the bells to ring; rings all bells if empty
+		Bells []string
+
+		// Rules defining when the bell push is quiet, i.e. "Mon-Fri 20:00-07:00"; optional
+		Schedule []string
 	}
 
 	// Controller defines the config for the controller.
@@ -91,18 +296,40 @@ type (
 		SIP          SIP
 		StatusLED    StatusLED
 		ExternalBell ExternalBell
+		DoorOpener   DoorOpener
+		Intercom     Intercom
+		Mailbox      Mailbox
+		Bells        []Bell
 		BellPushes   []BellPush
 		Logging      Logging
 		Controller   Controller
 		DisableGPIO  bool
+
+		// The time zone schedules are evaluated in, i.e. Europe/Berlin; defaults to the system's time zone
+		Timezone string
+
+		// Path of the file to persist the enabled state of bells and bell pushes in; defaults to
+		// /var/lib/raspidoor/state.json. Set to off to not persist the state.
+		StateFile string
 	}
 )
 
 func (c Config) NewLogger() (logging.Logger, error) {
+	var l logging.Logger
 	if c.Logging.Target == "syslog" {
-		return logging.Syslog("raspidoord")
+		var err error
+		l, err = logging.Syslog("raspidoord")
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		l = logging.Stdout()
 	}
-	return logging.Stdout(), nil
+
+	if !c.Logging.Debug {
+		l = logging.DiscardDebug(l)
+	}
+	return l, nil
 }
 
 func (c Config) GatekeeperOptions() (gatekeeper.Options, error) {
@@ -113,17 +340,43 @@ func (c Config) GatekeeperOptions() (gatekeeper.Options, error) {
 		return gatekeeper.Options{}, err
 	}
 
-	callee, err := sip.ParseURI(c.SIP.Callee)
+	secure := strings.ToLower(c.SIP.Server.Transport) == "tls"
+	if caller.Secure() && !secure {
+		return gatekeeper.Options{}, fmt.Errorf("sips URIs require SIP transport tls")
+	}
+
+	trace, err := c.SIP.Server.traceOptions()
+	if err != nil {
+		return gatekeeper.Options{}, err
+	}
+
+	transport, err := c.SIP.Server.newTransport(trace.Tracer)
 	if err != nil {
 		return gatekeeper.Options{}, err
 	}
 
-	transport := &sip.TCPTransport{
-		DumpRoundTrips: c.SIP.Server.Debug,
+	authHandlers := []sip.AuthenticationHandler{sip.NewDigestHandler(c.SIP.Server.User, c.SIP.Server.Password)}
+
+	var registration *sip.Registration
+	if c.SIP.Server.Registration.Enabled {
+		registration = sip.NewRegistration(transport, c.SIP.Server.registrar(), caller, c.SIP.Server.Registration.Expires, authHandlers...)
+	}
+
+	var probe *sip.Probe
+	if c.SIP.Server.Probe.Enabled {
+		probe = sip.NewProbe(transport, c.SIP.Server.registrar(), caller, c.SIP.Server.Probe.Interval, authHandlers...)
 	}
 
 	bellPushes := make([]gatekeeper.BellPushOptions, len(c.BellPushes))
 	for i, p := range c.BellPushes {
+		var announcement []int16
+		if p.Announcement != "" {
+			announcement, err = audio.ReadWAVFile(p.Announcement)
+			if err != nil {
+				return gatekeeper.Options{}, fmt.Errorf("failed to read announcement for bell push %s: %w", p.Label, err)
+			}
+		}
+
 		var input gpio.DigitalInput
 		if c.DisableGPIO {
 			input = gpio.NewNOOPDigitalInput()
@@ -134,9 +387,17 @@ func (c Config) GatekeeperOptions() (gatekeeper.Options, error) {
 			}
 		}
 
+		schedule, err := gatekeeper.ParseSchedule(p.Schedule)
+		if err != nil {
+			return gatekeeper.Options{}, fmt.Errorf("invalid schedule for bell push %s: %w", p.Label, err)
+		}
+
 		bellPushes[i] = gatekeeper.BellPushOptions{
-			Label: p.Label,
-			Input: input,
+			Label:        p.Label,
+			Input:        input,
+			Announcement: announcement,
+			Bells:        p.Bells,
+			Schedule:     schedule,
 		}
 	}
 
@@ -150,27 +411,333 @@ func (c Config) GatekeeperOptions() (gatekeeper.Options, error) {
 		}
 	}
 
-	var externalBell gpio.DigitalOutput
-	if c.DisableGPIO {
-		externalBell = gpio.NewNOOPDigitalOutput()
-	} else {
-		externalBell, err = gpio.NewDigitalOutput(gpio.DefaultChip, c.ExternalBell.GPIO)
+	var opener *gatekeeper.DoorOpener
+	if c.DoorOpener.Enabled {
+		opener, err = c.DoorOpener.newDoorOpener(c.DisableGPIO)
 		if err != nil {
 			return gatekeeper.Options{}, err
 		}
 	}
 
+	intercom, err := c.Intercom.newDevice()
+	if err != nil {
+		return gatekeeper.Options{}, err
+	}
+
+	var mb *mailbox.Mailbox
+	if c.Mailbox.Enabled {
+		if intercom == nil {
+			return gatekeeper.Options{}, fmt.Errorf("mailbox requires an intercom device")
+		}
+
+		mb, err = c.Mailbox.newMailbox()
+		if err != nil {
+			return gatekeeper.Options{}, err
+		}
+	}
+
+	bells, err := newBells(c.bells(), &bellEnv{
+		disableGPIO:    c.DisableGPIO,
+		maxRingingTime: c.SIP.MaxRingingTime,
+		caller:         caller,
+		secure:         secure,
+		transport:      transport,
+		authHandlers:   authHandlers,
+		opener:         opener,
+	})
+	if err != nil {
+		return gatekeeper.Options{}, err
+	}
+
+	var location *time.Location
+	if c.Timezone != "" {
+		location, err = time.LoadLocation(c.Timezone)
+		if err != nil {
+			return gatekeeper.Options{}, fmt.Errorf("invalid timezone: %w", err)
+		}
+	}
+
+	var store *gatekeeper.StateStore
+	switch c.StateFile {
+	case "off":
+	case "":
+		store = gatekeeper.NewStateStore(defaultStateFile)
+	default:
+		store = gatekeeper.NewStateStore(c.StateFile)
+	}
+
+	var inbound *gatekeeper.InboundOptions
+	if c.SIP.Inbound.Enabled {
+		inbound, err = c.SIP.Inbound.newInboundOptions(trace.Tracer)
+		if err != nil {
+			return gatekeeper.Options{}, err
+		}
+
+		if registration != nil {
+			// Incoming calls are accepted on the inbound address rather than on the port used to register.
+			port, _ := addressPort(inbound.Address)
+			registration.SetContactPort(port)
+		}
+	}
+
 	return gatekeeper.Options{
-		StatusLED:   led,
-		LEDDuration: c.StatusLED.BlinkDuration,
-		BellPushes:  bellPushes,
-		Bells: []gatekeeper.BellOptions{
-			gatekeeper.NewExternalBell("External Bell", externalBell, c.ExternalBell.RingDuration),
-			gatekeeper.NewPhoneBell("SIP Phone", caller, callee, c.SIP.MaxRingingTime, transport, []sip.AuthenticationHandler{sip.NewDigestHandler(c.SIP.Server.User, c.SIP.Server.Password)}),
-		},
+		StatusLED:    led,
+		LEDDuration:  c.StatusLED.BlinkDuration,
+		BellPushes:   bellPushes,
+		Bells:        bells,
+		Registration: registration,
+		Probe:        probe,
+		ProbeLED:     c.SIP.Server.Probe.StatusLED,
+		DoorOpener:   opener,
+		Mailbox:      mb,
+		Intercom:     intercom,
+		Inbound:      inbound,
+		Trace:        trace,
+		Location:     location,
+		StateStore:   store,
+	}, nil
+}
+
+func (i SIPInbound) newInboundOptions(tracer sip.Tracer) (*gatekeeper.InboundOptions, error) {
+	if len(i.Allow) == 0 {
+		return nil, fmt.Errorf("incoming calls require at least one allowed address")
+	}
+
+	allow := make([]*net.IPNet, len(i.Allow))
+	for idx, a := range i.Allow {
+		n, err := parseNetwork(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed address %s: %w", a, err)
+		}
+		allow[idx] = n
+	}
+
+	pin, err := parsePIN(i.PIN)
+	if err != nil {
+		return nil, fmt.Errorf("invalid inbound PIN: %w", err)
+	}
+
+	address := i.Address
+	if address == "" {
+		address = fmt.Sprintf(":%d", sip.DefaultPort)
+	}
+
+	if _, err := addressPort(address); err != nil {
+		return nil, fmt.Errorf("invalid inbound address %s: %w", address, err)
+	}
+
+	return &gatekeeper.InboundOptions{
+		Address: address,
+		Allow:   allow,
+		PIN:     pin,
+		Tracer:  tracer,
 	}, nil
 }
 
+// addressPort returns the port of a listen address such as :5060.
+func addressPort(address string) (int, error) {
+	_, p, err := net.SplitHostPort(address)
+	if err != nil {
+		return 0, err
+	}
+
+	port, err := strconv.Atoi(p)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port: %s", p)
+	}
+	return port, nil
+}
+
+// parseNetwork parses either a network in CIDR notation or a single IP address.
+func parseNetwork(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, n, err := net.ParseCIDR(s)
+		return n, err
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("not an IP address or network")
+	}
+
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 8*net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// parsePIN parses a PIN given as DTMF digits. A trailing # is removed since # terminates the entry of a PIN.
+func parsePIN(s string) (string, error) {
+	pin := strings.TrimSuffix(strings.ToUpper(s), "#")
+	if strings.Trim(pin, "0123456789*ABCD") != "" {
+		return "", fmt.Errorf("must consist of DTMF digits 0-9, *, A-D optionally followed by #")
+	}
+	return pin, nil
+}
+
+func (m Mailbox) newMailbox() (*mailbox.Mailbox, error) {
+	if m.Directory == "" {
+		return nil, fmt.Errorf("missing mailbox directory")
+	}
+
+	maxDuration := m.MaxDuration
+	if maxDuration == 0 {
+		maxDuration = defaultMaxMessageDuration
+	}
+	if maxDuration < 0 || maxDuration > maxMessageDuration {
+		return nil, fmt.Errorf("invalid mailbox max duration: %s; must not exceed %s", m.MaxDuration, maxMessageDuration)
+	}
+
+	var prompt []int16
+	if m.Prompt != "" {
+		var err error
+		prompt, err = audio.ReadWAVFile(m.Prompt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mailbox prompt: %w", err)
+		}
+	}
+
+	return mailbox.New(m.Directory, prompt, maxDuration), nil
+}
+
+func (i Intercom) newDevice() (audio.Device, error) {
+	switch strings.ToLower(i.Device) {
+	case "":
+		return nil, nil
+	case "loopback":
+		return audio.NewLoopback(), nil
+	default:
+		return nil, fmt.Errorf("unsupported intercom device: %s", i.Device)
+	}
+}
+
+func (d DoorOpener) newDoorOpener(disableGPIO bool) (*gatekeeper.DoorOpener, error) {
+	pin, err := parsePIN(d.PIN)
+	if err != nil {
+		return nil, fmt.Errorf("invalid door opener PIN: %w", err)
+	}
+	if pin == "" {
+		return nil, fmt.Errorf("missing door opener PIN")
+	}
+
+	if disableGPIO {
+		return gatekeeper.NewDoorOpener(gpio.NewNOOPDigitalOutput(), d.Duration, pin), nil
+	}
+
+	out, err := gpio.NewDigitalOutput(gpio.DefaultChip, d.GPIO)
+	if err != nil {
+		return nil, err
+	}
+
+	return gatekeeper.NewDoorOpener(out, d.Duration, pin), nil
+}
+
+// registrar returns the URI of the SIP server to send REGISTER requests to. Without a configured port the
+// server is located via DNS.
+func (s SIPServer) registrar() sip.URI {
+	scheme := sip.SchemeSIP
+	if strings.ToLower(s.Transport) == "tls" {
+		scheme = sip.SchemeSIPS
+	}
+
+	return sip.NewURI(scheme, "", s.Host, s.Port)
+}
+
+// traceOptions returns the options to trace SIP messages with. The initial mode is validated when creating
+// the gatekeeper.
+func (s SIPServer) traceOptions() (*gatekeeper.TraceOptions, error) {
+	if s.Trace.MaxSize < 0 || s.Trace.MaxFiles < 0 {
+		return nil, fmt.Errorf("invalid SIP trace rotation: must not be negative")
+	}
+
+	t := &gatekeeper.TraceOptions{
+		Tracer:   &sip.SwitchTracer{},
+		Mode:     strings.ToLower(s.Trace.Mode),
+		File:     s.Trace.File,
+		MaxSize:  s.Trace.MaxSize,
+		MaxFiles: s.Trace.MaxFiles,
+	}
+
+	if t.Mode == "" && s.Debug {
+		t.Mode = gatekeeper.TraceLog
+	}
+
+	if t.MaxSize == 0 {
+		t.MaxSize = defaultTraceMaxSize
+	}
+
+	if t.MaxFiles == 0 {
+		t.MaxFiles = defaultTraceMaxFiles
+	}
+
+	return t, nil
+}
+
+func (s SIPServer) newTransport(tracer sip.Tracer) (sip.Transport, error) {
+	locator := &sip.Locator{}
+	if s.OutboundProxy != "" {
+		proxy, err := sip.ParseURI(s.OutboundProxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid outbound proxy: %w", err)
+		}
+		locator.OutboundProxy = &proxy
+	}
+
+	switch strings.ToLower(s.Transport) {
+	case "", "tcp":
+		return &sip.TCPTransport{
+			Locator: locator,
+			Tracer:  tracer,
+		}, nil
+	case "udp":
+		return &sip.UDPTransport{
+			Locator: locator,
+			Tracer:  tracer,
+		}, nil
+	case "tls":
+		tlsConfig, err := s.TLS.newTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		return &sip.TLSTransport{
+			Config:  tlsConfig,
+			Locator: locator,
+			Tracer:  tracer,
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid SIP transport: %s", s.Transport)
+	}
+}
+
+func (t SIPTLS) newTLSConfig() (*tls.Config, error) {
+	c := &tls.Config{
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %s", err)
+		}
+
+		c.RootCAs = x509.NewCertPool()
+		if !c.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", t.CAFile)
+		}
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %s", err)
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+
+	return c, nil
+}
+
 func ReadConfig() (*Config, error) {
 	return readConfigFromFiles(
 		"/etc/raspidoor/raspidoord.yaml",
@@ -16,15 +16,54 @@ func TestReadConfig(t *testing.T) {
 
 	if diff := deep.Equal(*config, Config{
 		SIP: SIP{
-			Caller:         "sip:caller@registrar.example.com",
-			Callee:         "sip:callee@registrar.example.com",
+			Caller: "sip:caller@registrar.example.com",
+			Callee: "sip:callee@registrar.example.com",
+			Callees: []SIPCallee{
+				{Address: "sip:alice@registrar.example.com"},
+				{Address: "sip:bob@registrar.example.com", MaxRingingTime: 10 * time.Second},
+			},
+			Strategy:       "sequential",
 			MaxRingingTime: 5 * time.Second,
+			Retry: SIPRetry{
+				BusyRetries:        2,
+				BusyDelay:          10 * time.Second,
+				UnavailableRetries: 1,
+				MaxRetryAfter:      time.Minute,
+				BusyFallback:       "sip:mobile@registrar.example.com",
+			},
 			Server: SIPServer{
-				Host:     "registrar.example.com",
-				Port:     5060,
-				User:     "caller",
-				Password: "password001",
-				Debug:    false,
+				Host:          "registrar.example.com",
+				Port:          5060,
+				User:          "caller",
+				Password:      "password001",
+				Transport:     "udp",
+				OutboundProxy: "sip:proxy.example.com",
+				TLS: SIPTLS{
+					CAFile:             "/etc/ssl/certs/pbx.pem",
+					InsecureSkipVerify: true,
+				},
+				Registration: SIPRegistration{
+					Enabled: true,
+					Expires: 30 * time.Minute,
+				},
+				Probe: SIPProbe{
+					Enabled:   true,
+					Interval:  30 * time.Second,
+					StatusLED: true,
+				},
+				Trace: SIPTrace{
+					Mode:     "pcapng",
+					File:     "/var/log/raspidoor/sip.pcapng",
+					MaxSize:  1 << 20,
+					MaxFiles: 3,
+				},
+				Debug: false,
+			},
+			Inbound: SIPInbound{
+				Enabled: true,
+				Address: ":5070",
+				Allow:   []string{"192.168.178.1", "10.0.0.0/8"},
+				PIN:     "4321#",
 			},
 		},
 		StatusLED: StatusLED{
@@ -35,16 +74,78 @@ func TestReadConfig(t *testing.T) {
 			GPIO:         25,
 			RingDuration: 2 * time.Second,
 		},
+		DoorOpener: DoorOpener{
+			Enabled:  true,
+			GPIO:     22,
+			Duration: 3 * time.Second,
+			PIN:      "1234#",
+		},
+		Bells: []Bell{
+			{
+				Label:        "Chime",
+				Type:         "gpio",
+				GPIO:         26,
+				RingDuration: time.Second,
+				Schedule:     []string{"20:00-07:00"},
+			},
+			{
+				Label:          "Phones",
+				Type:           "sip",
+				Callees:        []SIPCallee{{Address: "sip:carol@registrar.example.com"}},
+				Strategy:       "parallel",
+				MaxRingingTime: 20 * time.Second,
+				Retry:          SIPRetry{BusyRetries: 1},
+			},
+		},
 		BellPushes: []BellPush{
 			{
-				Label: "Main door",
-				GPIO:  24,
+				Label:    "Main door",
+				GPIO:     24,
+				Bells:    []string{"External Bell", "SIP Phone"},
+				Schedule: []string{"Mon-Fri 08:00-12:00", "Sun"},
 			},
 		},
 		Logging: Logging{
 			Debug: true,
 		},
+		Timezone:  "Europe/Berlin",
+		StateFile: "/var/lib/raspidoor/state.json",
 	}); diff != nil {
 		t.Error(diff)
 	}
 }
+
+func TestSIPInbound_newInboundOptions(t *testing.T) {
+	opts, err := SIPInbound{Allow: []string{"192.168.178.1", "10.0.0.0/8", "fd00::1"}, PIN: "12*3#"}.newInboundOptions(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var allow []string
+	for _, n := range opts.Allow {
+		allow = append(allow, n.String())
+	}
+
+	if diff := deep.Equal(allow, []string{"192.168.178.1/32", "10.0.0.0/8", "fd00::1/128"}); diff != nil {
+		t.Error(diff)
+	}
+
+	if opts.PIN != "12*3" {
+		t.Errorf("expected PIN 12*3 but got %q", opts.PIN)
+	}
+
+	tests := map[string]SIPInbound{
+		"no allowed address":  {},
+		"invalid address":     {Address: "5070", Allow: []string{"10.0.0.1"}},
+		"SIP address":         {Allow: []string{"sip:alice@registrar.example.com"}},
+		"invalid network":     {Allow: []string{"10.0.0.0/33"}},
+		"invalid PIN":         {Allow: []string{"10.0.0.1"}, PIN: "12#3"},
+		"invalid PIN letters": {Allow: []string{"10.0.0.1"}, PIN: "12E"},
+	}
+
+	for name, i := range tests {
+		if _, err := i.newInboundOptions(nil); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -2,14 +2,20 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"os"
+	"time"
 
 	"github.com/halimath/raspidoor/controller"
 	"github.com/halimath/raspidoor/daemon/internal/gatekeeper"
+	"github.com/halimath/raspidoor/daemon/internal/mailbox"
+	"github.com/halimath/raspidoor/daemon/internal/sip"
 	"github.com/halimath/raspidoor/systemd/logging"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type Controller struct {
@@ -22,23 +28,67 @@ type Controller struct {
 }
 
 func (c *Controller) SetState(ctx context.Context, msg *controller.EnabledState) (*controller.Result, error) {
-	c.logger.Info("Received SetState: %s %d %v", msg.Target.String(), msg.Index, msg.State)
+	c.logger.Info("Received SetState: %s %d %v (duration %dms, until %d)", msg.Target.String(), msg.Index, msg.State, msg.DurationMillis, msg.Until)
 
-	if msg.Target == controller.Target_BELL_PUSH {
-		if err := c.gatekeeper.SetBellPushState(int(msg.Index), msg.State); err != nil {
-			return failed(err.Error())
-		}
-		return ok()
+	var until time.Time
+	switch {
+	case msg.DurationMillis != 0 && msg.Until != 0:
+		return failed("duration and until are mutually exclusive")
+	case msg.DurationMillis < 0:
+		return failed("duration must not be negative")
+	case msg.DurationMillis > 0:
+		until = time.Now().Add(time.Duration(msg.DurationMillis) * time.Millisecond)
+	case msg.Until != 0:
+		until = time.Unix(msg.Until, 0)
 	}
 
-	if msg.Target == controller.Target_BELL {
-		if err := c.gatekeeper.SetBellState(int(msg.Index), msg.State); err != nil {
-			return failed(err.Error())
-		}
-		return ok()
+	var err error
+	switch msg.Target {
+	case controller.Target_BELL_PUSH:
+		err = c.gatekeeper.SetBellPushState(int(msg.Index), msg.State, until)
+	case controller.Target_BELL:
+		err = c.gatekeeper.SetBellState(int(msg.Index), msg.State, until)
+	default:
+		return failed(fmt.Sprintf("unknown target: %d", msg.Target))
+	}
+
+	if err != nil {
+		return failed(err.Error())
+	}
+	return ok()
+}
+
+func (c *Controller) SetRouting(ctx context.Context, msg *controller.Routing) (*controller.Result, error) {
+	c.logger.Info("Received SetRouting: %d %v", msg.Index, msg.Bells)
+
+	if err := c.gatekeeper.SetBellPushBells(int(msg.Index), msg.Bells); err != nil {
+		return failed(err.Error())
+	}
+
+	return ok()
+}
+
+func (c *Controller) SetSchedule(ctx context.Context, msg *controller.Schedule) (*controller.Result, error) {
+	c.logger.Info("Received SetSchedule: %s %d %v", msg.Target.String(), msg.Index, msg.Rules)
+
+	s, err := gatekeeper.ParseSchedule(msg.Rules)
+	if err != nil {
+		return failed(err.Error())
 	}
 
-	return failed(fmt.Sprintf("unknown target: %d", msg.Target))
+	switch msg.Target {
+	case controller.Target_BELL_PUSH:
+		err = c.gatekeeper.SetBellPushSchedule(int(msg.Index), s)
+	case controller.Target_BELL:
+		err = c.gatekeeper.SetBellSchedule(int(msg.Index), s)
+	default:
+		return failed(fmt.Sprintf("unknown target: %d", msg.Target))
+	}
+
+	if err != nil {
+		return failed(err.Error())
+	}
+	return ok()
 }
 
 func (c *Controller) Ring(context.Context, *controller.Empty) (*controller.Empty, error) {
@@ -57,21 +107,190 @@ func (c *Controller) Info(ctx context.Context, _ *controller.Empty) (*controller
 
 	for idx, p := range i.BellPushes {
 		r.BellPushes[idx] = &controller.ItemState{
-			Label:   p.Label,
-			Enabled: p.Enabled,
+			Label:           p.Label,
+			Enabled:         p.Enabled,
+			Bells:           p.Bells,
+			Schedule:        p.Schedule.Strings(),
+			Quiet:           p.Quiet,
+			Until:           unix(p.Until),
+			RemainingMillis: p.Remaining.Milliseconds(),
 		}
 	}
 
 	for idx, b := range i.Bells {
 		r.Bells[idx] = &controller.ItemState{
-			Label:   b.Label,
-			Enabled: b.Enabled,
+			Label:           b.Label,
+			Enabled:         b.Enabled,
+			Schedule:        b.Schedule.Strings(),
+			Quiet:           b.Quiet,
+			Until:           unix(b.Until),
+			RemainingMillis: b.Remaining.Milliseconds(),
+		}
+	}
+
+	if i.Registration != nil {
+		r.Registration = &controller.RegistrationState{
+			State:     i.Registration.State.String(),
+			Registrar: i.Registration.Registrar.String(),
+		}
+
+		if !i.Registration.ExpiresAt.IsZero() {
+			r.Registration.ExpiresAt = i.Registration.ExpiresAt.Unix()
+		}
+
+		if i.Registration.Error != nil {
+			r.Registration.Error = i.Registration.Error.Error()
 		}
 	}
 
 	return &r, nil
 }
 
+func (c *Controller) Health(ctx context.Context, _ *controller.Empty) (*controller.HealthState, error) {
+	h := c.gatekeeper.Info().Health
+	if h == nil {
+		return &controller.HealthState{}, nil
+	}
+
+	r := controller.HealthState{
+		Probing:       true,
+		Server:        h.Server.String(),
+		Healthy:       h.Healthy,
+		LatencyMillis: h.Latency.Milliseconds(),
+	}
+
+	if !h.LastProbe.IsZero() {
+		r.LastProbe = h.LastProbe.Unix()
+	}
+
+	if !h.LastSuccess.IsZero() {
+		r.LastSuccess = h.LastSuccess.Unix()
+	}
+
+	if h.Error != nil {
+		r.Error = h.Error.Error()
+	}
+
+	return &r, nil
+}
+
+func (c *Controller) History(ctx context.Context, _ *controller.Empty) (*controller.CallHistory, error) {
+	records := c.gatekeeper.History()
+
+	r := controller.CallHistory{
+		Calls: make([]*controller.CallRecord, len(records)),
+	}
+
+	for idx, rec := range records {
+		r.Calls[idx] = &controller.CallRecord{
+			Time:     rec.Time.Unix(),
+			Bell:     rec.Bell,
+			BellPush: rec.BellPush,
+			Result:   rec.Result.String(),
+		}
+
+		if rec.Result == sip.ResultAnswered {
+			r.Calls[idx].Callee = rec.Callee.String()
+		}
+
+		if rec.Error != nil {
+			r.Calls[idx].Error = rec.Error.Error()
+		}
+	}
+
+	return &r, nil
+}
+
+func (c *Controller) SetTrace(ctx context.Context, msg *controller.TraceMode) (*controller.Result, error) {
+	c.logger.Info("Received SetTrace: %s", msg.Mode)
+
+	if err := c.gatekeeper.SetTraceMode(msg.Mode); err != nil {
+		return failed(err.Error())
+	}
+
+	return ok()
+}
+
+func (c *Controller) Trace(ctx context.Context, _ *controller.Empty) (*controller.TraceState, error) {
+	t := c.gatekeeper.TraceState()
+	return &controller.TraceState{
+		Mode: t.Mode,
+		File: t.File,
+	}, nil
+}
+
+func (c *Controller) ListMessages(ctx context.Context, _ *controller.Empty) (*controller.MessageList, error) {
+	r := controller.MessageList{}
+
+	m := c.gatekeeper.Mailbox()
+	if m == nil {
+		return &r, nil
+	}
+
+	messages, err := m.List()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list messages: %s", err)
+	}
+
+	r.Messages = make([]*controller.MessageInfo, len(messages))
+	for idx, msg := range messages {
+		r.Messages[idx] = messageInfo(msg)
+	}
+
+	return &r, nil
+}
+
+func (c *Controller) FetchMessage(ctx context.Context, msg *controller.MessageID) (*controller.Message, error) {
+	m := c.gatekeeper.Mailbox()
+	if m == nil {
+		return nil, status.Error(codes.FailedPrecondition, "no mailbox configured")
+	}
+
+	info, data, err := m.Read(msg.Id)
+	if errors.Is(err, mailbox.ErrNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to read message: %s", err)
+	}
+
+	return &controller.Message{
+		Info: messageInfo(info),
+		Wav:  data,
+	}, nil
+}
+
+func (c *Controller) DeleteMessage(ctx context.Context, msg *controller.MessageID) (*controller.Result, error) {
+	c.logger.Info("Received DeleteMessage: %s", msg.Id)
+
+	m := c.gatekeeper.Mailbox()
+	if m == nil {
+		return failed("no mailbox configured")
+	}
+
+	if err := m.Delete(msg.Id); err != nil {
+		return failed(err.Error())
+	}
+
+	return ok()
+}
+
+func messageInfo(msg mailbox.Message) *controller.MessageInfo {
+	return &controller.MessageInfo{
+		Id:             msg.ID,
+		RecordedAt:     msg.Time.Unix(),
+		DurationMillis: msg.Duration.Milliseconds(),
+	}
+}
+
+// unix returns t as a unix timestamp; 0 if t is zero.
+func unix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
 func ok() (*controller.Result, error) {
 	return &controller.Result{
 		Ok: true,
@@ -1,6 +1,9 @@
 package gatekeeper
 
 import (
+	"context"
+	"errors"
+	"sync"
 	"time"
 
 	"github.com/halimath/raspidoor/daemon/internal/gpio"
@@ -8,9 +11,37 @@ import (
 	"github.com/halimath/raspidoor/systemd/logging"
 )
 
+const (
+	// Parallel rings all callees at once; the first callee to answer wins and the calls to all other callees
+	// are cancelled.
+	Parallel Strategy = iota
+
+	// Sequential rings the callees one after another, each for its max ringing time, until one answers.
+	Sequential
+)
+
 type (
+	// RingEvent describes why the bells ring.
+	RingEvent struct {
+		// BellPush is the label of the bell push that has been pressed; empty if rung via the controller.
+		BellPush string
+
+		// Announcement contains the samples to play to callees answering a phone bell; nil for none.
+		Announcement []int16
+
+		// report receives the outcome of calls placed by phone bells; nil to not record them.
+		report func(CallRecord)
+
+		// calls tracks the calls placed by phone bells in the background; nil to not track them.
+		calls *sync.WaitGroup
+
+		// intercom is connected to callees answering a phone bell; nil for none.
+		intercom *intercom
+	}
+
 	Ringer interface {
-		Ring(logger logging.Logger)
+		// Ring rings the bell. Ringers that keep ringing in the background must stop once ctx is done.
+		Ring(ctx context.Context, evt RingEvent, logger logging.Logger)
 		Close() error
 	}
 
@@ -19,16 +50,47 @@ type (
 		out gpio.DigitalOutput
 	}
 
+	// Strategy defines how a phone bell rings several callees.
+	Strategy int
+
+	// Callee is a callee rung by a phone bell.
+	Callee struct {
+		URI            sip.URI
+		MaxRingingTime time.Duration
+	}
+
+	// RetryPolicy defines how a phone bell handles callees that are busy or temporarily unavailable. The zero
+	// value does not retry.
+	RetryPolicy struct {
+		// BusyRetries is the number of times to ring a busy callee again after waiting BusyDelay.
+		BusyRetries int
+		BusyDelay   time.Duration
+
+		// UnavailableRetries is the number of times to ring a callee again after 503 Service Unavailable once
+		// the time given by the response's Retry-After header has passed. Responses without Retry-After or
+		// asking to wait longer than MaxRetryAfter are not retried.
+		UnavailableRetries int
+		MaxRetryAfter      time.Duration
+
+		// BusyFallback is rung if all callees are busy; nil for none.
+		BusyFallback *Callee
+	}
+
 	phoneBell struct {
-		caller         sip.URI
-		callee         sip.URI
-		maxRingingTime time.Duration
-		transport      sip.Transport
-		authHandler    []sip.AuthenticationHandler
+		caller      sip.URI
+		callees     []Callee
+		strategy    Strategy
+		retry       RetryPolicy
+		transport   sip.Transport
+		authHandler []sip.AuthenticationHandler
+		opener      *DoorOpener
+
+		// calls tracks the calls in progress so that Close can wait for them.
+		calls sync.WaitGroup
 	}
 )
 
-func (e *externalBell) Ring(logger logging.Logger) {
+func (e *externalBell) Ring(_ context.Context, _ RingEvent, logger logging.Logger) {
 	if err := gpio.OnFor(e.out, e.dur); err != nil {
 		logger.Error("failed to ring external bell: %s", err)
 	}
@@ -36,16 +98,269 @@ func (e *externalBell) Ring(logger logging.Logger) {
 
 func (e *externalBell) Close() error { return e.out.Close() }
 
-func (p *phoneBell) Ring(logger logging.Logger) {
+func (p *phoneBell) Ring(ctx context.Context, evt RingEvent, logger logging.Logger) {
+	p.calls.Add(1)
+	if evt.calls != nil {
+		evt.calls.Add(1)
+	}
+
 	go func() {
-		d := sip.NewDialog(p.transport, p.caller, p.authHandler...)
-		if _, err := d.Ring(p.callee, p.maxRingingTime); err != nil {
+		defer p.calls.Done()
+		if evt.calls != nil {
+			defer evt.calls.Done()
+		}
+
+		start := time.Now()
+
+		var result sip.Result
+		var answeredBy sip.URI
+		var err error
+		if p.strategy == Sequential {
+			result, answeredBy, err = p.ringSequential(ctx, evt, logger)
+		} else {
+			result, answeredBy, err = p.ringParallel(ctx, evt, logger)
+		}
+
+		if err == nil && result == sip.ResultBusy && p.retry.BusyFallback != nil {
+			logger.Info("All SIP phones are busy; ringing fallback %s", p.retry.BusyFallback.URI)
+			result, answeredBy, err = p.ringCallee(ctx, evt, logger, nil, *p.retry.BusyFallback)
+		}
+
+		if errors.Is(err, context.Canceled) {
+			logger.Info("Cancelled call to SIP phone")
+			return
+		}
+
+		if evt.report != nil {
+			evt.report(CallRecord{
+				Time:     start,
+				BellPush: evt.BellPush,
+				Result:   result,
+				Callee:   answeredBy,
+				Error:    err,
+			})
+		}
+
+		if err != nil {
 			logger.Error("failed to ring SIP phone: %s", err)
+			return
+		}
+
+		if result == sip.ResultAnswered {
+			logger.Info("Rang SIP phone: answered by %s", answeredBy)
+		} else {
+			logger.Info("Rang SIP phone: %s", result)
 		}
 	}()
 }
 
+// ringSequential rings the callees one after another until one of them answers. It returns the result, the
+// callee that answered and an error if ringing failed for all callees.
+func (p *phoneBell) ringSequential(ctx context.Context, evt RingEvent, logger logging.Logger) (sip.Result, sip.URI, error) {
+	var results []sip.Result
+	var errs []error
+
+	for _, c := range p.callees {
+		result, answeredBy, err := p.ringCallee(ctx, evt, logger, nil, c)
+		if ctx.Err() != nil {
+			return sip.ResultFailed, sip.URI{}, ctx.Err()
+		}
+		if err != nil {
+			logger.Error("failed to ring SIP phone %s: %s", c.URI, err)
+			errs = append(errs, err)
+			continue
+		}
+
+		logger.Info("Rang SIP phone %s: %s", c.URI, result)
+		if result == sip.ResultAnswered {
+			return result, answeredBy, nil
+		}
+		results = append(results, result)
+	}
+
+	return combineResults(results, errs)
+}
+
+// ringParallel rings all callees at once. The first callee to answer is connected; the calls to all other
+// callees are cancelled. It returns the result, the callee that answered and an error if ringing failed for
+// all callees.
+func (p *phoneBell) ringParallel(ctx context.Context, evt RingEvent, logger logging.Logger) (sip.Result, sip.URI, error) {
+	type outcome struct {
+		idx    int
+		result sip.Result
+		callee sip.URI
+		err    error
+	}
+
+	ctxs := make([]context.Context, len(p.callees))
+	cancels := make([]context.CancelFunc, len(p.callees))
+	for i := range p.callees {
+		ctxs[i], cancels[i] = context.WithCancel(ctx)
+		defer cancels[i]()
+	}
+
+	var lock sync.Mutex
+	winner := -1
+
+	outcomes := make(chan outcome, len(p.callees))
+	for i, c := range p.callees {
+		go func(i int, c Callee) {
+			claim := func() bool {
+				lock.Lock()
+				defer lock.Unlock()
+
+				if winner >= 0 {
+					// Another callee answered first.
+					return false
+				}
+
+				winner = i
+				for j, cancel := range cancels {
+					if j != i {
+						cancel()
+					}
+				}
+				return true
+			}
+
+			result, callee, err := p.ringCallee(ctxs[i], evt, logger, claim, c)
+			outcomes <- outcome{idx: i, result: result, callee: callee, err: err}
+		}(i, c)
+	}
+
+	var results []sip.Result
+	var errs []error
+	var answeredBy sip.URI
+	for range p.callees {
+		o := <-outcomes
+		c := p.callees[o.idx]
+
+		lock.Lock()
+		won, cancelled := winner == o.idx, winner >= 0
+		lock.Unlock()
+
+		switch {
+		case won:
+			logger.Info("Rang SIP phone %s: %s", c.URI, o.result)
+			answeredBy = o.callee
+		case ctx.Err() != nil || (cancelled && errors.Is(o.err, context.Canceled)):
+			// Cancelled because another callee answered or the gatekeeper has been closed.
+		case o.err != nil:
+			logger.Error("failed to ring SIP phone %s: %s", c.URI, o.err)
+			errs = append(errs, o.err)
+		default:
+			logger.Info("Rang SIP phone %s: %s", c.URI, o.result)
+			results = append(results, o.result)
+		}
+	}
+
+	// All dialogs have finished, so winner can be read without the lock.
+	if winner >= 0 {
+		return sip.ResultAnswered, answeredBy, nil
+	}
+
+	if ctx.Err() != nil {
+		return sip.ResultFailed, sip.URI{}, ctx.Err()
+	}
+
+	return combineResults(results, errs)
+}
+
+// ringCallee rings c and rings again according to the retry policy while c is busy or temporarily
+// unavailable. It returns the result and the callee rung last, which differs from c's URI if the call has been
+// redirected. claim is passed to dialog.
+func (p *phoneBell) ringCallee(ctx context.Context, evt RingEvent, logger logging.Logger, claim func() bool, c Callee) (sip.Result, sip.URI, error) {
+	var busy, unavailable int
+
+	for {
+		d := p.dialog(evt, logger, claim)
+		result, err := d.Ring(ctx, c.URI, c.MaxRingingTime)
+
+		var delay time.Duration
+		switch {
+		case err == nil && result == sip.ResultBusy && busy < p.retry.BusyRetries:
+			busy++
+			delay = p.retry.BusyDelay
+		case errors.Is(err, sip.ErrServiceUnavailable) && unavailable < p.retry.UnavailableRetries &&
+			d.RetryAfter() > 0 && d.RetryAfter() <= p.retry.MaxRetryAfter:
+			unavailable++
+			delay = d.RetryAfter()
+		default:
+			return result, d.Callee(), err
+		}
+
+		logger.Info("SIP phone %s is unavailable (%s); ringing again in %s", c.URI, describe(result, err), delay)
+
+		select {
+		case <-ctx.Done():
+			return sip.ResultFailed, sip.URI{}, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// describe returns a short description of the outcome of ringing a callee for log messages.
+func describe(result sip.Result, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return result.String()
+}
+
+// dialog creates a dialog to ring a callee. claim is invoked when the callee answers - even if no media session
+// could be negotiated - and reports whether to talk to the callee; the call is hung up immediately otherwise.
+// claim may be nil.
+func (p *phoneBell) dialog(evt RingEvent, logger logging.Logger, claim func() bool) *sip.Dialog {
+	d := sip.NewDialog(p.transport, p.caller, p.authHandler...)
+
+	var c *conversation
+	if len(evt.Announcement) > 0 || p.opener != nil || evt.intercom != nil {
+		c = &conversation{
+			announcement: evt.Announcement,
+			intercom:     evt.intercom,
+			logger:       logger,
+		}
+		if p.opener != nil {
+			c.command = p.opener.pinCommand(logger)
+		}
+	}
+
+	if claim != nil {
+		d.OnConfirm(claim)
+	}
+
+	if c != nil {
+		d.OnAnswer(c.handle)
+	}
+
+	return d
+}
+
+// combineResults combines the results of ringing several callees none of which answered: if all callees
+// declined (were busy, redirected the call) the call has been declined (busy, redirected); otherwise it has not
+// been answered. errs contains the errors of callees that could not be rung; the first one is returned if no
+// callee could be rung at all.
+func combineResults(results []sip.Result, errs []error) (sip.Result, sip.URI, error) {
+	if len(results) == 0 && len(errs) > 0 {
+		return sip.ResultFailed, sip.URI{}, errs[0]
+	}
+
+	if len(results) == 0 {
+		return sip.ResultDeclined, sip.URI{}, nil
+	}
+
+	for _, r := range results[1:] {
+		if r != results[0] {
+			return sip.ResultNotAnswered, sip.URI{}, nil
+		}
+	}
+
+	return results[0], sip.URI{}, nil
+}
+
+// Close waits for all calls in progress to finish. Cancel the context passed to Ring to abort them.
 func (p *phoneBell) Close() error {
+	p.calls.Wait()
 	return nil
 }
 
@@ -59,21 +374,27 @@ func NewExternalBell(label string, out gpio.DigitalOutput, dur time.Duration) Be
 	}
 }
 
+// NewPhoneBell creates a bell ringing callees via SIP using the given strategy and retry policy. The door
+// opener is optional. Callees answering are connected to the gatekeeper's intercom.
 func NewPhoneBell(label string,
 	caller sip.URI,
-	callee sip.URI,
-	maxRingingTime time.Duration,
+	callees []Callee,
+	strategy Strategy,
+	retry RetryPolicy,
 	transport sip.Transport,
 	authHandler []sip.AuthenticationHandler,
+	opener *DoorOpener,
 ) BellOptions {
 	return BellOptions{
 		Label: label,
 		Ringer: &phoneBell{
-			caller:         caller,
-			callee:         callee,
-			maxRingingTime: maxRingingTime,
-			transport:      transport,
-			authHandler:    authHandler,
+			caller:      caller,
+			callees:     callees,
+			strategy:    strategy,
+			retry:       retry,
+			transport:   transport,
+			authHandler: authHandler,
+			opener:      opener,
 		},
 	}
 }
@@ -0,0 +1,237 @@
+package gatekeeper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/halimath/raspidoor/daemon/internal/sip"
+	"github.com/halimath/raspidoor/systemd/logging"
+)
+
+func TestPhoneBell_sequential(t *testing.T) {
+	declining := servePhone(t, false)
+	answering := servePhone(t, true)
+
+	p := &phoneBell{
+		caller: sip.NewURI("sip", "door", "127.0.0.1", 5060),
+		callees: []Callee{
+			{URI: declining, MaxRingingTime: time.Second},
+			{URI: answering, MaxRingingTime: time.Second},
+		},
+		strategy:  Sequential,
+		transport: &sip.UDPTransport{},
+	}
+
+	result, answeredBy, err := p.ringSequential(context.Background(), RingEvent{}, logging.Stdout())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != sip.ResultAnswered || answeredBy.String() != answering.String() {
+		t.Errorf("expected call to be answered by %s but got %s/%s", answering, result, answeredBy)
+	}
+}
+
+func TestPhoneBell_parallel(t *testing.T) {
+	p := &phoneBell{
+		caller: sip.NewURI("sip", "door", "127.0.0.1", 5060),
+		callees: []Callee{
+			{URI: servePhone(t, false), MaxRingingTime: time.Second},
+			{URI: servePhone(t, false), MaxRingingTime: time.Second},
+		},
+		strategy:  Parallel,
+		transport: &sip.UDPTransport{},
+	}
+
+	result, _, err := p.ringParallel(context.Background(), RingEvent{}, logging.Stdout())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != sip.ResultDeclined {
+		t.Errorf("expected call to be declined but got %s", result)
+	}
+
+	answering := servePhone(t, true)
+	p.callees = append(p.callees, Callee{URI: answering, MaxRingingTime: time.Second})
+
+	result, answeredBy, err := p.ringParallel(context.Background(), RingEvent{}, logging.Stdout())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != sip.ResultAnswered || answeredBy.String() != answering.String() {
+		t.Errorf("expected call to be answered by %s but got %s/%s", answering, result, answeredBy)
+	}
+}
+
+func TestPhoneBell_parallelWithoutMedia(t *testing.T) {
+	// The callee answers without an SDP answer so that no media session can be negotiated.
+	answering, _ := serveStatus(t, sip.StatusOK, "")
+
+	p := &phoneBell{
+		caller: sip.NewURI("sip", "door", "127.0.0.1", 5060),
+		callees: []Callee{
+			{URI: servePhone(t, false), MaxRingingTime: time.Second},
+			{URI: answering, MaxRingingTime: time.Second},
+		},
+		strategy:  Parallel,
+		transport: &sip.UDPTransport{},
+	}
+
+	result, answeredBy, err := p.ringParallel(context.Background(), RingEvent{}, logging.Stdout())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != sip.ResultAnswered || answeredBy.String() != answering.String() {
+		t.Errorf("expected call to be answered by %s but got %s/%s", answering, result, answeredBy)
+	}
+}
+
+func TestPhoneBell_busy(t *testing.T) {
+	busy, invites := serveStatus(t, sip.StatusBusyHere, "")
+	answering := servePhone(t, true)
+
+	var records []CallRecord
+	p := &phoneBell{
+		caller:  sip.NewURI("sip", "door", "127.0.0.1", 5060),
+		callees: []Callee{{URI: busy, MaxRingingTime: time.Second}},
+		retry: RetryPolicy{
+			BusyRetries:  2,
+			BusyDelay:    10 * time.Millisecond,
+			BusyFallback: &Callee{URI: answering, MaxRingingTime: time.Second},
+		},
+		transport: &sip.UDPTransport{},
+	}
+
+	p.Ring(context.Background(), RingEvent{BellPush: "front", report: func(r CallRecord) { records = append(records, r) }}, logging.Stdout())
+	p.Close()
+
+	if n := invites(); n != 3 {
+		t.Errorf("expected busy callee to be rung 3 times but got %d", n)
+	}
+
+	if len(records) != 1 {
+		t.Fatalf("expected 1 call record but got %d", len(records))
+	}
+	if r := records[0]; r.Result != sip.ResultAnswered || r.Callee.String() != answering.String() || r.BellPush != "front" {
+		t.Errorf("expected call to be answered by fallback but got %+v", r)
+	}
+}
+
+func TestPhoneBell_serviceUnavailable(t *testing.T) {
+	unavailable, invites := serveStatus(t, sip.StatusServiceUnavailable, "Retry-After: 1")
+
+	p := &phoneBell{
+		caller:    sip.NewURI("sip", "door", "127.0.0.1", 5060),
+		callees:   []Callee{{URI: unavailable, MaxRingingTime: time.Second}},
+		retry:     RetryPolicy{UnavailableRetries: 1, MaxRetryAfter: time.Second},
+		transport: &sip.UDPTransport{},
+	}
+
+	result, _, err := p.ringSequential(context.Background(), RingEvent{}, logging.Stdout())
+	if !errors.Is(err, sip.ErrServiceUnavailable) || result != sip.ResultFailed {
+		t.Errorf("expected service unavailable but got %s/%v", result, err)
+	}
+
+	if n := invites(); n != 2 {
+		t.Errorf("expected callee to be rung twice but got %d", n)
+	}
+}
+
+func TestCombineResults(t *testing.T) {
+	tests := []struct {
+		results []sip.Result
+		want    sip.Result
+	}{
+		{results: []sip.Result{sip.ResultDeclined, sip.ResultDeclined}, want: sip.ResultDeclined},
+		{results: []sip.Result{sip.ResultBusy, sip.ResultBusy}, want: sip.ResultBusy},
+		{results: []sip.Result{sip.ResultRedirected}, want: sip.ResultRedirected},
+		{results: []sip.Result{sip.ResultBusy, sip.ResultDeclined}, want: sip.ResultNotAnswered},
+		{results: []sip.Result{sip.ResultDeclined, sip.ResultNotAnswered}, want: sip.ResultNotAnswered},
+	}
+
+	for _, test := range tests {
+		if got, _, _ := combineResults(test.results, nil); got != test.want {
+			t.Errorf("%v: expected %s but got %s", test.results, test.want, got)
+		}
+	}
+}
+
+// servePhone starts a SIP phone on a local UDP socket which either answers or declines all calls and returns
+// its URI.
+func servePhone(t *testing.T, answer bool) sip.URI {
+	con, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &sip.Server{
+		Accept: func(*sip.Request, net.Addr) bool { return answer },
+		Handler: func(ctx context.Context, call *sip.Call) error {
+			<-ctx.Done()
+			return nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.ServeUDP(ctx, con)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	addr := con.LocalAddr().(*net.UDPAddr)
+	return sip.NewURI("sip", "phone", addr.IP.String(), addr.Port)
+}
+
+// serveStatus starts a SIP phone on a local UDP socket which responds to all INVITEs with the given status
+// code and additional header line; BYEs are accepted. It returns the phone's URI and a function reporting the
+// number of INVITEs received.
+func serveStatus(t *testing.T, status int, header string) (sip.URI, func() int) {
+	con, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { con.Close() })
+
+	var invites int32
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, addr, err := con.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			req, err := sip.ParseRequest(bytes.NewReader(buf[:n]))
+			if err != nil || (req.Method != "INVITE" && req.Method != "BYE") {
+				continue
+			}
+
+			var res *sip.Response
+			if req.Method == "BYE" {
+				res = sip.NewResponse(req, sip.StatusOK, "OK")
+			} else {
+				atomic.AddInt32(&invites, 1)
+				res = sip.NewResponse(req, status, "Status")
+				if header != "" {
+					res.Header.ParseHeader(header)
+				}
+			}
+
+			var b bytes.Buffer
+			res.Write(&b)
+			con.WriteTo(b.Bytes(), addr)
+		}
+	}()
+
+	addr := con.LocalAddr().(*net.UDPAddr)
+	return sip.NewURI("sip", "phone", addr.IP.String(), addr.Port), func() int { return int(atomic.LoadInt32(&invites)) }
+}
@@ -0,0 +1,196 @@
+package gatekeeper
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/halimath/raspidoor/daemon/internal/audio"
+	"github.com/halimath/raspidoor/daemon/internal/rtp"
+	"github.com/halimath/raspidoor/daemon/internal/sdp"
+	"github.com/halimath/raspidoor/daemon/internal/sip"
+	"github.com/halimath/raspidoor/systemd/logging"
+)
+
+const (
+	// maxCallDuration bounds the time an answered call is kept open for the intercom or waiting for DTMF
+	// commands.
+	maxCallDuration = 2 * time.Minute
+
+	// dtmfBufferSize is the number of DTMF digits received via RTP buffered until they are matched.
+	dtmfBufferSize = 16
+)
+
+// conversation implements an answered call: the announcement is played first, followed by the audio captured
+// by the intercom. Audio received from the peer is played back by the intercom and DTMF digits entered by the
+// peer are passed to command. Without intercom and command, the call is hung up once the announcement has
+// been played. The call is connected to the intercom only if the intercom is not in use by another party.
+type conversation struct {
+	announcement []int16
+	intercom     *intercom
+
+	// command handles a DTMF digit entered by the peer. The call is hung up once command returns true or an
+	// error.
+	command func(peer sip.URI, d rune) (bool, error)
+
+	logger logging.Logger
+}
+
+// handle implements sip.AnswerHandler.
+func (c *conversation) handle(ctx context.Context, call *sip.Call) error {
+	encode, ok := audio.Encoder(call.Media.Codec.Name)
+	if !ok {
+		return fmt.Errorf("unsupported codec: %s", call.Media.Codec)
+	}
+
+	decode, ok := audio.Decoder(call.Media.Codec.Name)
+	if !ok {
+		return fmt.Errorf("unsupported codec: %s", call.Media.Codec)
+	}
+
+	sender := rtp.NewSender(call.RTP, call.Media.Remote, call.Media.Codec.PayloadType, call.Media.Codec.ClockRate)
+
+	var dev audio.Device
+	if c.intercom != nil {
+		if dev = c.intercom.acquire(); dev != nil {
+			defer c.intercom.release()
+		} else {
+			c.logger.Info("Intercom is in use; connecting %s without audio", call.Peer)
+		}
+	}
+
+	if dev == nil && c.command == nil {
+		return c.announce(ctx, sender, encode)
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	ctx, cancel := context.WithTimeout(ctx, maxCallDuration)
+	defer cancel()
+
+	digits := make(chan rune, dtmfBufferSize)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := c.receive(ctx, call, dev, decode, digits); err != nil {
+			c.logger.Error("failed to receive RTP: %s", err)
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := c.send(ctx, sender, dev, encode); err != nil && ctx.Err() == nil {
+			c.logger.Error("failed to send RTP: %s", err)
+		}
+	}()
+
+	if c.command == nil {
+		<-ctx.Done()
+		return nil
+	}
+
+	for {
+		var d rune
+		select {
+		case <-ctx.Done():
+			return nil
+		case d = <-digits:
+		case d = <-call.DTMF:
+		}
+
+		if done, err := c.command(call.Peer, d); done || err != nil {
+			return err
+		}
+	}
+}
+
+// announce plays the announcement.
+func (c *conversation) announce(ctx context.Context, sender *rtp.Sender, encode func([]int16) []byte) error {
+	frames := audio.Frames(c.announcement, sdp.Ptime*time.Millisecond)
+	payloads := make([][]byte, len(frames))
+	for i, f := range frames {
+		payloads[i] = encode(f)
+	}
+
+	return sender.Stream(ctx, payloads, sdp.Ptime*time.Millisecond)
+}
+
+// send plays the announcement followed by the audio captured by dev until ctx is done; dev may be nil.
+func (c *conversation) send(ctx context.Context, sender *rtp.Sender, dev audio.Device, encode func([]int16) []byte) error {
+	if err := c.announce(ctx, sender, encode); err != nil {
+		return err
+	}
+
+	if dev == nil {
+		return nil
+	}
+
+	frame := make([]int16, audio.SampleRate*sdp.Ptime/1000)
+	for {
+		if err := dev.Capture(ctx, frame); err != nil {
+			return err
+		}
+
+		if err := sender.Send(encode(frame), len(frame)); err != nil {
+			return err
+		}
+	}
+}
+
+// receive receives RTP packets from the callee until ctx is done. Audio is played back by dev unless dev is nil
+// and DTMF digits are sent to digits. Packets are played back in the order received.
+func (c *conversation) receive(ctx context.Context, call *sip.Call, dev audio.Device, decode func([]byte) []int16, digits chan<- rune) error {
+	var detector *rtp.DTMFDetector
+	if call.Media.TelephoneEvent != nil {
+		detector = rtp.NewDTMFDetector(call.Media.TelephoneEvent.PayloadType)
+	}
+
+	return rtp.Receive(ctx, call.RTP, func(p *rtp.Packet) {
+		switch {
+		case detector != nil && p.PayloadType == call.Media.TelephoneEvent.PayloadType:
+			if d, ok := detector.Detect(p); ok {
+				select {
+				case digits <- d:
+				default:
+				}
+			}
+
+		case dev != nil && p.PayloadType == call.Media.Codec.PayloadType:
+			if err := dev.Playback(decode(p.Payload)); err != nil {
+				c.logger.Error("failed to play back audio: %s", err)
+			}
+		}
+	})
+}
+
+// intercom is the intercom device shared by phone bell calls, incoming calls and the mailbox. Only one party
+// uses the device at a time so that the visitor never talks to several parties at once.
+type intercom struct {
+	device audio.Device
+	lock   sync.Mutex
+}
+
+// newIntercom creates an intercom using device; nil if device is nil.
+func newIntercom(device audio.Device) *intercom {
+	if device == nil {
+		return nil
+	}
+	return &intercom{device: device}
+}
+
+// acquire returns the device for exclusive use; nil if the device is in use by another party. release must be
+// called once done with the device.
+func (i *intercom) acquire() audio.Device {
+	if !i.lock.TryLock() {
+		return nil
+	}
+	return i.device
+}
+
+func (i *intercom) release() {
+	i.lock.Unlock()
+}
@@ -0,0 +1,152 @@
+package gatekeeper
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/halimath/raspidoor/daemon/internal/audio"
+	"github.com/halimath/raspidoor/daemon/internal/rtp"
+	"github.com/halimath/raspidoor/daemon/internal/sdp"
+	"github.com/halimath/raspidoor/daemon/internal/sip"
+	"github.com/halimath/raspidoor/systemd/logging"
+)
+
+func TestConversation_announcement(t *testing.T) {
+	sink, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	con, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	call := &sip.Call{
+		Media: &sdp.Negotiation{
+			Codec:  sdp.PCMA,
+			Remote: sink.LocalAddr().(*net.UDPAddr),
+		},
+		RTP: con,
+	}
+
+	// 50ms of audio are sent as 3 packets of 20ms each.
+	c := &conversation{announcement: make([]int16, 400), logger: logging.Stdout()}
+	if err := c.handle(context.Background(), call); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 1500)
+	for i := 0; i < 3; i++ {
+		sink.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := sink.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := rtp.Parse(buf[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if p.PayloadType != sdp.PCMA.PayloadType || len(p.Payload) != 160 {
+			t.Errorf("packet %d: expected 160 bytes of PCMA but got %d bytes of payload type %d", i, len(p.Payload), p.PayloadType)
+		}
+
+		// A-law silence
+		if p.Payload[0] != 0xd5 {
+			t.Errorf("packet %d: expected silence but got %#x", i, p.Payload[0])
+		}
+	}
+}
+
+func TestConversation_intercom(t *testing.T) {
+	phone, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer phone.Close()
+
+	con, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	call := &sip.Call{
+		Media: &sdp.Negotiation{
+			Codec:  sdp.PCMU,
+			Remote: phone.LocalAddr().(*net.UDPAddr),
+		},
+		RTP: con,
+	}
+
+	// The door station captures a constant signal.
+	captured := make([]int16, 160)
+	for i := range captured {
+		captured[i] = 1000
+	}
+	intercom := audio.NewFileDevice(captured)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		c := &conversation{intercom: newIntercom(intercom), logger: logging.Stdout()}
+		done <- c.handle(ctx, call)
+	}()
+
+	// The callee talks.
+	spoken := make([]int16, 160)
+	for i := range spoken {
+		spoken[i] = -2000
+	}
+	p := rtp.Packet{PayloadType: sdp.PCMU.PayloadType, Payload: audio.EncodeULaw(spoken)}
+	if _, err := phone.WriteTo(p.Marshal(), con.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The callee hears the door station followed by silence.
+	buf := make([]byte, 1500)
+	for i := 0; i < 2; i++ {
+		phone.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := phone.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := rtp.Parse(buf[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		samples := audio.DecodeULaw(p.Payload)
+		if len(samples) != 160 {
+			t.Fatalf("packet %d: expected 160 samples but got %d", i, len(samples))
+		}
+
+		want := int16(0)
+		if i == 0 {
+			want = 1000
+		}
+		if d := samples[0] - want; d < -50 || d > 50 {
+			t.Errorf("packet %d: expected %d but got %d", i, want, samples[0])
+		}
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	recorded := intercom.Recorded()
+	if len(recorded) != 160 {
+		t.Fatalf("expected 160 samples to be played back but got %d", len(recorded))
+	}
+	if d := recorded[0] + 2000; d < -50 || d > 50 {
+		t.Errorf("expected -2000 to be played back but got %d", recorded[0])
+	}
+}
@@ -1,12 +1,17 @@
 package gatekeeper
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net"
 	"sync"
 	"time"
 
+	"github.com/halimath/raspidoor/daemon/internal/audio"
 	"github.com/halimath/raspidoor/daemon/internal/gpio"
+	"github.com/halimath/raspidoor/daemon/internal/mailbox"
+	"github.com/halimath/raspidoor/daemon/internal/sip"
 	"github.com/halimath/raspidoor/systemd/logging"
 )
 
@@ -23,11 +28,24 @@ type (
 	BellPushOptions struct {
 		Label string
 		Input gpio.DigitalInput
+
+		// Announcement contains the samples to play to callees answering a phone bell rung by this bell
+		// push; nil for none.
+		Announcement []int16
+
+		// Bells contains the labels of the bells rung by this bell push; all bells are rung if empty.
+		Bells []string
+
+		// Schedule defines when the bell push is quiet; optional.
+		Schedule Schedule
 	}
 
 	BellOptions struct {
 		Label  string
 		Ringer Ringer
+
+		// Schedule defines when the bell is quiet; optional.
+		Schedule Schedule
 	}
 
 	Options struct {
@@ -36,28 +54,109 @@ type (
 
 		BellPushes []BellPushOptions
 		Bells      []BellOptions
+
+		// Registration is the optional SIP registration to maintain while the gatekeeper is running.
+		Registration *sip.Registration
+
+		// Probe is the optional health probe of the SIP server to run while the gatekeeper is running.
+		Probe *sip.Probe
+
+		// ProbeLED defines whether to blink the status LED in an error pattern while the probe fails.
+		ProbeLED bool
+
+		// DoorOpener is the optional door opener used by the phone bells; it is closed with the gatekeeper.
+		DoorOpener *DoorOpener
+
+		// Mailbox is the optional mailbox recording a message if no callee of the phone bells rung answered;
+		// requires the intercom.
+		Mailbox *mailbox.Mailbox
+
+		// Intercom is the optional sound device used by the phone bells and incoming calls; it is closed with
+		// the gatekeeper.
+		Intercom audio.Device
+
+		// Inbound configures incoming calls; nil to not accept calls.
+		Inbound *InboundOptions
+
+		// Trace configures tracing of SIP messages; nil if tracing is not supported.
+		Trace *TraceOptions
+
+		// Location is the time zone schedules are evaluated in; time.Local if nil.
+		Location *time.Location
+
+		// Clock returns the current time schedules are evaluated at; time.Now if nil. Temporary state changes
+		// are restored by timers and thus always expire on the wall clock.
+		Clock func() time.Time
+
+		// StateStore persists the enabled state of bells and bell pushes across restarts; optional.
+		StateStore *StateStore
+	}
+
+	// InboundOptions defines how to accept incoming SIP calls. Callers are connected to the intercom and may
+	// enter DTMF commands prefixed with the PIN: PIN# opens the door and PIN*n# toggles bell n.
+	InboundOptions struct {
+		// Address is the UDP and TCP address to listen on, i.e. ":5060".
+		Address string
+
+		// Allow lists the networks calls are accepted from.
+		Allow []*net.IPNet
+
+		// PIN is the PIN callers must enter before each command; the door opener's PIN if empty. Commands are
+		// ignored if neither is set.
+		PIN string
+
+		// Tracer traces the messages sent and received; optional.
+		Tracer sip.Tracer
 	}
 
 	bellPush struct {
-		enabled bool
-		label   string
-		btn     gpio.DigitalInput
+		state
+		label        string
+		btn          gpio.DigitalInput
+		announcement []int16
+
+		// bells contains the indexes of the bells rung by this bell push; nil to ring all bells.
+		bells []int
+
+		schedule Schedule
 	}
 
 	bell struct {
-		enabled bool
-		label   string
-		ringer  Ringer
+		state
+		label    string
+		ringer   Ringer
+		history  *history
+		schedule Schedule
 	}
 
 	ItemInfo struct {
 		Label   string
 		Enabled bool
+
+		// Until is the time a temporary state change expires at and Remaining the duration left; both are zero
+		// if the state is permanent.
+		Until     time.Time
+		Remaining time.Duration
+
+		// Bells contains the labels of the bells rung by a bell push; empty if a bell push rings all bells
+		// and for bells.
+		Bells []string
+
+		Schedule Schedule
+
+		// Quiet reports whether the item is currently quiet due to its schedule.
+		Quiet bool
 	}
 
 	Info struct {
 		BellPushes []ItemInfo
 		Bells      []ItemInfo
+
+		// Registration contains the state of the SIP registration; nil if no registration is configured.
+		Registration *sip.RegistrationInfo
+
+		// Health contains the result of the SIP server's health probe; nil if no probe is configured.
+		Health *sip.ProbeInfo
 	}
 
 	Gatekeeper struct {
@@ -69,12 +168,54 @@ type (
 
 		logger logging.Logger
 
+		// ctx is passed to all bells when ringing; cancel aborts all calls in progress on Close.
+		ctx    context.Context
+		cancel context.CancelFunc
+
+		history history
+
+		// intercom is shared by phone bell calls, incoming calls and the mailbox; nil if not configured.
+		intercom *intercom
+
+		// recordings tracks the ring events the mailbox may record a message for so that Close can wait for
+		// them.
+		recordings sync.WaitGroup
+
+		trace     TraceState
+		traceLock sync.Mutex
+
+		// inbound is closed once the server accepting incoming calls has stopped.
+		inbound chan struct{}
+
+		// unhealthy is set while the probe reports the SIP server to be unreachable.
+		unhealthy bool
+
+		// stopErrorBlink stops blinking the status LED in the error pattern; nil if not blinking.
+		stopErrorBlink func()
+
+		// errorBlinkRetry starts the error pattern once the LED has finished blinking for a ring; nil if no
+		// retry is pending.
+		errorBlinkRetry *time.Timer
+		healthLock      sync.Mutex
+
+		// stored contains the persisted states including those of items no longer configured so that removing
+		// an item from the config temporarily does not lose its state.
+		stored storedStates
+
 		lock sync.RWMutex
 	}
 )
 
-func (b *bell) Ring(logger logging.Logger) {
-	b.ringer.Ring(logger)
+func (b *bell) Ring(ctx context.Context, evt RingEvent, logger logging.Logger) {
+	report := evt.report
+	evt.report = func(r CallRecord) {
+		r.Bell = b.label
+		b.history.add(r)
+		if report != nil {
+			report(r)
+		}
+	}
+	b.ringer.Ring(ctx, evt, logger)
 }
 
 func (b *bell) Close() error {
@@ -89,13 +230,34 @@ func New(opts Options, logger logging.Logger) (*Gatekeeper, error) {
 		bells:      make([]*bell, len(opts.Bells)),
 		bellPushes: make([]*bellPush, len(opts.BellPushes)),
 		logger:     logger,
+		intercom:   newIntercom(opts.Intercom),
+	}
+
+	g.ctx, g.cancel = context.WithCancel(context.Background())
+
+	for i, b := range opts.Bells {
+		g.bells[i] = &bell{
+			state:    state{enabled: true},
+			label:    b.Label,
+			ringer:   b.Ringer,
+			history:  &g.history,
+			schedule: b.Schedule,
+		}
 	}
 
 	for i, p := range opts.BellPushes {
+		bells, err := g.bellIndexes(p.Bells)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bells for bell push %s: %w", p.Label, err)
+		}
+
 		g.bellPushes[i] = &bellPush{
-			enabled: true,
-			label:   p.Label,
-			btn:     p.Input,
+			state:        state{enabled: true},
+			label:        p.Label,
+			btn:          p.Input,
+			announcement: p.Announcement,
+			bells:        bells,
+			schedule:     p.Schedule,
 		}
 		func(i int) {
 			g.bellPushes[i].btn.AddCallback(func(pressed bool) {
@@ -108,11 +270,19 @@ func New(opts Options, logger logging.Logger) (*Gatekeeper, error) {
 		}(i)
 	}
 
-	for i, b := range opts.Bells {
-		g.bells[i] = &bell{
-			enabled: true,
-			label:   b.Label,
-			ringer:  b.Ringer,
+	if opts.StateStore != nil {
+		// A broken state file must not keep the bells from ringing; all items stay enabled.
+		g.lock.Lock()
+		err := g.loadStates()
+		g.lock.Unlock()
+		if err != nil {
+			logger.Error("failed to load state: %s", err)
+		}
+	}
+
+	if opts.Trace != nil {
+		if err := g.SetTraceMode(opts.Trace.Mode); err != nil {
+			return nil, err
 		}
 	}
 
@@ -125,13 +295,54 @@ func (g *Gatekeeper) Start() {
 
 	g.logger.Info("Starting gatekeeper")
 	g.statusLED.On()
+
+	if g.opts.Registration != nil {
+		g.opts.Registration.Start()
+	}
+
+	if g.opts.Probe != nil {
+		g.opts.Probe.OnChange(g.healthChanged)
+		g.opts.Probe.Start()
+	}
+
+	if g.opts.Inbound != nil {
+		g.inbound = make(chan struct{})
+		go g.serveInbound(*g.opts.Inbound)
+	}
 }
 
 func (g *Gatekeeper) Close() error {
+	g.logger.Info("Shutting down gatekeeper")
+
+	// Abort all calls in progress; closing the bells below waits for them to finish.
+	g.cancel()
+
+	// Incoming calls must end before taking the lock since their commands take it to toggle bells.
+	g.lock.RLock()
+	inbound := g.inbound
+	g.lock.RUnlock()
+
+	if inbound != nil {
+		<-inbound
+	}
+
 	g.lock.Lock()
 	defer g.lock.Unlock()
 
-	g.logger.Info("Shutting down gatekeeper")
+	if g.opts.Registration != nil {
+		if err := g.opts.Registration.Close(); err != nil {
+			g.logger.Error("failed to unregister: %s", err)
+		}
+	}
+
+	if g.opts.Probe != nil {
+		g.opts.Probe.Close()
+	}
+
+	// The LED must not be switched on again by the error pattern once it has been turned off below.
+	g.healthLock.Lock()
+	g.stopErrorPattern()
+	g.healthLock.Unlock()
 
 	g.statusLED.Off()
 	if err := g.statusLED.Close(); err != nil {
@@ -139,71 +350,378 @@ func (g *Gatekeeper) Close() error {
 	}
 
 	for _, p := range g.bellPushes {
+		p.stop()
 		if err := p.btn.Close(); err != nil {
 			return err
 		}
 	}
 
 	for _, b := range g.bells {
+		b.stop()
 		if err := b.Close(); err != nil {
 			return err
 		}
 	}
 
+	g.recordings.Wait()
+
+	if g.opts.DoorOpener != nil {
+		if err := g.opts.DoorOpener.Close(); err != nil {
+			return err
+		}
+	}
+
+	if g.opts.Intercom != nil {
+		if err := g.opts.Intercom.Close(); err != nil {
+			return err
+		}
+	}
+
+	if g.opts.Trace != nil {
+		if err := g.SetTraceMode(TraceOff); err != nil {
+			return err
+		}
+	}
+
 	return g.logger.Close()
 }
 
 func (g *Gatekeeper) bellPushPressed(idx int) {
 	g.logger.Info("Pressed bell push %d: %s", idx, g.bellPushes[idx].label)
 
-	if !g.bellPushes[idx].enabled {
+	g.lock.RLock()
+	enabled, quiet := g.bellPushes[idx].enabled, g.bellPushes[idx].schedule.Quiet(g.now())
+	g.lock.RUnlock()
+
+	if !enabled {
 		g.logger.Info("Bell push %d disabled; not ringing", idx)
 		return
 	}
 
-	g.Ring()
+	if quiet {
+		g.logger.Info("Bell push %d quiet due to its schedule; not ringing", idx)
+		return
+	}
+
+	g.ring(RingEvent{
+		BellPush:     g.bellPushes[idx].label,
+		Announcement: g.bellPushes[idx].announcement,
+	}, g.bellPushes[idx])
 }
 
+// Ring rings all enabled bells without playing an announcement.
 func (g *Gatekeeper) Ring() {
+	g.ring(RingEvent{}, nil)
+}
+
+// ring rings the enabled bells routed to the bell push p; all enabled bells if p is nil. Once all phone bells
+// have finished, the mailbox records a message unless a callee answered.
+func (g *Gatekeeper) ring(evt RingEvent, p *bellPush) {
 	g.lock.RLock()
 	defer g.lock.RUnlock()
 
-	if err := g.statusLED.BlinkFor(g.opts.LEDDuration, 100*time.Millisecond); err != nil {
+	var o ringOutcome
+	evt.report = o.add
+	evt.calls = &sync.WaitGroup{}
+	evt.intercom = g.intercom
+
+	// The LED keeps blinking the error pattern while the SIP server is unreachable.
+	if err := g.statusLED.BlinkFor(g.opts.LEDDuration, 100*time.Millisecond); err != nil && !errors.Is(err, gpio.ErrAlreadyBlinking) {
 		g.logger.Error("failed to blink status led: %s", err)
 	}
 
-	for _, b := range g.bells {
-		if b.enabled {
-			b.Ring(g.logger)
+	now := g.now()
+	for i, b := range g.bells {
+		if !b.enabled || (p != nil && !p.rings(i)) {
+			continue
+		}
+
+		if b.schedule.Quiet(now) {
+			g.logger.Info("Bell %d quiet due to its schedule; not ringing", i)
+			continue
 		}
+
+		b.Ring(g.ctx, evt, g.logger)
 	}
+
+	if g.opts.Mailbox == nil || g.intercom == nil {
+		return
+	}
+
+	g.recordings.Add(1)
+	go func() {
+		defer g.recordings.Done()
+
+		evt.calls.Wait()
+		if o.leaveMessage() && g.ctx.Err() == nil {
+			g.record()
+		}
+	}()
+}
+
+// ringOutcome collects the outcome of the calls placed by phone bells for a single ring event.
+type ringOutcome struct {
+	lock       sync.Mutex
+	answered   bool
+	unanswered bool
 }
 
-func (g *Gatekeeper) SetBellPushState(index int, enabled bool) error {
+func (o *ringOutcome) add(r CallRecord) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	switch {
+	case r.Result == sip.ResultAnswered:
+		o.answered = true
+	case r.Error == nil:
+		o.unanswered = true
+	}
+}
+
+// leaveMessage reports whether the visitor may leave a message, i.e. some callee has been rung but none
+// answered.
+func (o *ringOutcome) leaveMessage() bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	return o.unanswered && !o.answered
+}
+
+// record records a message left by the visitor using the intercom unless the intercom is in use.
+func (g *Gatekeeper) record() {
+	dev := g.intercom.acquire()
+	if dev == nil {
+		g.logger.Info("Intercom is in use; not recording message")
+		return
+	}
+	defer g.intercom.release()
+
+	msg, err := g.opts.Mailbox.Record(g.ctx, dev)
+	if err != nil {
+		g.logger.Error("failed to record message: %s", err)
+		return
+	}
+	g.logger.Info("Recorded message %s (%s)", msg.ID, msg.Duration)
+}
+
+// now returns the current time in the location schedules are evaluated in.
+func (g *Gatekeeper) now() time.Time {
+	now := time.Now
+	if g.opts.Clock != nil {
+		now = g.opts.Clock
+	}
+
+	loc := time.Local
+	if g.opts.Location != nil {
+		loc = g.opts.Location
+	}
+
+	return now().In(loc)
+}
+
+// rings reports whether p rings the bell with the given index.
+func (p *bellPush) rings(bell int) bool {
+	if p.bells == nil {
+		return true
+	}
+
+	for _, b := range p.bells {
+		if b == bell {
+			return true
+		}
+	}
+	return false
+}
+
+// healthChanged is invoked by the probe whenever the SIP server's health changes.
+func (g *Gatekeeper) healthChanged(info sip.ProbeInfo) {
+	if info.Healthy {
+		g.logger.Info("SIP server %s is reachable (latency %s)", info.Server, info.Latency)
+	} else {
+		g.logger.Warn("SIP server %s is unreachable: %s", info.Server, info.Error)
+	}
+
+	if !g.opts.ProbeLED {
+		return
+	}
+
+	g.healthLock.Lock()
+	defer g.healthLock.Unlock()
+
+	g.unhealthy = !info.Healthy
+	if info.Healthy {
+		g.stopErrorPattern()
+	} else {
+		g.startErrorPattern()
+	}
+}
+
+// startErrorPattern blinks the status LED in the error pattern. If the LED is blinking for a ring, the error
+// pattern is started once the ring blink has ended. g.healthLock must be held.
+func (g *Gatekeeper) startErrorPattern() {
+	if g.stopErrorBlink != nil || g.errorBlinkRetry != nil {
+		return
+	}
+
+	// Two short flashes followed by a pause distinguish the error pattern from ringing.
+	stop, err := g.statusLED.BlinkPattern(150*time.Millisecond, 150*time.Millisecond, 150*time.Millisecond, time.Second)
+	switch {
+	case errors.Is(err, gpio.ErrAlreadyBlinking):
+		g.errorBlinkRetry = time.AfterFunc(g.opts.LEDDuration, func() {
+			g.healthLock.Lock()
+			defer g.healthLock.Unlock()
+
+			g.errorBlinkRetry = nil
+			if g.unhealthy && g.ctx.Err() == nil {
+				g.startErrorPattern()
+			}
+		})
+	case err != nil:
+		g.logger.Error("failed to blink status led: %s", err)
+	default:
+		g.stopErrorBlink = stop
+	}
+}
+
+// stopErrorPattern stops blinking the error pattern and waits for the LED's previous state to be restored.
+// g.healthLock must be held.
+func (g *Gatekeeper) stopErrorPattern() {
+	if g.errorBlinkRetry != nil {
+		g.errorBlinkRetry.Stop()
+		g.errorBlinkRetry = nil
+	}
+
+	if g.stopErrorBlink != nil {
+		g.stopErrorBlink()
+		g.stopErrorBlink = nil
+	}
+}
+
+// SetBellPushState enables or disables the bell push with the given index. If until is not zero, the change is
+// temporary and the previous state is restored at until. The state is changed even if persisting it fails.
+func (g *Gatekeeper) SetBellPushState(index int, enabled bool, until time.Time) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if index < 0 || index >= len(g.bellPushes) {
+		return fmt.Errorf("%w: bell push %d", ErrNotFound, index)
+	}
+
+	return g.setState(&g.bellPushes[index].state, fmt.Sprintf("bell push %d", index), enabled, until)
+}
+
+// SetBellPushBells changes the bells rung by the bell push with the given index. bells contains the bells'
+// labels; the bell push rings all bells if bells is empty.
+func (g *Gatekeeper) SetBellPushBells(index int, bells []string) error {
 	g.lock.Lock()
 	defer g.lock.Unlock()
 
-	if index >= len(g.bellPushes) {
+	if index < 0 || index >= len(g.bellPushes) {
 		return fmt.Errorf("%w: bell push %d", ErrNotFound, index)
 	}
 
-	g.bellPushes[index].enabled = enabled
+	indexes, err := g.bellIndexes(bells)
+	if err != nil {
+		return err
+	}
+
+	g.bellPushes[index].bells = indexes
+	return nil
+}
+
+// bellIndexes returns the indexes of the bells with the given labels; nil if labels is empty.
+func (g *Gatekeeper) bellIndexes(labels []string) ([]int, error) {
+	if len(labels) == 0 {
+		return nil, nil
+	}
+
+	indexes := make([]int, len(labels))
+	for i, l := range labels {
+		indexes[i] = g.bellIndex(l)
+		if indexes[i] < 0 {
+			return nil, fmt.Errorf("%w: bell %s", ErrNotFound, l)
+		}
+	}
+
+	return indexes, nil
+}
+
+// bellIndex returns the index of the bell with the given label; -1 if no such bell exists.
+func (g *Gatekeeper) bellIndex(label string) int {
+	for i, b := range g.bells {
+		if b.label == label {
+			return i
+		}
+	}
+	return -1
+}
+
+// SetBellState enables or disables the bell with the given index. If until is not zero, the change is
+// temporary and the previous state is restored at until. The state is changed even if persisting it fails.
+func (g *Gatekeeper) SetBellState(index int, enabled bool, until time.Time) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if index < 0 || index >= len(g.bells) {
+		return fmt.Errorf("%w: bell %d", ErrNotFound, index)
+	}
+
+	return g.setState(&g.bells[index].state, fmt.Sprintf("bell %d", index), enabled, until)
+}
+
+// SetBellPushSchedule replaces the schedule of the bell push with the given index.
+func (g *Gatekeeper) SetBellPushSchedule(index int, s Schedule) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if index < 0 || index >= len(g.bellPushes) {
+		return fmt.Errorf("%w: bell push %d", ErrNotFound, index)
+	}
 
+	g.bellPushes[index].schedule = s
 	return nil
 }
 
-func (g *Gatekeeper) SetBellState(index int, enabled bool) error {
+// SetBellSchedule replaces the schedule of the bell with the given index.
+func (g *Gatekeeper) SetBellSchedule(index int, s Schedule) error {
 	g.lock.Lock()
 	defer g.lock.Unlock()
 
-	if index >= len(g.bellPushes) {
+	if index < 0 || index >= len(g.bells) {
 		return fmt.Errorf("%w: bell %d", ErrNotFound, index)
 	}
 
-	g.bells[index].enabled = enabled
+	g.bells[index].schedule = s
 	return nil
 }
 
+// toggleBell enables the bell with the given index if it is disabled and vice versa. It returns the new
+// state.
+func (g *Gatekeeper) toggleBell(index int) (bool, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if index < 0 || index >= len(g.bells) {
+		return false, fmt.Errorf("%w: bell %d", ErrNotFound, index)
+	}
+
+	b := g.bells[index]
+	if err := g.setState(&b.state, fmt.Sprintf("bell %d", index), !b.enabled, time.Time{}); err != nil {
+		return b.enabled, err
+	}
+	return b.enabled, nil
+}
+
+// History returns the outcome of the most recent calls placed by phone bells, most recent first.
+func (g *Gatekeeper) History() []CallRecord {
+	return g.history.list()
+}
+
+// Mailbox returns the mailbox messages are recorded to; nil if no mailbox is configured.
+func (g *Gatekeeper) Mailbox() *mailbox.Mailbox {
+	return g.opts.Mailbox
+}
+
 func (g *Gatekeeper) Info() Info {
 	g.lock.RLock()
 	defer g.lock.RUnlock()
@@ -213,19 +731,43 @@ func (g *Gatekeeper) Info() Info {
 		BellPushes: make([]ItemInfo, len(g.bellPushes)),
 	}
 
+	now, wall := g.now(), time.Now()
+
 	for idx, p := range g.bellPushes {
 		i.BellPushes[idx] = ItemInfo{
-			Label:   p.label,
-			Enabled: p.enabled,
+			Label:     p.label,
+			Enabled:   p.enabled,
+			Until:     p.until,
+			Remaining: p.remaining(wall),
+			Schedule:  p.schedule,
+			Quiet:     p.schedule.Quiet(now),
+		}
+
+		for _, b := range p.bells {
+			i.BellPushes[idx].Bells = append(i.BellPushes[idx].Bells, g.bells[b].label)
 		}
 	}
 
 	for idx, b := range g.bells {
 		i.Bells[idx] = ItemInfo{
-			Label:   b.label,
-			Enabled: b.enabled,
+			Label:     b.label,
+			Enabled:   b.enabled,
+			Until:     b.until,
+			Remaining: b.remaining(wall),
+			Schedule:  b.schedule,
+			Quiet:     b.schedule.Quiet(now),
 		}
 	}
 
+	if g.opts.Registration != nil {
+		r := g.opts.Registration.Info()
+		i.Registration = &r
+	}
+
+	if g.opts.Probe != nil {
+		h := g.opts.Probe.Info()
+		i.Health = &h
+	}
+
 	return i
 }
@@ -0,0 +1,438 @@
+package gatekeeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/halimath/raspidoor/daemon/internal/audio"
+	"github.com/halimath/raspidoor/daemon/internal/gpio"
+	"github.com/halimath/raspidoor/daemon/internal/mailbox"
+	"github.com/halimath/raspidoor/daemon/internal/sip"
+	"github.com/halimath/raspidoor/systemd/logging"
+)
+
+type ringerMock struct {
+	rings []RingEvent
+}
+
+func (r *ringerMock) Ring(ctx context.Context, evt RingEvent, logger logging.Logger) {
+	r.rings = append(r.rings, evt)
+}
+
+func (r *ringerMock) Close() error { return nil }
+
+// stdoutLogger logs to stdout but is not closed with the gatekeeper so that stdout stays open for later tests.
+type stdoutLogger struct {
+	logging.Logger
+}
+
+func (stdoutLogger) Close() error { return nil }
+
+// callerMock reports result in the background like a phone bell.
+type callerMock struct {
+	result sip.Result
+	calls  sync.WaitGroup
+}
+
+func (c *callerMock) Ring(ctx context.Context, evt RingEvent, logger logging.Logger) {
+	c.calls.Add(1)
+	evt.calls.Add(1)
+	go func() {
+		defer c.calls.Done()
+		defer evt.calls.Done()
+
+		time.Sleep(10 * time.Millisecond)
+		evt.report(CallRecord{Result: c.result})
+	}()
+}
+
+func (c *callerMock) Close() error {
+	c.calls.Wait()
+	return nil
+}
+
+func TestGatekeeper_bellPushBells(t *testing.T) {
+	chime, phone := &ringerMock{}, &ringerMock{}
+
+	g, err := New(Options{
+		StatusLED:   gpio.NewNOOPDigitalOutput(),
+		LEDDuration: time.Millisecond,
+		BellPushes: []BellPushOptions{
+			{Label: "front door", Input: gpio.NewNOOPDigitalInput(), Bells: []string{"chime", "phone"}},
+			{Label: "garden gate", Input: gpio.NewNOOPDigitalInput(), Bells: []string{"phone"}},
+			{Label: "back door", Input: gpio.NewNOOPDigitalInput()},
+		},
+		Bells: []BellOptions{
+			{Label: "chime", Ringer: chime},
+			{Label: "phone", Ringer: phone},
+		},
+	}, logging.Stdout())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g.bellPushPressed(1)
+	if len(chime.rings) != 0 || len(phone.rings) != 1 {
+		t.Errorf("expected garden gate to ring phone only but got %d, %d", len(chime.rings), len(phone.rings))
+	}
+
+	g.bellPushPressed(0)
+	g.bellPushPressed(2)
+	if len(chime.rings) != 2 || len(phone.rings) != 3 {
+		t.Errorf("expected front and back door to ring both bells but got %d, %d", len(chime.rings), len(phone.rings))
+	}
+
+	if err := g.SetBellPushBells(1, []string{"chime"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SetBellPushBells(0, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.SetBellPushBells(1, []string{"doorbell"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+	if err := g.SetBellPushBells(3, nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+
+	g.bellPushPressed(1)
+	if len(chime.rings) != 3 || len(phone.rings) != 3 {
+		t.Errorf("expected garden gate to ring chime only but got %d, %d", len(chime.rings), len(phone.rings))
+	}
+
+	if diff := deep.Equal(g.Info().BellPushes, []ItemInfo{
+		{Label: "front door", Enabled: true},
+		{Label: "garden gate", Enabled: true, Bells: []string{"chime"}},
+		{Label: "back door", Enabled: true},
+	}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestNew_unknownBell(t *testing.T) {
+	_, err := New(Options{
+		StatusLED: gpio.NewNOOPDigitalOutput(),
+		BellPushes: []BellPushOptions{
+			{Label: "front door", Input: gpio.NewNOOPDigitalInput(), Bells: []string{"chime"}},
+		},
+	}, logging.Stdout())
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+}
+
+func TestGatekeeper_schedule(t *testing.T) {
+	chime, phone := &ringerMock{}, &ringerMock{}
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	quietHours, _ := ParseSchedule([]string{"20:00-07:00"})
+	weekdays, _ := ParseSchedule([]string{"Mon-Fri"})
+
+	// 2022-05-02 is a Monday; 19:30 UTC is 21:30 in Berlin.
+	now := time.Date(2022, 5, 2, 19, 30, 0, 0, time.UTC)
+
+	g, err := New(Options{
+		StatusLED:   gpio.NewNOOPDigitalOutput(),
+		LEDDuration: time.Millisecond,
+		BellPushes: []BellPushOptions{
+			{Label: "front door", Input: gpio.NewNOOPDigitalInput()},
+			{Label: "garden gate", Input: gpio.NewNOOPDigitalInput(), Schedule: weekdays},
+		},
+		Bells: []BellOptions{
+			{Label: "chime", Ringer: chime, Schedule: quietHours},
+			{Label: "phone", Ringer: phone},
+		},
+		Location: berlin,
+		Clock:    func() time.Time { return now },
+	}, logging.Stdout())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g.bellPushPressed(0)
+	g.bellPushPressed(1)
+	if len(chime.rings) != 0 || len(phone.rings) != 1 {
+		t.Errorf("expected phone to ring once but got %d, %d", len(chime.rings), len(phone.rings))
+	}
+
+	info := g.Info()
+	if !info.Bells[0].Quiet || info.Bells[1].Quiet || !info.BellPushes[1].Quiet {
+		t.Errorf("unexpected quiet state: %+v", info)
+	}
+
+	// 2022-05-07 is a Saturday.
+	now = time.Date(2022, 5, 7, 10, 0, 0, 0, time.UTC)
+	g.bellPushPressed(1)
+	if len(chime.rings) != 1 || len(phone.rings) != 2 {
+		t.Errorf("expected both bells to ring but got %d, %d", len(chime.rings), len(phone.rings))
+	}
+
+	if err := g.SetBellSchedule(1, weekdays); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SetBellPushSchedule(0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SetBellSchedule(2, nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+
+	if diff := deep.Equal(g.Info().Bells[1].Schedule.Strings(), []string{"Mon-Fri"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestGatekeeper_snooze(t *testing.T) {
+	g, err := New(Options{
+		StatusLED:   gpio.NewNOOPDigitalOutput(),
+		LEDDuration: time.Millisecond,
+		BellPushes: []BellPushOptions{
+			{Label: "front door", Input: gpio.NewNOOPDigitalInput()},
+		},
+		Bells: []BellOptions{
+			{Label: "chime", Ringer: &ringerMock{}},
+			{Label: "phone", Ringer: &ringerMock{}},
+		},
+	}, logging.Stdout())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.SetBellState(0, false, time.Now().Add(50*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replacing a temporary change keeps the state to restore.
+	if err := g.SetBellState(1, false, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SetBellState(1, false, time.Now().Add(50*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A permanent change cancels the restore.
+	if err := g.SetBellPushState(0, false, time.Now().Add(50*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SetBellPushState(0, false, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	info := g.Info()
+	if info.Bells[0].Enabled || info.Bells[0].Until.IsZero() || info.Bells[0].Remaining <= 0 {
+		t.Errorf("expected bell 0 to be disabled temporarily: %+v", info.Bells[0])
+	}
+	if !info.BellPushes[0].Until.IsZero() || info.BellPushes[0].Remaining != 0 {
+		t.Errorf("expected bell push 0 to be disabled permanently: %+v", info.BellPushes[0])
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	info = g.Info()
+	if !info.Bells[0].Enabled || !info.Bells[1].Enabled {
+		t.Errorf("expected bells to be enabled again: %+v", info.Bells)
+	}
+	if !info.Bells[0].Until.IsZero() || info.Bells[0].Remaining != 0 {
+		t.Errorf("expected bell 0 to be enabled permanently: %+v", info.Bells[0])
+	}
+	if info.BellPushes[0].Enabled {
+		t.Errorf("expected bell push 0 to stay disabled")
+	}
+
+	if err := g.SetBellState(0, false, time.Now().Add(-time.Minute)); err == nil {
+		t.Errorf("expected error for expiry in the past")
+	}
+	if err := g.SetBellPushState(1, false, time.Time{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+}
+
+func TestGatekeeper_snoozeWithClock(t *testing.T) {
+	// Temporary changes expire on the wall clock regardless of the clock schedules are evaluated at.
+	g, err := New(Options{
+		StatusLED:   gpio.NewNOOPDigitalOutput(),
+		LEDDuration: time.Millisecond,
+		Bells: []BellOptions{
+			{Label: "chime", Ringer: &ringerMock{}},
+		},
+		Clock: func() time.Time { return time.Date(2022, 5, 2, 19, 30, 0, 0, time.UTC) },
+	}, logging.Stdout())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.SetBellState(0, false, time.Now().Add(50*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if r := g.Info().Bells[0].Remaining; r <= 0 || r > 50*time.Millisecond {
+		t.Errorf("unexpected remaining time: %s", r)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	if !g.Info().Bells[0].Enabled {
+		t.Errorf("expected bell to be enabled again")
+	}
+}
+
+func TestGatekeeper_closeDuringIncomingCall(t *testing.T) {
+	g, err := New(Options{
+		StatusLED:   gpio.NewNOOPDigitalOutput(),
+		LEDDuration: time.Millisecond,
+		Bells: []BellOptions{
+			{Label: "chime", Ringer: &ringerMock{}},
+		},
+	}, stdoutLogger{logging.Stdout()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate an incoming call toggling a bell while the gatekeeper is shut down.
+	g.inbound = make(chan struct{})
+	go func() {
+		defer close(g.inbound)
+		<-g.ctx.Done()
+		g.toggleBell(0)
+	}()
+
+	closed := make(chan error)
+	go func() { closed <- g.Close() }()
+
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return")
+	}
+}
+
+// outputMock is a digital output safe for concurrent use which records writes after being closed.
+type outputMock struct {
+	lock         sync.Mutex
+	on           bool
+	closed       bool
+	writtenAfter bool
+}
+
+func (o *outputMock) State() bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.on
+}
+
+func (o *outputMock) set(on bool) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.on = on
+	o.writtenAfter = o.writtenAfter || o.closed
+	return nil
+}
+
+func (o *outputMock) On() error  { return o.set(true) }
+func (o *outputMock) Off() error { return o.set(false) }
+
+func (o *outputMock) Close() error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.closed = true
+	return nil
+}
+
+func TestGatekeeper_probeDuringRing(t *testing.T) {
+	led := &outputMock{}
+
+	g, err := New(Options{
+		StatusLED:   led,
+		LEDDuration: 50 * time.Millisecond,
+		ProbeLED:    true,
+	}, stdoutLogger{logging.Stdout()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Start()
+
+	// The probe fails while the LED is blinking for a ring.
+	g.Ring()
+	g.healthChanged(sip.ProbeInfo{Healthy: false, Error: errors.New("timeout")})
+
+	blinking := func() bool {
+		g.healthLock.Lock()
+		defer g.healthLock.Unlock()
+		return g.stopErrorBlink != nil
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for !blinking() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected error pattern to start once the ring blink has ended")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	led.lock.Lock()
+	defer led.lock.Unlock()
+	if led.on || led.writtenAfter {
+		t.Errorf("expected LED to be off and not written after close: on=%t, written=%t", led.on, led.writtenAfter)
+	}
+}
+
+func TestGatekeeper_mailbox(t *testing.T) {
+	tests := map[string]struct {
+		results  []sip.Result
+		messages int
+	}{
+		"not answered": {results: []sip.Result{sip.ResultNotAnswered, sip.ResultBusy}, messages: 1},
+		"answered":     {results: []sip.Result{sip.ResultNotAnswered, sip.ResultAnswered}, messages: 0},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mb := mailbox.New(t.TempDir(), nil, 40*time.Millisecond)
+
+			opts := Options{
+				StatusLED:   gpio.NewNOOPDigitalOutput(),
+				LEDDuration: time.Millisecond,
+				Mailbox:     mb,
+				Intercom:    audio.NewLoopback(),
+			}
+			for i, r := range test.results {
+				opts.Bells = append(opts.Bells, BellOptions{Label: fmt.Sprintf("phone %d", i), Ringer: &callerMock{result: r}})
+			}
+
+			g, err := New(opts, logging.Stdout())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			g.Ring()
+			g.recordings.Wait()
+
+			messages, err := mb.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(messages) != test.messages {
+				t.Errorf("expected %d messages but got %d", test.messages, len(messages))
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package gatekeeper
+
+import (
+	"sync"
+	"time"
+
+	"github.com/halimath/raspidoor/daemon/internal/sip"
+)
+
+// historySize is the number of calls kept in the history.
+const historySize = 100
+
+type (
+	// CallRecord describes the outcome of ringing a phone bell.
+	CallRecord struct {
+		// Time is the time the bell started ringing.
+		Time time.Time
+
+		// Bell is the label of the phone bell.
+		Bell string
+
+		// BellPush is the label of the bell push that has been pressed; empty if rung via the controller.
+		BellPush string
+
+		Result sip.Result
+
+		// Callee is the callee that answered the call, which may be a redirect target; the zero URI if no
+		// callee answered.
+		Callee sip.URI
+
+		// Error is the reason why ringing failed; nil unless Result is sip.ResultFailed.
+		Error error
+	}
+
+	// history keeps the most recent call records.
+	history struct {
+		lock    sync.Mutex
+		records []CallRecord
+	}
+)
+
+func (h *history) add(r CallRecord) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.records = append(h.records, r)
+	if len(h.records) > historySize {
+		h.records = h.records[len(h.records)-historySize:]
+	}
+}
+
+// list returns the records, most recent first.
+func (h *history) list() []CallRecord {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	l := make([]CallRecord, len(h.records))
+	for i, r := range h.records {
+		l[len(l)-1-i] = r
+	}
+	return l
+}
@@ -0,0 +1,148 @@
+package gatekeeper
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/halimath/raspidoor/daemon/internal/sip"
+	"github.com/halimath/raspidoor/systemd/logging"
+)
+
+// serveInbound accepts incoming calls until the gatekeeper is closed.
+func (g *Gatekeeper) serveInbound(opts InboundOptions) {
+	defer close(g.inbound)
+
+	s := &sip.Server{
+		Accept:  sip.AllowNetworks(opts.Allow...),
+		Handler: g.answer,
+		Tracer:  opts.Tracer,
+	}
+
+	g.logger.Info("Accepting incoming calls on %s", opts.Address)
+	if err := s.ListenAndServe(g.ctx, opts.Address); err != nil {
+		g.logger.Error("failed to accept incoming calls: %s", err)
+	}
+}
+
+// answer implements sip.AnswerHandler for incoming calls.
+func (g *Gatekeeper) answer(ctx context.Context, call *sip.Call) error {
+	g.logger.Info("Answered incoming call from %s", call.Peer)
+
+	i := &interpreter{
+		toggleBell: g.toggleBell,
+		opener:     g.opts.DoorOpener,
+		logger:     g.logger,
+	}
+
+	pin := g.opts.Inbound.PIN
+	if pin == "" && g.opts.DoorOpener != nil {
+		pin = g.opts.DoorOpener.pin
+	}
+	if pin != "" {
+		i.pin = &pinEntry{pin: pin}
+	}
+
+	c := &conversation{
+		intercom: g.intercom,
+		command:  i.command,
+		logger:   g.logger,
+	}
+
+	if err := c.handle(ctx, call); err != nil {
+		g.logger.Error("failed to handle incoming call from %s: %s", call.Peer, err)
+		return err
+	}
+
+	g.logger.Info("Incoming call from %s ended", call.Peer)
+	return nil
+}
+
+// interpreter interprets the DTMF digits entered by callers of incoming calls. Each command starts with the
+// PIN and is terminated by #: PIN# opens the door and PIN*n# toggles bell n. The call is hung up after
+// maxPINAttempts wrong PINs. Unlike phone bell calls, incoming calls are kept open after the door has been
+// opened.
+type interpreter struct {
+	toggleBell func(index int) (bool, error)
+	opener     *DoorOpener
+
+	// pin checks the PIN; nil if no PIN is configured in which case all commands are ignored.
+	pin *pinEntry
+
+	logger logging.Logger
+}
+
+// command implements conversation.command.
+func (i *interpreter) command(peer sip.URI, d rune) (bool, error) {
+	if i.pin == nil {
+		return false, nil
+	}
+
+	entry, ok := i.pin.add(d)
+	if !ok {
+		return false, nil
+	}
+
+	// The PIN has a fixed length; the command follows it.
+	pin, cmd := entry, ""
+	if len(entry) > len(i.pin.pin) {
+		pin, cmd = entry[:len(i.pin.pin)], entry[len(i.pin.pin):]
+	}
+
+	match, err := i.pin.check(pin)
+	if err != nil {
+		i.logger.Warn("%s entered %d wrong PINs; hanging up", peer, maxPINAttempts)
+		return true, nil
+	}
+	if !match {
+		i.logger.Warn("%s entered a wrong PIN", peer)
+		return false, nil
+	}
+
+	switch {
+	case cmd == "":
+		i.open(peer)
+	case strings.HasPrefix(cmd, "*") && len(cmd) > 1:
+		i.toggle(peer, cmd[1:])
+	default:
+		i.logger.Warn("%s entered unknown command %q", peer, cmd)
+	}
+
+	return false, nil
+}
+
+// open opens the door.
+func (i *interpreter) open(peer sip.URI) {
+	if i.opener == nil {
+		i.logger.Warn("%s tried to open the door but no door opener is configured", peer)
+		return
+	}
+
+	i.logger.Info("Door opened by %s at %s", peer, time.Now().Format(time.RFC3339))
+	if err := i.opener.Open(); err != nil {
+		i.logger.Error("failed to open door: %s", err)
+	}
+}
+
+// toggle toggles the bell with the given index.
+func (i *interpreter) toggle(peer sip.URI, index string) {
+	idx, err := strconv.Atoi(index)
+	if err != nil {
+		i.logger.Warn("%s entered invalid bell %q", peer, index)
+		return
+	}
+
+	enabled, err := i.toggleBell(idx)
+	if errors.Is(err, ErrNotFound) {
+		i.logger.Warn("%s tried to toggle unknown bell %d", peer, idx)
+		return
+	}
+	if err != nil {
+		i.logger.Error("failed to toggle bell %d: %s", idx, err)
+		return
+	}
+
+	i.logger.Info("Bell %d %s by %s", idx, formatEnabled(enabled), peer)
+}
@@ -0,0 +1,97 @@
+package gatekeeper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/halimath/raspidoor/daemon/internal/gpio"
+	"github.com/halimath/raspidoor/daemon/internal/sip"
+	"github.com/halimath/raspidoor/systemd/logging"
+)
+
+func TestInterpreter(t *testing.T) {
+	out := gpio.NewNOOPDigitalOutput()
+	opener := NewDoorOpener(out, time.Hour, "12")
+
+	var toggled []int
+	i := &interpreter{
+		toggleBell: func(index int) (bool, error) {
+			toggled = append(toggled, index)
+			return true, nil
+		},
+		opener: opener,
+		pin:    &pinEntry{pin: opener.pin},
+		logger: logging.Stdout(),
+	}
+
+	peer := sip.NewURI("sip", "alice", "example.com", 5060)
+
+	// Toggling a bell requires the PIN; the wrong PIN is counted.
+	for _, d := range "*1#12*1#12*3#12*x#" {
+		if done, err := i.command(peer, d); done || err != nil {
+			t.Fatalf("unexpected result: %v, %v", done, err)
+		}
+	}
+
+	if len(toggled) != 2 || toggled[0] != 1 || toggled[1] != 3 {
+		t.Errorf("expected bells 1 and 3 to be toggled but got %v", toggled)
+	}
+
+	if out.State() {
+		t.Fatal("expected door to be closed")
+	}
+
+	for _, d := range "12#" {
+		i.command(peer, d)
+	}
+
+	if !out.State() {
+		t.Error("expected door to be opened")
+	}
+}
+
+func TestInterpreter_wrongPINs(t *testing.T) {
+	var toggled []int
+	i := &interpreter{
+		toggleBell: func(index int) (bool, error) {
+			toggled = append(toggled, index)
+			return true, nil
+		},
+		pin:    &pinEntry{pin: "12"},
+		logger: logging.Stdout(),
+	}
+
+	peer := sip.NewURI("sip", "mallory", "example.com", 5060)
+
+	var done bool
+	for _, d := range "11*1#13*1#14*1#" {
+		var err error
+		if done, err = i.command(peer, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !done {
+		t.Error("expected call to be hung up after three wrong PINs")
+	}
+
+	if len(toggled) != 0 {
+		t.Errorf("expected no bell to be toggled but got %v", toggled)
+	}
+}
+
+func TestInterpreter_noPIN(t *testing.T) {
+	i := &interpreter{
+		toggleBell: func(index int) (bool, error) {
+			t.Errorf("unexpected toggle of bell %d", index)
+			return true, nil
+		},
+		logger: logging.Stdout(),
+	}
+
+	for _, d := range "*1#" {
+		if done, err := i.command(sip.NewURI("sip", "alice", "example.com", 5060), d); done || err != nil {
+			t.Fatalf("unexpected result: %v, %v", done, err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package gatekeeper
+
+import (
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"github.com/halimath/raspidoor/daemon/internal/gpio"
+	"github.com/halimath/raspidoor/daemon/internal/sip"
+	"github.com/halimath/raspidoor/systemd/logging"
+)
+
+// DoorOpener opens the door by pulsing a relay when the callee of a phone bell or the caller of an incoming
+// call enters the PIN followed by #.
+type DoorOpener struct {
+	out gpio.DigitalOutput
+	dur time.Duration
+	pin string
+}
+
+// NewDoorOpener creates a DoorOpener switching out on for dur once pin has been entered. pin is a sequence
+// of DTMF digits (0-9, *, A-D); # terminates the entry and must not be part of pin.
+func NewDoorOpener(out gpio.DigitalOutput, dur time.Duration, pin string) *DoorOpener {
+	return &DoorOpener{
+		out: out,
+		dur: dur,
+		pin: pin,
+	}
+}
+
+// Open opens the door.
+func (o *DoorOpener) Open() error {
+	return gpio.OnFor(o.out, o.dur)
+}
+
+// pinCommand returns a conversation command opening the door and hanging up once the PIN has been entered.
+// The call is hung up after maxPINAttempts wrong PINs.
+func (o *DoorOpener) pinCommand(logger logging.Logger) func(peer sip.URI, d rune) (bool, error) {
+	e := pinEntry{pin: o.pin}
+	return func(peer sip.URI, d rune) (bool, error) {
+		entry, ok := e.add(d)
+		if !ok {
+			return false, nil
+		}
+
+		match, err := e.check(entry)
+		if err != nil {
+			logger.Warn("%s entered %d wrong PINs; hanging up", peer, maxPINAttempts)
+			return true, nil
+		}
+		if !match {
+			logger.Warn("%s entered a wrong PIN", peer)
+			return false, nil
+		}
+
+		logger.Info("Door opened by %s at %s", peer, time.Now().Format(time.RFC3339))
+		return true, o.Open()
+	}
+}
+
+func (o *DoorOpener) Close() error {
+	return o.out.Close()
+}
+
+const (
+	// maxPINAttempts is the number of wrong PINs after which a call is hung up.
+	maxPINAttempts = 3
+
+	// maxEntryLength limits the number of digits of a single entry; further digits are dropped.
+	maxEntryLength = 32
+)
+
+var errTooManyPINAttempts = errors.New("too many wrong PINs")
+
+// pinEntry collects the DTMF digits entered by a peer into entries terminated by # and checks them against
+// the PIN. Wrong PINs are counted for the whole call.
+type pinEntry struct {
+	pin      string
+	entered  []rune
+	attempts int
+}
+
+// add adds d to the current entry. Once d is #, the entry is complete and returned with ok set to true.
+func (e *pinEntry) add(d rune) (entry string, ok bool) {
+	if d != '#' {
+		if len(e.entered) < maxEntryLength {
+			e.entered = append(e.entered, d)
+		}
+		return "", false
+	}
+
+	entry = string(e.entered)
+	e.entered = nil
+	return entry, true
+}
+
+// check reports whether pin matches the PIN. It returns errTooManyPINAttempts once maxPINAttempts wrong PINs
+// have been checked.
+func (e *pinEntry) check(pin string) (bool, error) {
+	if e.attempts >= maxPINAttempts {
+		return false, errTooManyPINAttempts
+	}
+
+	if subtle.ConstantTimeCompare([]byte(pin), []byte(e.pin)) == 1 {
+		return true, nil
+	}
+
+	e.attempts++
+	if e.attempts >= maxPINAttempts {
+		return false, errTooManyPINAttempts
+	}
+	return false, nil
+}
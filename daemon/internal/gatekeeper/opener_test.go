@@ -0,0 +1,199 @@
+package gatekeeper
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/halimath/raspidoor/daemon/internal/gpio"
+	"github.com/halimath/raspidoor/daemon/internal/rtp"
+	"github.com/halimath/raspidoor/daemon/internal/sdp"
+	"github.com/halimath/raspidoor/daemon/internal/sip"
+	"github.com/halimath/raspidoor/systemd/logging"
+)
+
+func TestDoorOpener_info(t *testing.T) {
+	out := gpio.NewNOOPDigitalOutput()
+	o := NewDoorOpener(out, time.Hour, "1234")
+
+	dtmf := make(chan rune, 10)
+	for _, d := range "12#1234#" {
+		dtmf <- d
+	}
+
+	call := &sip.Call{
+		Media: &sdp.Negotiation{Codec: sdp.PCMU, Remote: listenRTP(t).LocalAddr().(*net.UDPAddr)},
+		RTP:   listenRTP(t),
+		DTMF:  dtmf,
+	}
+
+	c := &conversation{command: o.pinCommand(logging.Stdout()), logger: logging.Stdout()}
+	if err := c.handle(context.Background(), call); err != nil {
+		t.Fatal(err)
+	}
+
+	if !out.State() {
+		t.Error("expected door to be opened")
+	}
+
+	if len(dtmf) != 0 {
+		t.Errorf("expected all digits to be consumed but got %d left", len(dtmf))
+	}
+}
+
+func TestDoorOpener_telephoneEvent(t *testing.T) {
+	con, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	phone, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer phone.Close()
+
+	out := gpio.NewNOOPDigitalOutput()
+	o := NewDoorOpener(out, time.Hour, "5")
+
+	call := &sip.Call{
+		Media: &sdp.Negotiation{
+			Codec:          sdp.PCMU,
+			TelephoneEvent: &sdp.TelephoneEvent,
+			Remote:         phone.LocalAddr().(*net.UDPAddr),
+		},
+		RTP:  con,
+		DTMF: make(chan rune),
+	}
+
+	// 5 followed by #
+	for i, event := range []uint8{5, 11} {
+		p := rtp.Packet{
+			PayloadType: sdp.TelephoneEvent.PayloadType,
+			Timestamp:   uint32(i * 800),
+			Payload:     rtp.TelephoneEvent{Event: event, End: true, Duration: 320}.Marshal(),
+		}
+		if _, err := phone.WriteTo(p.Marshal(), con.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// The announcement keeps playing while waiting for the PIN.
+	c := &conversation{announcement: make([]int16, 8000), command: o.pinCommand(logging.Stdout()), logger: logging.Stdout()}
+	if err := c.handle(ctx, call); err != nil {
+		t.Fatal(err)
+	}
+
+	if !out.State() {
+		t.Error("expected door to be opened")
+	}
+}
+
+func TestDoorOpener_hangUp(t *testing.T) {
+	out := gpio.NewNOOPDigitalOutput()
+	o := NewDoorOpener(out, time.Hour, "1234")
+
+	dtmf := make(chan rune, 10)
+	for _, d := range "1234" {
+		dtmf <- d
+	}
+
+	call := &sip.Call{
+		Media: &sdp.Negotiation{Codec: sdp.PCMU, Remote: listenRTP(t).LocalAddr().(*net.UDPAddr)},
+		RTP:   listenRTP(t),
+		DTMF:  dtmf,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	c := &conversation{command: o.pinCommand(logging.Stdout()), logger: logging.Stdout()}
+	if err := c.handle(ctx, call); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.State() {
+		t.Error("expected door to remain closed")
+	}
+}
+
+func TestDoorOpener_wrongPINs(t *testing.T) {
+	out := gpio.NewNOOPDigitalOutput()
+	o := NewDoorOpener(out, time.Hour, "1234")
+
+	dtmf := make(chan rune, 20)
+	for _, d := range "1111#2222#3333#1234#" {
+		dtmf <- d
+	}
+
+	call := &sip.Call{
+		Media: &sdp.Negotiation{Codec: sdp.PCMU, Remote: listenRTP(t).LocalAddr().(*net.UDPAddr)},
+		RTP:   listenRTP(t),
+		DTMF:  dtmf,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	c := &conversation{command: o.pinCommand(logging.Stdout()), logger: logging.Stdout()}
+	if err := c.handle(ctx, call); err != nil {
+		t.Fatal(err)
+	}
+
+	if ctx.Err() != nil {
+		t.Error("expected call to be hung up after three wrong PINs")
+	}
+
+	if out.State() {
+		t.Error("expected door to remain closed")
+	}
+}
+
+func TestPINEntry(t *testing.T) {
+	e := pinEntry{pin: "1*2"}
+
+	var entries []string
+	for _, d := range "1*2#12#" {
+		if entry, ok := e.add(d); ok {
+			entries = append(entries, entry)
+		}
+	}
+
+	if len(entries) != 2 || entries[0] != "1*2" || entries[1] != "12" {
+		t.Fatalf("unexpected entries: %q", entries)
+	}
+
+	if ok, err := e.check(entries[0]); !ok || err != nil {
+		t.Errorf("expected PIN to match: %v, %v", ok, err)
+	}
+
+	for i := 1; i < maxPINAttempts; i++ {
+		if ok, err := e.check(entries[1]); ok || err != nil {
+			t.Errorf("attempt %d: unexpected result: %v, %v", i, ok, err)
+		}
+	}
+
+	if _, err := e.check(entries[1]); err != errTooManyPINAttempts {
+		t.Errorf("expected errTooManyPINAttempts but got %v", err)
+	}
+
+	// Even the correct PIN is rejected once the attempts are exhausted.
+	if ok, err := e.check(entries[0]); ok || err != errTooManyPINAttempts {
+		t.Errorf("expected PIN to be rejected: %v, %v", ok, err)
+	}
+}
+
+func listenRTP(t *testing.T) net.PacketConn {
+	con, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { con.Close() })
+
+	return con
+}
@@ -0,0 +1,257 @@
+package gatekeeper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// weekdayNames contains the abbreviated names of the weekdays indexed by time.Weekday.
+var weekdayNames = [...]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+type (
+	// Rule defines a time window during which a bell or bell push is quiet, i.e. does not ring. A window
+	// spanning midnight (End before Start) belongs to the weekday it starts on.
+	Rule struct {
+		// Weekdays contains the days the rule applies to; all days if empty.
+		Weekdays []time.Weekday
+
+		// Start and End define the window as offsets from midnight; End is exclusive. The rule applies to the
+		// whole day if both are zero.
+		Start time.Duration
+		End   time.Duration
+	}
+
+	// Schedule contains the rules defining when a bell or bell push is quiet. The zero value is never quiet.
+	Schedule []Rule
+)
+
+// ParseRule parses a rule given as weekdays, a time window or both, i.e. "Mon-Fri 20:00-07:00", "Sat,Sun" or
+// "22:00-06:00". Weekdays are given as a comma separated list of abbreviated names or ranges of names.
+func ParseRule(s string) (Rule, error) {
+	var r Rule
+
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return Rule{}, fmt.Errorf("invalid rule: %q", s)
+	}
+
+	if len(fields) == 2 || !strings.Contains(fields[0], ":") {
+		days, err := parseWeekdays(fields[0])
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid rule: %q: %w", s, err)
+		}
+		r.Weekdays = days
+		fields = fields[1:]
+	}
+
+	if len(fields) == 1 {
+		start, end, ok := strings.Cut(fields[0], "-")
+		if !ok {
+			return Rule{}, fmt.Errorf("invalid rule: %q: missing end of time window", s)
+		}
+
+		var err error
+		if r.Start, err = parseTimeOfDay(start); err != nil {
+			return Rule{}, fmt.Errorf("invalid rule: %q: %w", s, err)
+		}
+		if r.End, err = parseTimeOfDay(end); err != nil {
+			return Rule{}, fmt.Errorf("invalid rule: %q: %w", s, err)
+		}
+		if r.Start == r.End {
+			return Rule{}, fmt.Errorf("invalid rule: %q: empty time window", s)
+		}
+	}
+
+	return r, nil
+}
+
+// ParseSchedule parses each of rules using ParseRule.
+func ParseSchedule(rules []string) (Schedule, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	s := make(Schedule, len(rules))
+	for i, r := range rules {
+		var err error
+		if s[i], err = ParseRule(r); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func parseWeekdays(s string) ([]time.Weekday, error) {
+	var days []time.Weekday
+
+	for _, part := range strings.Split(s, ",") {
+		first, last, isRange := strings.Cut(part, "-")
+
+		from, err := parseWeekday(first)
+		if err != nil {
+			return nil, err
+		}
+
+		to := from
+		if isRange {
+			if to, err = parseWeekday(last); err != nil {
+				return nil, err
+			}
+		}
+
+		// Ranges may wrap around the end of the week, i.e. Fri-Mon.
+		for d := from; ; d = (d + 1) % 7 {
+			days = append(days, d)
+			if d == to {
+				break
+			}
+		}
+	}
+
+	return days, nil
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	for d, n := range weekdayNames {
+		if strings.EqualFold(s, n) {
+			return time.Weekday(d), nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid weekday: %q", s)
+}
+
+// parseTimeOfDay parses a time of day given as HH:MM; 24:00 denotes midnight at the end of the day.
+func parseTimeOfDay(s string) (time.Duration, error) {
+	h, m, ok := strings.Cut(s, ":")
+	if !ok || len(h) == 0 || len(h) > 2 || len(m) != 2 {
+		return 0, fmt.Errorf("invalid time of day: %q", s)
+	}
+
+	hours, err := strconv.Atoi(h)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day: %q", s)
+	}
+
+	minutes, err := strconv.Atoi(m)
+	if err != nil || minutes > 59 || hours > 24 || (hours == 24 && minutes > 0) || hours < 0 || minutes < 0 {
+		return 0, fmt.Errorf("invalid time of day: %q", s)
+	}
+
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+// Quiet reports whether r applies at t. t must be given in the location the rule is defined for.
+func (r Rule) Quiet(t time.Time) bool {
+	hour, minute, second := t.Clock()
+	tod := time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute + time.Duration(second)*time.Second
+	day := t.Weekday()
+
+	switch {
+	case r.Start == 0 && r.End == 0:
+		return r.appliesTo(day)
+	case r.Start < r.End:
+		return r.appliesTo(day) && tod >= r.Start && tod < r.End
+	default:
+		return (r.appliesTo(day) && tod >= r.Start) || (r.appliesTo((day+6)%7) && tod < r.End)
+	}
+}
+
+func (r Rule) appliesTo(day time.Weekday) bool {
+	if len(r.Weekdays) == 0 {
+		return true
+	}
+
+	for _, d := range r.Weekdays {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns r in the format accepted by ParseRule.
+func (r Rule) String() string {
+	var parts []string
+
+	if days := r.formatWeekdays(); days != "" {
+		parts = append(parts, days)
+	}
+
+	if r.Start != 0 || r.End != 0 {
+		parts = append(parts, formatTimeOfDay(r.Start)+"-"+formatTimeOfDay(r.End))
+	}
+
+	if len(parts) == 0 {
+		return "Mon-Sun"
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatWeekdays formats the rule's weekdays starting with Monday; consecutive days are joined to ranges.
+// It returns the empty string if the rule applies to all days.
+func (r Rule) formatWeekdays() string {
+	var days [7]bool
+	for _, d := range r.Weekdays {
+		days[(d+6)%7] = true
+	}
+
+	if days == [7]bool{true, true, true, true, true, true, true} {
+		return ""
+	}
+
+	var parts []string
+	for i := 0; i < 7; i++ {
+		if !days[i] {
+			continue
+		}
+
+		j := i
+		for j+1 < 7 && days[j+1] {
+			j++
+		}
+
+		first, last := weekdayNames[(i+1)%7], weekdayNames[(j+1)%7]
+		switch j - i {
+		case 0:
+			parts = append(parts, first)
+		case 1:
+			parts = append(parts, first, last)
+		default:
+			parts = append(parts, first+"-"+last)
+		}
+		i = j
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func formatTimeOfDay(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d/time.Hour), int(d%time.Hour/time.Minute))
+}
+
+// Quiet reports whether any of the rules applies at t.
+func (s Schedule) Quiet(t time.Time) bool {
+	for _, r := range s {
+		if r.Quiet(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Strings returns the rules formatted using Rule.String.
+func (s Schedule) Strings() []string {
+	if len(s) == 0 {
+		return nil
+	}
+
+	l := make([]string, len(s))
+	for i, r := range s {
+		l[i] = r.String()
+	}
+	return l
+}
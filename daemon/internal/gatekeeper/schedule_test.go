@@ -0,0 +1,109 @@
+package gatekeeper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := map[string]Rule{
+		"Mon-Fri 20:00-07:00": {
+			Weekdays: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+			Start:    20 * time.Hour,
+			End:      7 * time.Hour,
+		},
+		"sat,SUN": {
+			Weekdays: []time.Weekday{time.Saturday, time.Sunday},
+		},
+		"Fri-Mon": {
+			Weekdays: []time.Weekday{time.Friday, time.Saturday, time.Sunday, time.Monday},
+		},
+		"12:30-24:00": {
+			Start: 12*time.Hour + 30*time.Minute,
+			End:   24 * time.Hour,
+		},
+	}
+
+	for in, want := range tests {
+		t.Run(in, func(t *testing.T) {
+			got, err := ParseRule(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := deep.Equal(got, want); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestParseRule_invalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"Mon-Fri 20:00-07:00 extra",
+		"Monday",
+		"Mon-Fri 20:00",
+		"20:00-20:00",
+		"24:30-07:00",
+		"7-8",
+		"Mon 20:60-21:00",
+	} {
+		if _, err := ParseRule(in); err == nil {
+			t.Errorf("expected error for %q", in)
+		}
+	}
+}
+
+func TestRule_String(t *testing.T) {
+	tests := map[string]string{
+		"Mon-Fri 20:00-07:00":    "Mon-Fri 20:00-07:00",
+		"Sun,Sat":                "Sat,Sun",
+		"Fri-Mon 9:00-10:00":     "Mon,Fri-Sun 09:00-10:00",
+		"Mon,Wed,Thu,Fri,Sat":    "Mon,Wed-Sat",
+		"Mon-Sun 22:00-06:00":    "22:00-06:00",
+		"Tue-Mon":                "Mon-Sun",
+		"Mon,Tue 00:00-24:00":    "Mon,Tue 00:00-24:00",
+		"Wed,Thu,Sun 8:15-12:45": "Wed,Thu,Sun 08:15-12:45",
+	}
+
+	for in, want := range tests {
+		r, err := ParseRule(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.String(); got != want {
+			t.Errorf("%q: expected %q but got %q", in, want, got)
+		}
+	}
+}
+
+func TestSchedule_Quiet(t *testing.T) {
+	s, err := ParseSchedule([]string{"Mon-Fri 20:00-07:00", "Sun"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]bool{
+		"2022-05-02T19:59:59Z": false, // Monday
+		"2022-05-02T20:00:00Z": true,
+		"2022-05-03T06:59:59Z": true, // Tuesday morning after Monday night
+		"2022-05-03T07:00:00Z": false,
+		"2022-05-06T23:00:00Z": true, // Friday night
+		"2022-05-07T06:00:00Z": true, // Saturday morning after Friday night
+		"2022-05-07T21:00:00Z": false,
+		"2022-05-08T12:00:00Z": true,  // Sunday
+		"2022-05-09T06:00:00Z": false, // Monday morning after Sunday
+	}
+
+	for in, want := range tests {
+		now, err := time.Parse(time.RFC3339, in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.Quiet(now); got != want {
+			t.Errorf("%s: expected %v but got %v", in, want, got)
+		}
+	}
+}
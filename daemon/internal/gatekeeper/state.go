@@ -0,0 +1,98 @@
+package gatekeeper
+
+import (
+	"fmt"
+	"time"
+)
+
+// state is the enabled state of a bell or bell push. The state may be changed temporarily in which case the
+// previous state is restored once the change expires.
+type state struct {
+	enabled bool
+
+	// until is the time the temporary change expires at; zero if the state is permanent.
+	until time.Time
+
+	// previous is the state restored once the temporary change expires.
+	previous bool
+
+	// timer restores the previous state; nil if the state is permanent.
+	timer *time.Timer
+}
+
+// setState changes s to enabled. If until is not zero, the change is temporary and the state before the change
+// is restored at until. A temporary change replacing another one keeps the state to restore; a permanent
+// change cancels any pending restore. The new state is persisted if a state store is configured. g.lock must
+// be held.
+func (g *Gatekeeper) setState(s *state, name string, enabled bool, until time.Time) error {
+	var d time.Duration
+	if !until.IsZero() {
+		d = time.Until(until)
+		if d <= 0 {
+			return fmt.Errorf("invalid expiry for %s: %s is not in the future", name, until.Format(time.RFC3339))
+		}
+	}
+
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	} else {
+		s.previous = s.enabled
+	}
+
+	s.enabled = enabled
+	s.until = until
+
+	if !until.IsZero() {
+		g.restoreAfter(s, name, d)
+	}
+
+	return g.saveStates()
+}
+
+// restoreAfter restores the previous state of s after d. g.lock must be held.
+func (g *Gatekeeper) restoreAfter(s *state, name string, d time.Duration) {
+	var t *time.Timer
+	t = time.AfterFunc(d, func() {
+		g.lock.Lock()
+		defer g.lock.Unlock()
+
+		// The timer may have fired while being replaced by another change.
+		if s.timer != t {
+			return
+		}
+
+		g.logger.Info("Restoring state of %s: %s", name, formatEnabled(s.previous))
+		s.enabled = s.previous
+		s.until = time.Time{}
+		s.timer = nil
+
+		if err := g.saveStates(); err != nil {
+			g.logger.Error("%s", err)
+		}
+	})
+	s.timer = t
+}
+
+// stop cancels a pending restore without changing the state.
+func (s *state) stop() {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+}
+
+// remaining returns the duration until the temporary change expires; 0 if the state is permanent.
+func (s *state) remaining(now time.Time) time.Duration {
+	if s.until.IsZero() || !s.until.After(now) {
+		return 0
+	}
+	return s.until.Sub(now)
+}
+
+func formatEnabled(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
@@ -0,0 +1,169 @@
+package gatekeeper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type (
+	// StateStore persists the enabled state of bells and bell pushes in a JSON file. States are keyed by label
+	// so that reordering bells or bell pushes in the config keeps their states.
+	StateStore struct {
+		path string
+	}
+
+	// storedState is the persisted state of a single bell or bell push.
+	storedState struct {
+		Enabled bool `json:"enabled"`
+
+		// Until is the time a temporary change expires at and Previous the state restored afterwards; Until is
+		// nil if the state is permanent.
+		Until    *time.Time `json:"until,omitempty"`
+		Previous bool       `json:"previous,omitempty"`
+	}
+
+	storedStates struct {
+		BellPushes map[string]storedState `json:"bellPushes"`
+		Bells      map[string]storedState `json:"bells"`
+	}
+)
+
+// NewStateStore creates a store persisting states in the file with the given path. The file and its directory
+// are created when the first state is written.
+func NewStateStore(path string) *StateStore {
+	return &StateStore{path: path}
+}
+
+// load reads the persisted states; empty states if the file does not exist.
+func (s *StateStore) load() (storedStates, error) {
+	var states storedStates
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return states, nil
+	}
+	if err != nil {
+		return states, err
+	}
+
+	if err := json.Unmarshal(data, &states); err != nil {
+		return storedStates{}, fmt.Errorf("invalid state file %s: %w", s.path, err)
+	}
+
+	return states, nil
+}
+
+// save writes states. The file is written atomically so that a crash never leaves a truncated file behind.
+func (s *StateStore) save(states storedStates) error {
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, ".state-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), s.path)
+}
+
+func (s *state) stored() storedState {
+	st := storedState{Enabled: s.enabled}
+	if !s.until.IsZero() {
+		until := s.until
+		st.Until = &until
+		st.Previous = s.previous
+	}
+	return st
+}
+
+// loadStates restores the states persisted by the state store. A temporary change that expired while the
+// gatekeeper was not running is reverted. Items without a persisted state keep their current state.
+func (g *Gatekeeper) loadStates() error {
+	states, err := g.opts.StateStore.load()
+	if err != nil {
+		return err
+	}
+	g.stored = states
+
+	now := time.Now()
+
+	for i, p := range g.bellPushes {
+		if st, ok := states.BellPushes[p.label]; ok {
+			g.restoreState(&p.state, fmt.Sprintf("bell push %d", i), st, now)
+		}
+	}
+
+	for i, b := range g.bells {
+		if st, ok := states.Bells[b.label]; ok {
+			g.restoreState(&b.state, fmt.Sprintf("bell %d", i), st, now)
+		}
+	}
+
+	return nil
+}
+
+func (g *Gatekeeper) restoreState(s *state, name string, st storedState, now time.Time) {
+	switch {
+	case st.Until == nil:
+		s.enabled = st.Enabled
+	case st.Until.After(now):
+		s.enabled, s.previous, s.until = st.Enabled, st.Previous, *st.Until
+		g.restoreAfter(s, name, st.Until.Sub(now))
+	default:
+		s.enabled = st.Previous
+	}
+}
+
+// saveStates persists the states of all bells and bell pushes merged into the states loaded on startup; it is a
+// no-op if no state store is configured. g.lock must be held.
+func (g *Gatekeeper) saveStates() error {
+	if g.opts.StateStore == nil {
+		return nil
+	}
+
+	if g.stored.BellPushes == nil {
+		g.stored.BellPushes = make(map[string]storedState, len(g.bellPushes))
+	}
+	if g.stored.Bells == nil {
+		g.stored.Bells = make(map[string]storedState, len(g.bells))
+	}
+
+	for _, p := range g.bellPushes {
+		g.stored.BellPushes[p.label] = p.stored()
+	}
+
+	for _, b := range g.bells {
+		g.stored.Bells[b.label] = b.stored()
+	}
+
+	if err := g.opts.StateStore.save(g.stored); err != nil {
+		return fmt.Errorf("failed to persist state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package gatekeeper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/halimath/raspidoor/daemon/internal/gpio"
+	"github.com/halimath/raspidoor/systemd/logging"
+)
+
+func newStoreGatekeeper(t *testing.T, store *StateStore, bells ...string) *Gatekeeper {
+	opts := Options{
+		StatusLED:   gpio.NewNOOPDigitalOutput(),
+		LEDDuration: time.Millisecond,
+		BellPushes: []BellPushOptions{
+			{Label: "front door", Input: gpio.NewNOOPDigitalInput()},
+		},
+		StateStore: store,
+	}
+
+	for _, b := range bells {
+		opts.Bells = append(opts.Bells, BellOptions{Label: b, Ringer: &ringerMock{}})
+	}
+
+	g, err := New(opts, logging.Stdout())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestStateStore(t *testing.T) {
+	store := NewStateStore(filepath.Join(t.TempDir(), "raspidoor", "state.json"))
+
+	g := newStoreGatekeeper(t, store, "chime", "phone", "garage")
+	if err := g.SetBellState(0, false, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SetBellState(2, false, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SetBellPushState(0, false, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// States are keyed by label so reordering the bells keeps their states.
+	g = newStoreGatekeeper(t, store, "phone", "garage", "chime", "new")
+	info := g.Info()
+
+	if info.BellPushes[0].Enabled {
+		t.Errorf("expected bell push to be disabled")
+	}
+	if !info.Bells[0].Enabled || info.Bells[2].Enabled || !info.Bells[3].Enabled {
+		t.Errorf("unexpected bell states: %+v", info.Bells)
+	}
+	if info.Bells[1].Enabled || info.Bells[1].Until.IsZero() {
+		t.Errorf("expected garage to be disabled temporarily: %+v", info.Bells[1])
+	}
+}
+
+func TestStateStore_removedBell(t *testing.T) {
+	store := NewStateStore(filepath.Join(t.TempDir(), "state.json"))
+
+	g := newStoreGatekeeper(t, store, "chime", "phone")
+	if err := g.SetBellState(1, false, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Saving the states while phone is removed from the config keeps its state.
+	g = newStoreGatekeeper(t, store, "chime")
+	if err := g.SetBellState(0, false, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	g = newStoreGatekeeper(t, store, "chime", "phone")
+	if info := g.Info(); info.Bells[0].Enabled || info.Bells[1].Enabled {
+		t.Errorf("unexpected bell states: %+v", info.Bells)
+	}
+}
+
+func TestStateStore_expired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	state := `{"bells": {"chime": {"enabled": false, "until": "2022-05-02T19:30:00Z", "previous": true}}}`
+	if err := os.WriteFile(path, []byte(state), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	g := newStoreGatekeeper(t, NewStateStore(path), "chime")
+	if i := g.Info().Bells[0]; !i.Enabled || !i.Until.IsZero() {
+		t.Errorf("expected expired change to be reverted: %+v", i)
+	}
+}
+
+func TestStateStore_invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	g := newStoreGatekeeper(t, NewStateStore(path), "chime")
+	if !g.Info().Bells[0].Enabled {
+		t.Errorf("expected bell to be enabled")
+	}
+}
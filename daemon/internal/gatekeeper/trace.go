@@ -0,0 +1,122 @@
+package gatekeeper
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/halimath/raspidoor/daemon/internal/sip"
+)
+
+const (
+	// TraceOff disables tracing SIP messages.
+	TraceOff = "off"
+	// TraceLog writes SIP messages to the gatekeeper's logger at debug level.
+	TraceLog = "log"
+	// TraceText writes SIP messages to a rotating text file.
+	TraceText = "text"
+	// TracePcapNG writes SIP messages to a rotating pcapng file to be opened with Wireshark.
+	TracePcapNG = "pcapng"
+)
+
+var (
+	ErrTracingUnavailable = errors.New("tracing not configured")
+)
+
+type (
+	// TraceOptions defines how to trace the SIP messages sent and received by the phone bells, the
+	// registration, the health probe and incoming calls.
+	TraceOptions struct {
+		// Tracer is the tracer used by all SIP transports and servers; the gatekeeper switches the tracer it
+		// forwards to when the trace mode changes.
+		Tracer *sip.SwitchTracer
+
+		// Mode is the initial trace mode; one of the Trace constants. Empty means TraceOff.
+		Mode string
+
+		// File is the path of the trace file used with TraceText and TracePcapNG.
+		File string
+
+		// MaxSize is the size in bytes after which the trace file is rotated; 0 to never rotate.
+		MaxSize int64
+
+		// MaxFiles is the number of rotated trace files to keep.
+		MaxFiles int
+	}
+
+	// TraceState describes the current trace mode.
+	TraceState struct {
+		Mode string
+
+		// File is the path of the trace file; empty unless Mode is TraceText or TracePcapNG.
+		File string
+	}
+)
+
+// SetTraceMode changes the way SIP messages are traced. TraceText and TracePcapNG write to the configured
+// trace file; the file cannot be changed at runtime so that clients cannot make the daemon write to arbitrary
+// files.
+func (g *Gatekeeper) SetTraceMode(mode string) error {
+	if g.opts.Trace == nil {
+		return ErrTracingUnavailable
+	}
+
+	if mode == "" {
+		mode = TraceOff
+	}
+
+	var tracer sip.Tracer
+	var file string
+	switch mode {
+	case TraceOff:
+	case TraceLog:
+		tracer = &sip.LogTracer{Logger: g.logger}
+	case TraceText, TracePcapNG:
+		file = g.opts.Trace.File
+		if file == "" {
+			return fmt.Errorf("missing trace file for trace mode %s", mode)
+		}
+
+		format := sip.TraceText
+		if mode == TracePcapNG {
+			format = sip.TracePcapNG
+		}
+
+		var err error
+		tracer, err = sip.NewFileTracer(file, format, g.opts.Trace.MaxSize, g.opts.Trace.MaxFiles)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid trace mode: %s", mode)
+	}
+
+	g.traceLock.Lock()
+	defer g.traceLock.Unlock()
+
+	prev := g.opts.Trace.Tracer.Set(tracer)
+	g.trace = TraceState{Mode: mode, File: file}
+
+	if c, ok := prev.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			g.logger.Error("failed to close trace file: %s", err)
+		}
+	}
+
+	if mode != TraceOff {
+		g.logger.Info("Tracing SIP messages: %s %s", mode, file)
+	}
+
+	return nil
+}
+
+// TraceState returns the current trace mode.
+func (g *Gatekeeper) TraceState() TraceState {
+	g.traceLock.Lock()
+	defer g.traceLock.Unlock()
+
+	if g.trace.Mode == "" {
+		return TraceState{Mode: TraceOff}
+	}
+	return g.trace
+}
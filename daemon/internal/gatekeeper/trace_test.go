@@ -0,0 +1,83 @@
+package gatekeeper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/halimath/raspidoor/daemon/internal/sip"
+	"github.com/halimath/raspidoor/systemd/logging"
+)
+
+func TestGatekeeper_SetTraceMode(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "sip.trace")
+	g := &Gatekeeper{
+		opts: Options{
+			Trace: &TraceOptions{Tracer: &sip.SwitchTracer{}, File: file},
+		},
+		logger: logging.Stdout(),
+	}
+
+	if diff := deep.Equal(g.TraceState(), TraceState{Mode: TraceOff}); diff != nil {
+		t.Error(diff)
+	}
+
+	if err := g.SetTraceMode(TraceText); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(g.TraceState(), TraceState{Mode: TraceText, File: file}); diff != nil {
+		t.Error(diff)
+	}
+
+	g.opts.Trace.Tracer.Trace(sip.TraceEvent{Transport: "UDP", Message: []byte("OPTIONS sip:example.com SIP/2.0\r\n\r\n")})
+
+	if err := g.SetTraceMode("wireshark"); err == nil {
+		t.Error("expected error for invalid mode")
+	}
+	if diff := deep.Equal(g.TraceState(), TraceState{Mode: TraceText, File: file}); diff != nil {
+		t.Error(diff)
+	}
+
+	if err := g.SetTraceMode(TraceOff); err != nil {
+		t.Fatal(err)
+	}
+	g.opts.Trace.Tracer.Trace(sip.TraceEvent{Transport: "UDP", Message: []byte("BYE sip:example.com SIP/2.0\r\n\r\n")})
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "OPTIONS") || strings.Contains(string(data), "BYE") {
+		t.Errorf("expected only the message sent while tracing but got %q", data)
+	}
+
+	if diff := deep.Equal(g.TraceState(), TraceState{Mode: TraceOff}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestGatekeeper_SetTraceMode_notConfigured(t *testing.T) {
+	g := &Gatekeeper{logger: logging.Stdout()}
+
+	if err := g.SetTraceMode(TraceLog); err != ErrTracingUnavailable {
+		t.Errorf("expected ErrTracingUnavailable but got %v", err)
+	}
+}
+
+func TestGatekeeper_SetTraceMode_missingFile(t *testing.T) {
+	g := &Gatekeeper{
+		opts: Options{
+			Trace: &TraceOptions{Tracer: &sip.SwitchTracer{}},
+		},
+		logger: logging.Stdout(),
+	}
+
+	if err := g.SetTraceMode(TracePcapNG); err == nil {
+		t.Error("expected error without configured trace file")
+	}
+	if err := g.SetTraceMode(TraceLog); err != nil {
+		t.Fatal(err)
+	}
+}
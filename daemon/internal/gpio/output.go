@@ -103,6 +103,57 @@ func (l *LED) Blink(duration time.Duration) (chan<- struct{}, error) {
 	return stopChan, nil
 }
 
+// BlinkPattern switches the LED on and off repeatedly following pattern, which lists the durations to keep
+// the LED on and off alternately starting with on. Call the returned function to stop blinking; it returns once
+// the LED's previous state has been restored.
+func (l *LED) BlinkPattern(pattern ...time.Duration) (func(), error) {
+	if len(pattern) == 0 || len(pattern)%2 != 0 {
+		return nil, errors.New("blink pattern must contain pairs of on and off durations")
+	}
+
+	if ok := l.lock.TryLock(); !ok {
+		return nil, ErrAlreadyBlinking
+	}
+
+	stopChan := make(chan struct{})
+	done := make(chan struct{})
+
+	s := l.State()
+
+	go func() {
+		defer close(done)
+		defer l.lock.Unlock()
+
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+
+		for i := 0; ; i = (i + 1) % len(pattern) {
+			select {
+			case <-stopChan:
+				if s {
+					l.On()
+				} else {
+					l.Off()
+				}
+				return
+
+			case <-timer.C:
+				if i%2 == 0 {
+					l.On()
+				} else {
+					l.Off()
+				}
+				timer.Reset(pattern[i])
+			}
+		}
+	}()
+
+	return func() {
+		close(stopChan)
+		<-done
+	}, nil
+}
+
 func (l *LED) BlinkFor(blinkDuration, onDuration time.Duration) error {
 	stopChan, err := l.Blink(onDuration)
 	if err != nil {
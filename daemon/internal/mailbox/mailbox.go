@@ -0,0 +1,248 @@
+// Package mailbox implements recording voice messages left by visitors if nobody answers the door.
+package mailbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/halimath/raspidoor/daemon/internal/audio"
+)
+
+const (
+	// fileExtension is the extension of the WAV files containing messages.
+	fileExtension = ".wav"
+
+	// idLayout is the layout of message IDs which are derived from the time recording started.
+	idLayout = "20060102T150405Z"
+
+	// wavHeaderSize is the size of the header written by audio.WriteWAV.
+	wavHeaderSize = 44
+
+	// frameDuration is the duration of the frames captured while recording.
+	frameDuration = 20 * time.Millisecond
+)
+
+var (
+	ErrNotFound = errors.New("message not found")
+)
+
+// Message describes a recorded message.
+type Message struct {
+	// ID identifies the message.
+	ID string
+
+	// Time is the time recording started.
+	Time time.Time
+
+	// Duration is the duration of the recording.
+	Duration time.Duration
+}
+
+// Mailbox records messages as WAV files in a spool directory.
+type Mailbox struct {
+	dir         string
+	prompt      []int16
+	maxDuration time.Duration
+
+	// lock serializes recordings so that the device is not used concurrently.
+	lock sync.Mutex
+}
+
+// New creates a Mailbox storing messages in dir. The prompt is played to visitors before recording up to
+// maxDuration of their message.
+func New(dir string, prompt []int16, maxDuration time.Duration) *Mailbox {
+	return &Mailbox{
+		dir:         dir,
+		prompt:      prompt,
+		maxDuration: maxDuration,
+	}
+}
+
+// Record plays the prompt via dev and records a message captured from dev. Recording stops after the
+// configured maximum duration or when ctx is done; the message recorded so far is stored in that case.
+func (m *Mailbox) Record(ctx context.Context, dev audio.Device) (Message, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	start := time.Now().UTC()
+
+	// Play the prompt and wait for it to be finished so that it is not recorded.
+	frame := make([]int16, int64(audio.SampleRate)*int64(frameDuration)/int64(time.Second))
+	for _, f := range audio.Frames(m.prompt, frameDuration) {
+		if err := dev.Playback(f); err != nil {
+			return Message{}, fmt.Errorf("failed to play prompt: %w", err)
+		}
+		if err := dev.Capture(ctx, frame); err != nil {
+			return Message{}, err
+		}
+	}
+
+	samples := make([]int16, 0, int64(audio.SampleRate)*int64(m.maxDuration)/int64(time.Second))
+	for len(samples) < cap(samples) {
+		if err := dev.Capture(ctx, frame); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return Message{}, fmt.Errorf("failed to record message: %w", err)
+		}
+		samples = append(samples, frame...)
+	}
+
+	return m.store(start, samples)
+}
+
+// store writes samples to a new file. The file is written atomically so that incomplete messages are never
+// listed.
+func (m *Mailbox) store(start time.Time, samples []int16) (Message, error) {
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return Message{}, err
+	}
+
+	var buf bytes.Buffer
+	if err := audio.WriteWAV(&buf, samples); err != nil {
+		return Message{}, err
+	}
+
+	id := start.Format(idLayout)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(m.path(id)); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		id = start.Format(idLayout) + "-" + strconv.Itoa(i)
+	}
+
+	f, err := os.CreateTemp(m.dir, ".recording-*")
+	if err != nil {
+		return Message{}, err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return Message{}, err
+	}
+
+	if err := f.Close(); err != nil {
+		return Message{}, err
+	}
+
+	if err := os.Rename(f.Name(), m.path(id)); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		ID:       id,
+		Time:     start,
+		Duration: time.Duration(len(samples)) * time.Second / audio.SampleRate,
+	}, nil
+}
+
+// List returns all messages ordered by time, the most recent one first.
+func (m *Mailbox) List() ([]Message, error) {
+	entries, err := os.ReadDir(m.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]Message, 0, len(entries))
+	for _, e := range entries {
+		id := strings.TrimSuffix(e.Name(), fileExtension)
+		if e.IsDir() || !validID(id) || id+fileExtension != e.Name() {
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, message(id, info.Size()))
+	}
+
+	sort.Slice(messages, func(i, j int) bool {
+		if messages[i].Time.Equal(messages[j].Time) {
+			return messages[i].ID > messages[j].ID
+		}
+		return messages[i].Time.After(messages[j].Time)
+	})
+
+	return messages, nil
+}
+
+// Read returns the message identified by id along with its WAV data.
+func (m *Mailbox) Read(id string) (Message, []byte, error) {
+	if !validID(id) {
+		return Message{}, nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	data, err := os.ReadFile(m.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return Message{}, nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return Message{}, nil, err
+	}
+
+	return message(id, int64(len(data))), data, nil
+}
+
+// Delete deletes the message identified by id.
+func (m *Mailbox) Delete(id string) error {
+	if !validID(id) {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	err := os.Remove(m.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	return err
+}
+
+// message describes the message identified by id stored in a file of size bytes.
+func message(id string, size int64) Message {
+	t, _ := time.Parse(idLayout, id[:len(idLayout)])
+
+	return Message{
+		ID:       id,
+		Time:     t,
+		Duration: time.Duration((size-wavHeaderSize)/2) * time.Second / audio.SampleRate,
+	}
+}
+
+func (m *Mailbox) path(id string) string {
+	return filepath.Join(m.dir, id+fileExtension)
+}
+
+// validID reports whether id is a message ID, i.e. a time formatted with idLayout optionally followed by a
+// counter. IDs are validated before using them as file names to prevent path traversal.
+func validID(id string) bool {
+	if len(id) < len(idLayout) {
+		return false
+	}
+
+	if _, err := time.Parse(idLayout, id[:len(idLayout)]); err != nil {
+		return false
+	}
+
+	suffix := id[len(idLayout):]
+	if suffix == "" {
+		return true
+	}
+
+	n, err := strconv.Atoi(strings.TrimPrefix(suffix, "-"))
+	return strings.HasPrefix(suffix, "-") && err == nil && n > 1 && strconv.Itoa(n) == suffix[1:]
+}
@@ -0,0 +1,127 @@
+package mailbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/halimath/raspidoor/daemon/internal/audio"
+)
+
+func TestMailbox(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "spool")
+
+	// 40ms are captured while the prompt is played; the message follows.
+	captured := make([]int16, 320+800)
+	for i := range captured {
+		captured[i] = 1
+		if i >= 320 {
+			captured[i] = 2
+		}
+	}
+	dev := audio.NewFileDevice(captured)
+
+	m := New(dir, make([]int16, 320), 100*time.Millisecond)
+
+	msg, err := m.Record(context.Background(), dev)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Duration != 100*time.Millisecond {
+		t.Errorf("expected 100ms but got %s", msg.Duration)
+	}
+
+	if len(dev.Recorded()) != 320 {
+		t.Errorf("expected prompt to be played but got %d samples", len(dev.Recorded()))
+	}
+
+	second, err := m.Record(context.Background(), audio.NewFileDevice(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := m.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(messages) != 2 || messages[0].ID != second.ID || messages[1].ID != msg.ID {
+		t.Fatalf("expected messages %s, %s but got %v", second.ID, msg.ID, messages)
+	}
+
+	if messages[1].Duration != msg.Duration || !messages[1].Time.Equal(msg.Time.Truncate(time.Second)) {
+		t.Errorf("expected %+v but got %+v", msg, messages[1])
+	}
+
+	read, data, err := m.Read(msg.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if read != messages[1] {
+		t.Errorf("expected %+v but got %+v", messages[1], read)
+	}
+
+	samples, err := audio.ReadWAV(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 800 || samples[0] != 2 || samples[799] != 2 {
+		t.Errorf("unexpected message: %d samples starting with %d", len(samples), samples[0])
+	}
+
+	if err := m.Delete(msg.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := m.Read(msg.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+
+	if err := m.Delete(msg.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+}
+
+func TestMailbox_Record_cancelled(t *testing.T) {
+	m := New(t.TempDir(), nil, time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	msg, err := m.Record(ctx, audio.NewFileDevice(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Duration <= 0 || msg.Duration > time.Second {
+		t.Errorf("expected the message recorded so far but got %s", msg.Duration)
+	}
+}
+
+func TestMailbox_invalidID(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "secret.wav"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := New(filepath.Join(dir, "spool"), nil, time.Second)
+
+	for _, id := range []string{"../secret", "secret", "20221030T101010Z-1", "20221030T101010Z/../../secret", ""} {
+		if _, _, err := m.Read(id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%q: expected ErrNotFound but got %v", id, err)
+		}
+		if err := m.Delete(id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%q: expected ErrNotFound but got %v", id, err)
+		}
+	}
+
+	if messages, err := m.List(); err != nil || len(messages) != 0 {
+		t.Errorf("expected no messages but got %v, %v", messages, err)
+	}
+}
@@ -0,0 +1,87 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// telephoneEventSize is the size of a telephone event payload as defined in RFC 4733 section 2.3.
+const telephoneEventSize = 4
+
+// digits maps the DTMF event codes 0 to 15 to the corresponding digits (RFC 4733 section 3.2).
+const digits = "0123456789*#ABCD"
+
+// TelephoneEvent is the payload of a RTP packet carrying a named telephone event (RFC 4733 section 2.3).
+type TelephoneEvent struct {
+	Event    uint8
+	End      bool
+	Volume   uint8
+	Duration uint16
+}
+
+// ParseTelephoneEvent parses a telephone event from a RTP packet's payload.
+func ParseTelephoneEvent(payload []byte) (TelephoneEvent, error) {
+	if len(payload) < telephoneEventSize {
+		return TelephoneEvent{}, fmt.Errorf("%w: telephone event too short", ErrInvalidPacket)
+	}
+
+	return TelephoneEvent{
+		Event:    payload[0],
+		End:      payload[1]&0x80 != 0,
+		Volume:   payload[1] & 0x3f,
+		Duration: binary.BigEndian.Uint16(payload[2:]),
+	}, nil
+}
+
+// Marshal returns the wire format of e.
+func (e TelephoneEvent) Marshal() []byte {
+	b := make([]byte, telephoneEventSize)
+	b[0] = e.Event
+	b[1] = e.Volume & 0x3f
+	if e.End {
+		b[1] |= 0x80
+	}
+	binary.BigEndian.PutUint16(b[2:], e.Duration)
+	return b
+}
+
+// Digit returns the DTMF digit denoted by e. It returns false if e is not a DTMF event.
+func (e TelephoneEvent) Digit() (rune, bool) {
+	if int(e.Event) >= len(digits) {
+		return 0, false
+	}
+	return rune(digits[e.Event]), true
+}
+
+// DTMFDetector detects DTMF digits from a stream of telephone event packets. Each digit is detected once
+// when the end of its event has been received.
+type DTMFDetector struct {
+	payloadType uint8
+
+	// The end of an event is sent three times; all packets of an event share the same timestamp
+	// (RFC 4733 section 2.5.1.4).
+	lastTimestamp uint32
+	detected      bool
+}
+
+// NewDTMFDetector creates a DTMFDetector for telephone events with the given payload type.
+func NewDTMFDetector(payloadType uint8) *DTMFDetector {
+	return &DTMFDetector{payloadType: payloadType}
+}
+
+// Detect returns the digit which ended with p. It returns false if p is not the first packet ending a DTMF
+// event, i.e. a packet of a different payload type.
+func (d *DTMFDetector) Detect(p *Packet) (rune, bool) {
+	if p.PayloadType != d.payloadType {
+		return 0, false
+	}
+
+	e, err := ParseTelephoneEvent(p.Payload)
+	if err != nil || !e.End || (d.detected && p.Timestamp == d.lastTimestamp) {
+		return 0, false
+	}
+
+	d.lastTimestamp, d.detected = p.Timestamp, true
+
+	return e.Digit()
+}
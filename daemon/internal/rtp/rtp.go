@@ -0,0 +1,220 @@
+// Package rtp implements sending and receiving audio via the Real-time Transport Protocol (RFC 3550).
+package rtp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	// Version is the RTP version implemented.
+	Version = 2
+
+	headerSize = 12
+
+	// maxPacketSize is the maximum size of packets received; larger packets are truncated.
+	maxPacketSize = 1500
+)
+
+var (
+	ErrInvalidPacket = errors.New("invalid RTP packet")
+)
+
+// Packet is a single RTP packet. Contributing sources and header extensions are not supported when
+// marshaling and skipped when parsing.
+type Packet struct {
+	Marker         bool
+	PayloadType    uint8
+	SequenceNumber uint16
+	Timestamp      uint32
+	SSRC           uint32
+	Payload        []byte
+}
+
+// Marshal returns the wire format of p.
+func (p *Packet) Marshal() []byte {
+	b := make([]byte, headerSize+len(p.Payload))
+
+	b[0] = Version << 6
+	b[1] = p.PayloadType & 0x7f
+	if p.Marker {
+		b[1] |= 0x80
+	}
+	binary.BigEndian.PutUint16(b[2:], p.SequenceNumber)
+	binary.BigEndian.PutUint32(b[4:], p.Timestamp)
+	binary.BigEndian.PutUint32(b[8:], p.SSRC)
+	copy(b[headerSize:], p.Payload)
+
+	return b
+}
+
+// Parse parses a RTP packet from data. The returned packet's payload refers to data.
+func Parse(data []byte) (*Packet, error) {
+	if len(data) < headerSize {
+		return nil, fmt.Errorf("%w: packet too short", ErrInvalidPacket)
+	}
+
+	if v := data[0] >> 6; v != Version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidPacket, v)
+	}
+
+	p := &Packet{
+		Marker:         data[1]&0x80 != 0,
+		PayloadType:    data[1] & 0x7f,
+		SequenceNumber: binary.BigEndian.Uint16(data[2:]),
+		Timestamp:      binary.BigEndian.Uint32(data[4:]),
+		SSRC:           binary.BigEndian.Uint32(data[8:]),
+	}
+
+	offset := headerSize + 4*int(data[0]&0x0f)
+	if data[0]&0x10 != 0 {
+		// Skip the header extension.
+		if len(data) < offset+4 {
+			return nil, fmt.Errorf("%w: truncated header extension", ErrInvalidPacket)
+		}
+		offset += 4 + 4*int(binary.BigEndian.Uint16(data[offset+2:]))
+	}
+
+	end := len(data)
+	if data[0]&0x20 != 0 {
+		// The last byte contains the number of padding bytes.
+		end -= int(data[len(data)-1])
+	}
+
+	if offset > end {
+		return nil, fmt.Errorf("%w: packet too short", ErrInvalidPacket)
+	}
+
+	p.Payload = data[offset:end]
+
+	return p, nil
+}
+
+// Sender sends a single stream of RTP packets to a remote address.
+type Sender struct {
+	con         net.PacketConn
+	remote      net.Addr
+	payloadType uint8
+	clockRate   int
+
+	ssrc           uint32
+	sequenceNumber uint16
+	timestamp      uint32
+	started        bool
+}
+
+// NewSender creates a Sender sending packets with the given payload type from con to remote. clockRate is
+// the payload format's clock rate used to compute timestamps.
+func NewSender(con net.PacketConn, remote net.Addr, payloadType uint8, clockRate int) *Sender {
+	// The initial values of SSRC, sequence number and timestamp should be random (RFC 3550 section 5.1).
+	var r [10]byte
+	if _, err := rand.Read(r[:]); err != nil {
+		panic(fmt.Sprintf("failed to read random bytes: %s", err))
+	}
+
+	return &Sender{
+		con:            con,
+		remote:         remote,
+		payloadType:    payloadType,
+		clockRate:      clockRate,
+		ssrc:           binary.BigEndian.Uint32(r[0:]),
+		sequenceNumber: binary.BigEndian.Uint16(r[4:]),
+		timestamp:      binary.BigEndian.Uint32(r[6:]),
+	}
+}
+
+// Send sends payload which contains samples samples as the next packet of the stream.
+func (s *Sender) Send(payload []byte, samples int) error {
+	p := Packet{
+		// The marker bit flags the beginning of a talkspurt.
+		Marker:         !s.started,
+		PayloadType:    s.payloadType,
+		SequenceNumber: s.sequenceNumber,
+		Timestamp:      s.timestamp,
+		SSRC:           s.ssrc,
+		Payload:        payload,
+	}
+
+	if _, err := s.con.WriteTo(p.Marshal(), s.remote); err != nil {
+		return err
+	}
+
+	s.started = true
+	s.sequenceNumber++
+	s.timestamp += uint32(samples)
+
+	return nil
+}
+
+// Stream sends payloads each containing ptime of audio in real time. It returns ctx's error if ctx is
+// done before all payloads have been sent.
+func (s *Sender) Stream(ctx context.Context, payloads [][]byte, ptime time.Duration) error {
+	samples := int(int64(s.clockRate) * int64(ptime) / int64(time.Second))
+
+	ticker := time.NewTicker(ptime)
+	defer ticker.Stop()
+
+	for i, p := range payloads {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+		}
+
+		if err := s.Send(p, samples); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Receive receives RTP packets from con and passes them to h until ctx is done. Invalid packets are
+// discarded. The packet passed to h is only valid until h returns. Receive returns nil once ctx is done.
+func Receive(ctx context.Context, con net.PacketConn, h func(p *Packet)) error {
+	// Clear the deadline set when a previous call has been cancelled.
+	if err := con.SetReadDeadline(time.Time{}); err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	go func() {
+		defer close(done)
+		select {
+		case <-ctx.Done():
+			con.SetReadDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, _, err := con.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		p, err := Parse(buf[:n])
+		if err != nil {
+			continue
+		}
+
+		h(p)
+	}
+}
@@ -0,0 +1,201 @@
+package rtp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPacket_roundTrip(t *testing.T) {
+	p := Packet{
+		Marker:         true,
+		PayloadType:    8,
+		SequenceNumber: 65535,
+		Timestamp:      1234567,
+		SSRC:           0xdeadbeef,
+		Payload:        []byte{1, 2, 3},
+	}
+
+	parsed, err := Parse(p.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.Marker != p.Marker || parsed.PayloadType != p.PayloadType || parsed.SequenceNumber != p.SequenceNumber ||
+		parsed.Timestamp != p.Timestamp || parsed.SSRC != p.SSRC || !bytes.Equal(parsed.Payload, p.Payload) {
+		t.Errorf("expected %+v but got %+v", p, parsed)
+	}
+}
+
+func TestParse_csrcExtensionAndPadding(t *testing.T) {
+	data := []byte{
+		0x80 | 0x20 | 0x10 | 0x01, 0x00, 0x00, 0x01, // V=2, P, X, CC=1
+		0, 0, 0, 0, // timestamp
+		0, 0, 0, 1, // SSRC
+		0, 0, 0, 2, // CSRC
+		0xbe, 0xde, 0x00, 0x01, // extension header with one word
+		0, 0, 0, 0, // extension
+		0xaa, 0xbb, // payload
+		0x00, 0x02, // padding
+	}
+
+	p, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(p.Payload, []byte{0xaa, 0xbb}) {
+		t.Errorf("unexpected payload %x", p.Payload)
+	}
+
+	if _, err := Parse(data[:8]); !errors.Is(err, ErrInvalidPacket) {
+		t.Errorf("expected invalid packet but got %v", err)
+	}
+}
+
+func TestSender_Stream(t *testing.T) {
+	sink, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	con, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	payloads := [][]byte{make([]byte, 160), make([]byte, 160), make([]byte, 160)}
+
+	s := NewSender(con, sink.LocalAddr(), 0, 8000)
+	if err := s.Stream(context.Background(), payloads, 5*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	var last *Packet
+	buf := make([]byte, 1500)
+	for i := range payloads {
+		sink.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := sink.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := Parse(append([]byte(nil), buf[:n]...))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if p.Marker != (i == 0) {
+			t.Errorf("packet %d: unexpected marker %v", i, p.Marker)
+		}
+
+		if last != nil {
+			if p.SequenceNumber != last.SequenceNumber+1 || p.Timestamp != last.Timestamp+40 || p.SSRC != last.SSRC {
+				t.Errorf("packet %d: unexpected header %+v following %+v", i, p, last)
+			}
+		}
+		last = p
+	}
+}
+
+func TestSender_Stream_cancelled(t *testing.T) {
+	con, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s := NewSender(con, con.LocalAddr(), 0, 8000)
+	if err := s.Stream(ctx, [][]byte{{0}, {0}}, time.Second); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled but got %v", err)
+	}
+}
+
+func TestTelephoneEvent_roundTrip(t *testing.T) {
+	e := TelephoneEvent{Event: 11, End: true, Volume: 10, Duration: 800}
+
+	got, err := ParseTelephoneEvent(e.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != e {
+		t.Errorf("expected %+v but got %+v", e, got)
+	}
+
+	if d, ok := got.Digit(); !ok || d != '#' {
+		t.Errorf("expected # but got %q", d)
+	}
+
+	if _, ok := (TelephoneEvent{Event: 16}).Digit(); ok {
+		t.Error("expected event 16 not to be a digit")
+	}
+}
+
+func TestDTMFDetector(t *testing.T) {
+	con, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	sender, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+
+	send := func(pt uint8, ts uint32, payload []byte) {
+		p := Packet{PayloadType: pt, Timestamp: ts, Payload: payload}
+		if _, err := sender.WriteTo(p.Marshal(), con.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Audio, an event in progress and the retransmitted end of the event are followed by a second event.
+	send(0, 0, make([]byte, 160))
+	send(101, 160, TelephoneEvent{Event: 1, Duration: 160}.Marshal())
+	for i := 0; i < 3; i++ {
+		send(101, 160, TelephoneEvent{Event: 1, End: true, Duration: 320}.Marshal())
+	}
+	send(101, 800, TelephoneEvent{Event: 10, End: true, Duration: 320}.Marshal())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan rune, 10)
+	done := make(chan error)
+	go func() {
+		detector := NewDTMFDetector(101)
+		done <- Receive(ctx, con, func(p *Packet) {
+			if d, ok := detector.Detect(p); ok {
+				out <- d
+			}
+		})
+	}()
+
+	var got []rune
+	for len(got) < 2 {
+		select {
+		case d := <-out:
+			got = append(got, d)
+		case <-time.After(time.Second):
+			t.Fatalf("expected two digits but got %q", string(got))
+		}
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+
+	if string(got) != "1*" || len(out) != 0 {
+		t.Errorf("expected 1* but got %q and %d more", string(got), len(out))
+	}
+}
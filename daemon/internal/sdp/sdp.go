@@ -0,0 +1,464 @@
+// Package sdp implements the subset of the Session Description Protocol (RFC 4566) required to negotiate a
+// single audio stream using the offer/answer model defined in RFC 3264.
+package sdp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ContentType is the content type of SIP message bodies containing a session description.
+	ContentType = "application/sdp"
+
+	MediaTypeAudio = "audio"
+	ProtocolRTPAVP = "RTP/AVP"
+
+	// Ptime is the packetization time in milliseconds announced in offers.
+	Ptime = 20
+)
+
+var (
+	ErrParsingError  = errors.New("error parsing session description")
+	ErrNoCommonCodec = errors.New("no common codec")
+	ErrNoMedia       = errors.New("no audio stream")
+)
+
+// Codec describes a RTP payload format.
+type Codec struct {
+	PayloadType uint8
+	Name        string
+	ClockRate   int
+	// Parameters contains the format specific parameters sent as fmtp attribute; empty if none.
+	Parameters string
+}
+
+func (c Codec) String() string {
+	return fmt.Sprintf("%s/%d", c.Name, c.ClockRate)
+}
+
+// matches reports whether c and o describe the same payload format regardless of the payload type.
+func (c Codec) matches(o Codec) bool {
+	return strings.EqualFold(c.Name, o.Name) && c.ClockRate == o.ClockRate
+}
+
+var (
+	// PCMU is G.711 µ-law.
+	PCMU = Codec{PayloadType: 0, Name: "PCMU", ClockRate: 8000}
+	// PCMA is G.711 A-law.
+	PCMA = Codec{PayloadType: 8, Name: "PCMA", ClockRate: 8000}
+	// TelephoneEvent transports DTMF digits as defined in RFC 4733.
+	TelephoneEvent = Codec{PayloadType: 101, Name: "telephone-event", ClockRate: 8000, Parameters: "0-16"}
+)
+
+// staticCodecs contains the static payload types defined in RFC 3551 which may be used without a rtpmap
+// attribute.
+var staticCodecs = map[uint8]Codec{
+	PCMU.PayloadType: PCMU,
+	3:                {PayloadType: 3, Name: "GSM", ClockRate: 8000},
+	PCMA.PayloadType: PCMA,
+	9:                {PayloadType: 9, Name: "G722", ClockRate: 8000},
+	18:               {PayloadType: 18, Name: "G729", ClockRate: 8000},
+}
+
+// Media describes a single media stream (m= line).
+type Media struct {
+	Type     string
+	Port     int
+	Protocol string
+	Codecs   []Codec
+	// Address is the media level connection address; nil if the session level address applies.
+	Address net.IP
+	// Attributes contains all attributes except rtpmap and fmtp, i.e. "sendrecv" or "ptime:20".
+	Attributes []string
+}
+
+// Session is a session description.
+type Session struct {
+	Username string
+	ID       uint64
+	Version  uint64
+	// Address is the session level connection address.
+	Address net.IP
+	Name    string
+	Media   []Media
+}
+
+// NewOffer creates a session description offering a single audio stream received at addr and port using
+// the given codecs in order of preference.
+func NewOffer(addr net.IP, port int, codecs ...Codec) *Session {
+	now := uint64(time.Now().Unix())
+
+	return &Session{
+		Username: "raspidoor",
+		ID:       now,
+		Version:  now,
+		Address:  addr,
+		Name:     "raspidoor",
+		Media: []Media{
+			{
+				Type:       MediaTypeAudio,
+				Port:       port,
+				Protocol:   ProtocolRTPAVP,
+				Codecs:     codecs,
+				Attributes: []string{fmt.Sprintf("ptime:%d", Ptime), "sendrecv"},
+			},
+		},
+	}
+}
+
+// Marshal formats s as defined in RFC 4566.
+func (s *Session) Marshal() []byte {
+	var b bytes.Buffer
+
+	b.WriteString("v=0\r\n")
+	fmt.Fprintf(&b, "o=%s %d %d IN %s %s\r\n", s.Username, s.ID, s.Version, addrType(s.Address), s.Address)
+	fmt.Fprintf(&b, "s=%s\r\n", s.Name)
+	fmt.Fprintf(&b, "c=IN %s %s\r\n", addrType(s.Address), s.Address)
+	b.WriteString("t=0 0\r\n")
+
+	for _, m := range s.Media {
+		formats := make([]string, len(m.Codecs))
+		for i, c := range m.Codecs {
+			formats[i] = strconv.Itoa(int(c.PayloadType))
+		}
+		fmt.Fprintf(&b, "m=%s %d %s %s\r\n", m.Type, m.Port, m.Protocol, strings.Join(formats, " "))
+
+		if m.Address != nil {
+			fmt.Fprintf(&b, "c=IN %s %s\r\n", addrType(m.Address), m.Address)
+		}
+
+		for _, c := range m.Codecs {
+			fmt.Fprintf(&b, "a=rtpmap:%d %s/%d\r\n", c.PayloadType, c.Name, c.ClockRate)
+			if c.Parameters != "" {
+				fmt.Fprintf(&b, "a=fmtp:%d %s\r\n", c.PayloadType, c.Parameters)
+			}
+		}
+
+		for _, a := range m.Attributes {
+			fmt.Fprintf(&b, "a=%s\r\n", a)
+		}
+	}
+
+	return b.Bytes()
+}
+
+func addrType(ip net.IP) string {
+	if ip.To4() == nil {
+		return "IP6"
+	}
+	return "IP4"
+}
+
+// Parse parses the session description contained in data.
+func Parse(data []byte) (*Session, error) {
+	s := &Session{}
+	var m *Media
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if len(line) < 2 || line[1] != '=' {
+			return nil, fmt.Errorf("%w: invalid line: %s", ErrParsingError, line)
+		}
+		value := line[2:]
+
+		var err error
+		switch line[0] {
+		case 'o':
+			err = s.parseOrigin(value)
+		case 's':
+			s.Name = value
+		case 'c':
+			var addr net.IP
+			addr, err = parseConnection(value)
+			if m == nil {
+				s.Address = addr
+			} else {
+				m.Address = addr
+			}
+		case 'm':
+			s.Media = append(s.Media, Media{})
+			m = &s.Media[len(s.Media)-1]
+			err = m.parse(value)
+		case 'a':
+			if m != nil {
+				err = m.parseAttribute(value)
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrParsingError, err)
+	}
+
+	return s, nil
+}
+
+func (s *Session) parseOrigin(value string) error {
+	f := strings.Fields(value)
+	if len(f) != 6 {
+		return fmt.Errorf("%w: invalid origin: %s", ErrParsingError, value)
+	}
+
+	var err error
+	s.Username = f[0]
+	if s.ID, err = strconv.ParseUint(f[1], 10, 64); err != nil {
+		return fmt.Errorf("%w: invalid session id: %s", ErrParsingError, f[1])
+	}
+	if s.Version, err = strconv.ParseUint(f[2], 10, 64); err != nil {
+		return fmt.Errorf("%w: invalid session version: %s", ErrParsingError, f[2])
+	}
+
+	return nil
+}
+
+func parseConnection(value string) (net.IP, error) {
+	f := strings.Fields(value)
+	if len(f) != 3 || f[0] != "IN" {
+		return nil, fmt.Errorf("%w: invalid connection: %s", ErrParsingError, value)
+	}
+
+	// Multicast addresses may carry a TTL and number of addresses separated by slashes.
+	addr := net.ParseIP(strings.Split(f[2], "/")[0])
+	if addr == nil {
+		return nil, fmt.Errorf("%w: invalid connection address: %s", ErrParsingError, f[2])
+	}
+
+	return addr, nil
+}
+
+func (m *Media) parse(value string) error {
+	f := strings.Fields(value)
+	if len(f) < 3 {
+		return fmt.Errorf("%w: invalid media: %s", ErrParsingError, value)
+	}
+
+	m.Type = f[0]
+	m.Protocol = f[2]
+
+	port, err := strconv.Atoi(strings.Split(f[1], "/")[0])
+	if err != nil {
+		return fmt.Errorf("%w: invalid media port: %s", ErrParsingError, f[1])
+	}
+	m.Port = port
+
+	for _, format := range f[3:] {
+		pt, err := strconv.ParseUint(format, 10, 7)
+		if err != nil {
+			return fmt.Errorf("%w: invalid payload type: %s", ErrParsingError, format)
+		}
+
+		c, ok := staticCodecs[uint8(pt)]
+		if !ok {
+			c = Codec{PayloadType: uint8(pt)}
+		}
+		m.Codecs = append(m.Codecs, c)
+	}
+
+	return nil
+}
+
+func (m *Media) parseAttribute(value string) error {
+	name, val := value, ""
+	if i := strings.IndexByte(value, ':'); i >= 0 {
+		name, val = value[:i], value[i+1:]
+	}
+
+	switch name {
+	case "rtpmap":
+		// rtpmap:<payload type> <encoding name>/<clock rate>[/<encoding parameters>]
+		f := strings.Fields(val)
+		if len(f) != 2 {
+			return fmt.Errorf("%w: invalid rtpmap: %s", ErrParsingError, val)
+		}
+		enc := strings.Split(f[1], "/")
+		if len(enc) < 2 {
+			return fmt.Errorf("%w: invalid rtpmap: %s", ErrParsingError, val)
+		}
+		rate, err := strconv.Atoi(enc[1])
+		if err != nil {
+			return fmt.Errorf("%w: invalid clock rate: %s", ErrParsingError, val)
+		}
+
+		if c := m.codec(f[0]); c != nil {
+			c.Name = enc[0]
+			c.ClockRate = rate
+		}
+	case "fmtp":
+		f := strings.SplitN(val, " ", 2)
+		if len(f) != 2 {
+			return fmt.Errorf("%w: invalid fmtp: %s", ErrParsingError, val)
+		}
+
+		if c := m.codec(f[0]); c != nil {
+			c.Parameters = strings.TrimSpace(f[1])
+		}
+	default:
+		m.Attributes = append(m.Attributes, value)
+	}
+
+	return nil
+}
+
+// codec returns the codec with the given payload type; nil if the payload type is not listed in m.
+func (m *Media) codec(payloadType string) *Codec {
+	for i := range m.Codecs {
+		if strconv.Itoa(int(m.Codecs[i].PayloadType)) == payloadType {
+			return &m.Codecs[i]
+		}
+	}
+	return nil
+}
+
+// Negotiation is the result of an offer/answer exchange.
+type Negotiation struct {
+	// Codec is the audio codec to use.
+	Codec Codec
+	// TelephoneEvent is the payload format for DTMF events; nil if the answerer does not support them.
+	TelephoneEvent *Codec
+	// Remote is the address to send RTP packets to.
+	Remote *net.UDPAddr
+}
+
+// Negotiate determines the codec and remote RTP endpoint from answer given the offer it answers following
+// RFC 3264 section 6. The first codec listed in the answer which has also been offered is chosen.
+func Negotiate(offer, answer *Session) (*Negotiation, error) {
+	offered, ok := offer.audio()
+	if !ok {
+		return nil, fmt.Errorf("%w: offer", ErrNoMedia)
+	}
+
+	m, ok := answer.audio()
+	if !ok || m.Port == 0 {
+		// A port of 0 rejects the stream.
+		return nil, fmt.Errorf("%w: answer", ErrNoMedia)
+	}
+
+	addr := m.Address
+	if addr == nil {
+		addr = answer.Address
+	}
+	if addr == nil {
+		return nil, fmt.Errorf("%w: missing connection address", ErrParsingError)
+	}
+
+	n := &Negotiation{
+		Remote: &net.UDPAddr{IP: addr, Port: m.Port},
+	}
+
+	var found bool
+	for _, c := range m.Codecs {
+		if !offered.offers(c) {
+			continue
+		}
+
+		if c.matches(TelephoneEvent) {
+			if n.TelephoneEvent == nil {
+				te := c
+				n.TelephoneEvent = &te
+			}
+			continue
+		}
+
+		if !found {
+			n.Codec = c
+			found = true
+		}
+	}
+
+	if !found {
+		return nil, ErrNoCommonCodec
+	}
+
+	return n, nil
+}
+
+// NewAnswer answers offer following RFC 3264 section 6 with a single audio stream received at addr and port.
+// The first codec listed in the offer which is also contained in codecs is chosen. Telephone events are
+// accepted if offered and contained in codecs. The payload types of the offer are used in the answer.
+func NewAnswer(offer *Session, addr net.IP, port int, codecs ...Codec) (*Session, *Negotiation, error) {
+	m, ok := offer.audio()
+	if !ok || m.Port == 0 {
+		return nil, nil, fmt.Errorf("%w: offer", ErrNoMedia)
+	}
+
+	remote := m.Address
+	if remote == nil {
+		remote = offer.Address
+	}
+	if remote == nil {
+		return nil, nil, fmt.Errorf("%w: missing connection address", ErrParsingError)
+	}
+
+	supported := Media{Codecs: codecs}
+
+	n := &Negotiation{
+		Remote: &net.UDPAddr{IP: remote, Port: m.Port},
+	}
+
+	var found bool
+	for _, c := range m.Codecs {
+		if !supported.offers(c) {
+			continue
+		}
+
+		if c.matches(TelephoneEvent) {
+			if n.TelephoneEvent == nil {
+				te := c
+				n.TelephoneEvent = &te
+			}
+			continue
+		}
+
+		if !found {
+			n.Codec = c
+			found = true
+		}
+	}
+
+	if !found {
+		return nil, nil, ErrNoCommonCodec
+	}
+
+	answered := []Codec{n.Codec}
+	if n.TelephoneEvent != nil {
+		answered = append(answered, *n.TelephoneEvent)
+	}
+
+	return NewOffer(addr, port, answered...), n, nil
+}
+
+// audio returns the first audio stream of s.
+func (s *Session) audio() (Media, bool) {
+	for _, m := range s.Media {
+		if m.Type == MediaTypeAudio {
+			return m, true
+		}
+	}
+	return Media{}, false
+}
+
+// offers reports whether m lists a codec matching c.
+func (m Media) offers(c Codec) bool {
+	for _, o := range m.Codecs {
+		if o.matches(c) {
+			return true
+		}
+	}
+	return false
+}
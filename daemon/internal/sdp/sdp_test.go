@@ -0,0 +1,155 @@
+package sdp
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOffer_roundTrip(t *testing.T) {
+	offer := NewOffer(net.ParseIP("192.168.1.10"), 40000, PCMU, PCMA, TelephoneEvent)
+
+	data := string(offer.Marshal())
+	for _, l := range []string{
+		"c=IN IP4 192.168.1.10\r\n",
+		"m=audio 40000 RTP/AVP 0 8 101\r\n",
+		"a=rtpmap:8 PCMA/8000\r\n",
+		"a=fmtp:101 0-16\r\n",
+		"a=sendrecv\r\n",
+	} {
+		if !strings.Contains(data, l) {
+			t.Errorf("expected offer to contain %q:\n%s", l, data)
+		}
+	}
+
+	parsed, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(offer, parsed) {
+		t.Errorf("expected %#v but got %#v", offer, parsed)
+	}
+}
+
+func TestOffer_ipv6(t *testing.T) {
+	offer := NewOffer(net.ParseIP("2001:db8::1"), 40000, PCMU)
+
+	if data := string(offer.Marshal()); !strings.Contains(data, "c=IN IP6 2001:db8::1\r\n") {
+		t.Errorf("expected IP6 connection:\n%s", data)
+	}
+}
+
+func TestParse_invalid(t *testing.T) {
+	for _, data := range []string{
+		"v=0\r\nnot a line\r\n",
+		"v=0\r\nc=IN IP4 not-an-ip\r\n",
+		"v=0\r\nm=audio port RTP/AVP 0\r\n",
+		"v=0\r\nm=audio 4000 RTP/AVP 0\r\na=rtpmap:0 PCMU\r\n",
+	} {
+		if _, err := Parse([]byte(data)); !errors.Is(err, ErrParsingError) {
+			t.Errorf("expected parsing error for %q but got %v", data, err)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	offer := NewOffer(net.ParseIP("192.168.1.10"), 40000, PCMU, PCMA, TelephoneEvent)
+
+	tests := []struct {
+		name       string
+		answer     string
+		wantCodec  Codec
+		wantDTMF   bool
+		wantRemote string
+		wantErr    error
+	}{
+		{
+			name:       "static payload types",
+			answer:     "v=0\r\no=- 1 1 IN IP4 192.168.1.1\r\ns=-\r\nc=IN IP4 192.168.1.1\r\nt=0 0\r\nm=audio 7078 RTP/AVP 8\r\n",
+			wantCodec:  PCMA,
+			wantRemote: "192.168.1.1:7078",
+		},
+		{
+			name:       "media level address and dynamic telephone-event",
+			answer:     "v=0\r\no=- 1 1 IN IP4 192.168.1.1\r\ns=-\r\nc=IN IP4 192.168.1.1\r\nt=0 0\r\nm=audio 7078 RTP/AVP 0 96\r\nc=IN IP4 192.168.1.2\r\na=rtpmap:0 PCMU/8000\r\na=rtpmap:96 telephone-event/8000\r\na=fmtp:96 0-15\r\n",
+			wantCodec:  PCMU,
+			wantDTMF:   true,
+			wantRemote: "192.168.1.2:7078",
+		},
+		{
+			name:    "no common codec",
+			answer:  "v=0\r\no=- 1 1 IN IP4 192.168.1.1\r\ns=-\r\nc=IN IP4 192.168.1.1\r\nt=0 0\r\nm=audio 7078 RTP/AVP 9 101\r\na=rtpmap:101 telephone-event/8000\r\n",
+			wantErr: ErrNoCommonCodec,
+		},
+		{
+			name:    "rejected stream",
+			answer:  "v=0\r\no=- 1 1 IN IP4 192.168.1.1\r\ns=-\r\nc=IN IP4 192.168.1.1\r\nt=0 0\r\nm=audio 0 RTP/AVP 0\r\n",
+			wantErr: ErrNoMedia,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			answer, err := Parse([]byte(test.answer))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			n, err := Negotiate(offer, answer)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Errorf("expected %v but got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if n.Codec.Name != test.wantCodec.Name || n.Codec.PayloadType != test.wantCodec.PayloadType {
+				t.Errorf("expected %s but got %s", test.wantCodec, n.Codec)
+			}
+
+			if (n.TelephoneEvent != nil) != test.wantDTMF {
+				t.Errorf("expected telephone-event: %v", test.wantDTMF)
+			}
+
+			if n.Remote.String() != test.wantRemote {
+				t.Errorf("expected remote %s but got %s", test.wantRemote, n.Remote)
+			}
+		})
+	}
+}
+
+func TestNewAnswer(t *testing.T) {
+	offer, err := Parse([]byte("v=0\r\no=- 1 1 IN IP4 192.168.1.1\r\ns=-\r\nc=IN IP4 192.168.1.1\r\nt=0 0\r\nm=audio 7078 RTP/AVP 9 8 0 96\r\na=rtpmap:96 telephone-event/8000\r\na=fmtp:96 0-15\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	answer, n, err := NewAnswer(offer, net.ParseIP("192.168.1.10"), 40000, PCMU, PCMA, TelephoneEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n.Codec.Name != PCMA.Name || n.TelephoneEvent == nil || n.TelephoneEvent.PayloadType != 96 || n.Remote.String() != "192.168.1.1:7078" {
+		t.Errorf("unexpected negotiation: %+v", n)
+	}
+
+	// The caller negotiates the same session from the answer.
+	negotiated, err := Negotiate(offer, answer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if negotiated.Codec.Name != PCMA.Name || negotiated.TelephoneEvent == nil || negotiated.TelephoneEvent.PayloadType != 96 || negotiated.Remote.String() != "192.168.1.10:40000" {
+		t.Errorf("unexpected negotiation: %+v", negotiated)
+	}
+
+	if _, _, err := NewAnswer(offer, net.ParseIP("192.168.1.10"), 40000, TelephoneEvent); !errors.Is(err, ErrNoCommonCodec) {
+		t.Errorf("expected ErrNoCommonCodec but got %v", err)
+	}
+}
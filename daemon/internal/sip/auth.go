@@ -2,12 +2,23 @@ package sip
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
+	"net/http"
+	"net/textproto"
+	"sort"
 	"strings"
+	"sync"
 )
 
+// maxAuthenticationAttempts limits the number of times a request is resent with credentials. Requests are
+// resent more than once only if the server marks the nonce as stale.
+const maxAuthenticationAttempts = 3
+
 var (
 	ErrInvalidAuthenticationChallenge     = errors.New("invalid authentication challenge")
 	ErrUnsolveableAuthenticationChallenge = errors.New("unsolveable authentication challenge")
@@ -16,12 +27,45 @@ var (
 type AuthenticationChallenge struct {
 	Method     string
 	Properties map[string]string
+
+	// Proxy is set for challenges issued by a proxy via Proxy-Authenticate; the credentials must be sent
+	// using Proxy-Authorization instead of Authorization.
+	Proxy bool
+}
+
+// parseChallenges parses all authentication challenges from res which must be either a 401 or a 407
+// response.
+func parseChallenges(res *Response) ([]AuthenticationChallenge, error) {
+	header := "WWW-Authenticate"
+	proxy := res.StatusCode == http.StatusProxyAuthRequired
+	if proxy {
+		header = "Proxy-Authenticate"
+	}
+
+	values := res.Header[textproto.CanonicalMIMEHeaderKey(header)]
+	if len(values) == 0 {
+		return nil, fmt.Errorf("%w: missing %s header", ErrInvalidAuthenticationChallenge, header)
+	}
+
+	challenges := make([]AuthenticationChallenge, 0, len(values))
+	for _, v := range values {
+		c, err := parseWWWAuthenticateHeader(v)
+		if err != nil {
+			return nil, err
+		}
+		c.Proxy = proxy
+		challenges = append(challenges, c)
+	}
+
+	return challenges, nil
 }
 
+// parseWWWAuthenticateHeader parses a single challenge from a WWW-Authenticate or Proxy-Authenticate header.
+// Parameter values may be quoted strings containing commas and escaped quotes.
 func parseWWWAuthenticateHeader(h string) (AuthenticationChallenge, error) {
 	h = strings.TrimSpace(h)
 
-	methodAndProps := strings.SplitN(strings.TrimSpace(h), " ", 2)
+	methodAndProps := strings.SplitN(h, " ", 2)
 	if len(methodAndProps) != 2 {
 		return AuthenticationChallenge{Method: h}, nil
 	}
@@ -31,48 +75,339 @@ func parseWWWAuthenticateHeader(h string) (AuthenticationChallenge, error) {
 		Properties: make(map[string]string),
 	}
 
-	props := strings.Split(methodAndProps[1], ",")
-	for _, prop := range props {
-		keyVal := strings.SplitN(strings.TrimSpace(prop), "=", 2)
-		if len(keyVal) != 2 {
+	props := methodAndProps[1]
+	for {
+		props = strings.TrimLeft(props, " \t,")
+		if props == "" {
+			return c, nil
+		}
+
+		eq := strings.IndexByte(props, '=')
+		if eq < 0 {
 			return AuthenticationChallenge{}, ErrInvalidAuthenticationChallenge
 		}
-		c.Properties[keyVal[0]] = strings.Trim(keyVal[1], `"`)
+		key := strings.TrimSpace(props[:eq])
+		props = strings.TrimLeft(props[eq+1:], " \t")
+
+		var val string
+		if strings.HasPrefix(props, `"`) {
+			var ok bool
+			val, props, ok = readQuotedString(props)
+			if !ok {
+				return AuthenticationChallenge{}, ErrInvalidAuthenticationChallenge
+			}
+		} else if comma := strings.IndexByte(props, ','); comma >= 0 {
+			val, props = strings.TrimSpace(props[:comma]), props[comma:]
+		} else {
+			val, props = strings.TrimSpace(props), ""
+		}
+
+		c.Properties[key] = val
 	}
+}
+
+// readQuotedString reads the quoted string at the beginning of s and returns the unquoted value as well as
+// the remainder of s. ok is false if the quoted string is not terminated.
+func readQuotedString(s string) (val, rest string, ok bool) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+			if i < len(s) {
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), s[i+1:], true
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+
+	return "", "", false
+}
+
+// Stale reports whether the challenge has been issued because the nonce of a previous request was stale.
+// In this case, the request should be retried using the new nonce without asking for new credentials.
+func (c AuthenticationChallenge) Stale() bool {
+	return strings.EqualFold(c.Properties["stale"], "true")
+}
 
-	return c, nil
+// authorizationHeader returns the name of the header to send the solution of c in.
+func (c AuthenticationChallenge) authorizationHeader() string {
+	if c.Proxy {
+		return "Proxy-Authorization"
+	}
+	return "Authorization"
+}
+
+// strength ranks c by the strength of its digest algorithm and whether it supports qop. Challenges using
+// unknown algorithms rank lowest.
+func (c AuthenticationChallenge) strength() int {
+	var s int
+	switch strings.TrimSuffix(strings.ToUpper(c.Properties["algorithm"]), "-SESS") {
+	case "", "MD5":
+		s = 1
+	case "SHA-256":
+		s = 2
+	case "SHA-512-256":
+		s = 3
+	}
+
+	s *= 2
+	if c.Properties["qop"] != "" {
+		s++
+	}
+
+	return s
 }
 
 type AuthenticationHandler interface {
 	Solve(challenge AuthenticationChallenge, req *Request) error
 }
 
+// isChallenge reports whether statusCode denotes a response carrying authentication challenges.
+func isChallenge(statusCode int) bool {
+	return statusCode == http.StatusUnauthorized || statusCode == http.StatusProxyAuthRequired
+}
+
+// challengeSolver solves the challenges received in 401 and 407 responses for a sequence of requests. It
+// remembers the solved challenges so that subsequent requests (i.e. ACK or BYE) can be authenticated too.
+type challengeSolver struct {
+	handlers []AuthenticationHandler
+	solved   []AuthenticationChallenge
+	answered map[bool]bool
+}
+
+func newChallengeSolver(handlers []AuthenticationHandler) *challengeSolver {
+	return &challengeSolver{
+		handlers: handlers,
+		answered: make(map[bool]bool),
+	}
+}
+
+// solve solves the strongest solvable challenge from res for req. It returns false if the challenges should
+// not be answered because credentials for the same kind of challenge have been rejected before (and the
+// nonce was not stale).
+func (s *challengeSolver) solve(res *Response, req *Request) (bool, error) {
+	challenges, err := parseChallenges(res)
+	if err != nil {
+		return false, err
+	}
+
+	proxy := challenges[0].Proxy
+	if s.answered[proxy] && !challenges[0].Stale() {
+		return false, nil
+	}
+
+	sort.SliceStable(challenges, func(i, j int) bool {
+		return challenges[i].strength() > challenges[j].strength()
+	})
+
+	for _, c := range challenges {
+		err = solveChallenge(s.handlers, c, req)
+		if errors.Is(err, ErrUnsolveableAuthenticationChallenge) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+
+		s.answered[proxy] = true
+		s.remember(c)
+		return true, nil
+	}
+
+	return false, ErrUnsolveableAuthenticationChallenge
+}
+
+// remember stores c replacing any challenge previously solved for the same header.
+func (s *challengeSolver) remember(c AuthenticationChallenge) {
+	for i, solved := range s.solved {
+		if solved.Proxy == c.Proxy {
+			s.solved[i] = c
+			return
+		}
+	}
+	s.solved = append(s.solved, c)
+}
+
+// authenticate solves all remembered challenges for req.
+func (s *challengeSolver) authenticate(req *Request) error {
+	for _, c := range s.solved {
+		if err := solveChallenge(s.handlers, c, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// solveChallenge solves c for req using the first handler from handlers able to solve it.
+func solveChallenge(handlers []AuthenticationHandler, c AuthenticationChallenge, req *Request) error {
+	for _, h := range handlers {
+		err := h.Solve(c, req)
+		if err == nil {
+			return nil
+		}
+
+		if errors.Is(err, ErrUnsolveableAuthenticationChallenge) {
+			continue
+		}
+
+		return err
+	}
+
+	return ErrUnsolveableAuthenticationChallenge
+}
+
 func NewDigestHandler(username, password string) AuthenticationHandler {
 	return &digestAuthenticationHandler{
-		username: username,
-		password: password,
+		username:    username,
+		password:    password,
+		cnonce:      func() string { return randomToken(16) },
+		nonceCounts: make(map[string]uint32),
 	}
 }
 
+const (
+	qopAuth    = "auth"
+	qopAuthInt = "auth-int"
+
+	// maxTrackedNonces limits the number of nonces to track nonce counts for.
+	maxTrackedNonces = 32
+)
+
+// digestAlgorithms maps the algorithms defined in RFC 7616 (and RFC 8760 for SIP) to their hash functions.
+// Session variants use the same hash function with a suffix of -sess.
+var digestAlgorithms = map[string]func() hash.Hash{
+	"MD5":         md5.New,
+	"SHA-256":     sha256.New,
+	"SHA-512-256": sha512.New512_256,
+}
+
 type digestAuthenticationHandler struct {
 	username, password string
+
+	// cnonce generates client nonces.
+	cnonce func() string
+
+	lock        sync.Mutex
+	nonceCounts map[string]uint32
 }
 
 func (h *digestAuthenticationHandler) Solve(challenge AuthenticationChallenge, req *Request) error {
+	return h.solve(challenge, req, req.URI.String())
+}
+
+// solve solves challenge for req using uri as the digest-uri.
+func (h *digestAuthenticationHandler) solve(challenge AuthenticationChallenge, req *Request, uri string) error {
 	if strings.ToLower(challenge.Method) != "digest" {
-		return ErrInvalidAuthenticationChallenge
+		return fmt.Errorf("%w: unsupported scheme: %s", ErrUnsolveableAuthenticationChallenge, challenge.Method)
+	}
+
+	algorithm := challenge.Properties["algorithm"]
+	name := strings.ToUpper(algorithm)
+	if name == "" {
+		name = "MD5"
+	}
+	session := strings.HasSuffix(name, "-SESS")
+	newHash, ok := digestAlgorithms[strings.TrimSuffix(name, "-SESS")]
+	if !ok {
+		return fmt.Errorf("%w: unsupported algorithm: %s", ErrUnsolveableAuthenticationChallenge, algorithm)
+	}
+
+	qop, err := selectQop(challenge.Properties["qop"])
+	if err != nil {
+		return err
+	}
+
+	digest := func(s string) string {
+		h := newHash()
+		h.Write([]byte(s))
+		return hex.EncodeToString(h.Sum(nil))
+	}
+
+	realm := challenge.Properties["realm"]
+	nonce := challenge.Properties["nonce"]
+
+	var cnonce, nc string
+	if qop != "" || session {
+		cnonce = h.cnonce()
+	}
+	if qop != "" {
+		nc = fmt.Sprintf("%08x", h.nextNonceCount(nonce))
+	}
+
+	ha1 := digest(fmt.Sprintf("%s:%s:%s", h.username, realm, h.password))
+	if session {
+		ha1 = digest(fmt.Sprintf("%s:%s:%s", ha1, nonce, cnonce))
 	}
 
-	h1Sum := md5.Sum([]byte(fmt.Sprintf("%s:%s:%s", h.username, challenge.Properties["realm"], h.password)))
-	h1 := hex.EncodeToString(h1Sum[:])
+	ha2 := digest(fmt.Sprintf("%s:%s", req.Method, uri))
+	if qop == qopAuthInt {
+		ha2 = digest(fmt.Sprintf("%s:%s:%s", req.Method, uri, digest(string(req.Body))))
+	}
 
-	h2Sum := md5.Sum([]byte(fmt.Sprintf("%s:%s", req.Method, req.URI)))
-	h2 := hex.EncodeToString(h2Sum[:])
+	var response string
+	if qop == "" {
+		response = digest(fmt.Sprintf("%s:%s:%s", ha1, nonce, ha2))
+	} else {
+		response = digest(fmt.Sprintf("%s:%s:%s:%s:%s:%s", ha1, nonce, nc, cnonce, qop, ha2))
+	}
 
-	responseSum := md5.Sum([]byte(fmt.Sprintf("%s:%s:%s", h1, challenge.Properties["nonce"], h2)))
-	response := hex.EncodeToString(responseSum[:])
+	var b strings.Builder
+	fmt.Fprintf(&b, `Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`, h.username, realm, nonce, uri, response)
+	if algorithm != "" {
+		fmt.Fprintf(&b, ", algorithm=%s", algorithm)
+	}
+	if cnonce != "" {
+		fmt.Fprintf(&b, `, cnonce="%s"`, cnonce)
+	}
+	if opaque, ok := challenge.Properties["opaque"]; ok {
+		fmt.Fprintf(&b, `, opaque="%s"`, opaque)
+	}
+	if qop != "" {
+		fmt.Fprintf(&b, ", qop=%s, nc=%s", qop, nc)
+	}
 
-	req.Header.Set("Authorization", fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`, h.username, challenge.Properties["realm"], challenge.Properties["nonce"], req.URI, response))
+	req.Header.Set(challenge.authorizationHeader(), b.String())
 
 	return nil
 }
+
+// nextNonceCount returns the next nonce count to use for nonce.
+func (h *digestAuthenticationHandler) nextNonceCount(nonce string) uint32 {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if _, ok := h.nonceCounts[nonce]; !ok && len(h.nonceCounts) >= maxTrackedNonces {
+		h.nonceCounts = make(map[string]uint32)
+	}
+
+	h.nonceCounts[nonce]++
+	return h.nonceCounts[nonce]
+}
+
+// selectQop selects the quality of protection to use from the comma separated list of options offered by
+// the server. auth is preferred over auth-int. An empty string is returned if no qop has been offered.
+func selectQop(offered string) (string, error) {
+	if offered == "" {
+		return "", nil
+	}
+
+	var authInt bool
+	for _, q := range strings.Split(offered, ",") {
+		switch strings.ToLower(strings.TrimSpace(q)) {
+		case qopAuth:
+			return qopAuth, nil
+		case qopAuthInt:
+			authInt = true
+		}
+	}
+
+	if authInt {
+		return qopAuthInt, nil
+	}
+
+	return "", fmt.Errorf("%w: unsupported qop: %s", ErrUnsolveableAuthenticationChallenge, offered)
+}
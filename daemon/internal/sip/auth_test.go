@@ -1,6 +1,8 @@
 package sip
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/go-test/deep"
@@ -23,6 +25,105 @@ func TestParseWWWAuthenticateHeader(t *testing.T) {
 	}
 }
 
+func TestParseWWWAuthenticateHeader_quotedCommas(t *testing.T) {
+	c, err := parseWWWAuthenticateHeader(`Digest realm="pbx, inc.",qop="auth,auth-int", nonce="a\"b", algorithm=SHA-256, stale=TRUE`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := deep.Equal(c, AuthenticationChallenge{
+		Method: "Digest",
+		Properties: map[string]string{
+			"realm":     "pbx, inc.",
+			"qop":       "auth,auth-int",
+			"nonce":     `a"b`,
+			"algorithm": "SHA-256",
+			"stale":     "TRUE",
+		},
+	}); diff != nil {
+		t.Error(diff)
+	}
+
+	if !c.Stale() {
+		t.Error("expected challenge to be stale")
+	}
+}
+
+func TestParseWWWAuthenticateHeader_unterminatedQuote(t *testing.T) {
+	if _, err := parseWWWAuthenticateHeader(`Digest realm="pbx`); !errors.Is(err, ErrInvalidAuthenticationChallenge) {
+		t.Errorf("expected invalid challenge but got %v", err)
+	}
+}
+
+func TestChallengeSolver_strongestChallenge(t *testing.T) {
+	uri, err := ParseURI("sip:test@localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := resp("SIP/2.0 407 Proxy Authentication Required\r\n" +
+		"Proxy-Authenticate: Digest realm=\"pbx\", nonce=\"1\", algorithm=MD5\r\n" +
+		"Proxy-Authenticate: Digest realm=\"pbx\", nonce=\"2\", algorithm=SHA-1\r\n" +
+		"Proxy-Authenticate: Digest realm=\"pbx\", nonce=\"3\", algorithm=SHA-256, qop=\"auth,auth-int\"\r\n" +
+		"Content-Length: 0\r\n\r\n")
+
+	s := newChallengeSolver([]AuthenticationHandler{NewDigestHandler("user", "password")})
+	r := NewRequest("INVITE", uri)
+
+	ok, err := s.solve(res, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("expected challenge to be solved")
+	}
+
+	if r.Header.Get("Authorization") != "" {
+		t.Errorf("expected no Authorization header")
+	}
+
+	got := r.Header.Get("Proxy-Authorization")
+	if !strings.Contains(got, `nonce="3"`) || !strings.Contains(got, "algorithm=SHA-256") || !strings.Contains(got, "qop=auth,") {
+		t.Errorf("expected SHA-256 challenge to be solved but got '%s'", got)
+	}
+
+	// A second, non-stale challenge of the same kind means the credentials have been rejected.
+	ok, err = s.solve(res, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("expected rejected credentials not to be resent")
+	}
+}
+
+func TestChallengeSolver_mixedSchemes(t *testing.T) {
+	uri, err := ParseURI("sip:test@localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := resp("SIP/2.0 401 Unauthorized\r\n" +
+		"WWW-Authenticate: Basic realm=\"pbx\"\r\n" +
+		"WWW-Authenticate: Digest realm=\"pbx\", nonce=\"1\"\r\n" +
+		"Content-Length: 0\r\n\r\n")
+
+	s := newChallengeSolver([]AuthenticationHandler{NewDigestHandler("user", "password")})
+	r := NewRequest("INVITE", uri)
+
+	ok, err := s.solve(res, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("expected challenge to be solved")
+	}
+
+	if got := r.Header.Get("Authorization"); !strings.HasPrefix(got, "Digest ") || !strings.Contains(got, `nonce="1"`) {
+		t.Errorf("expected Digest challenge to be solved but got '%s'", got)
+	}
+}
+
 func TestDigestAuthenticationHandler(t *testing.T) {
 	uri, err := ParseURI("sip:test@localhost")
 	if err != nil {
@@ -43,10 +144,102 @@ func TestDigestAuthenticationHandler(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	exp := `Digest username="user", realm="", nonce="123456789", uri="sip:test@localhost:5060", response="6f2dfa09fb298150e9195a987182a7e0"`
+	exp := `Digest username="user", realm="", nonce="123456789", uri="sip:test@localhost", response="847e487d0721057778427f8814c79f41"`
 	got := r.Header.Get("Authorization")
 
 	if got != exp {
 		t.Errorf("expected '%s' but got '%s'", exp, got)
 	}
 }
+
+func TestDigestAuthenticationHandler_qop(t *testing.T) {
+	// Test vectors taken from RFC 7616 section 3.9.1 using SIP request fields instead of HTTP ones.
+	tests := []struct {
+		algorithm string
+		exp       string
+	}{
+		{"MD5", "8ca523f5e9506fed4657c9700eebdbec"},
+		{"SHA-256", "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.algorithm, func(t *testing.T) {
+			h := NewDigestHandler("Mufasa", "Circle of Life").(*digestAuthenticationHandler)
+			h.cnonce = func() string { return "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ" }
+
+			r := &Request{Method: "GET", URI: URI{}, Header: Header{}}
+			c := AuthenticationChallenge{
+				Method: "Digest",
+				Properties: map[string]string{
+					"realm":     "http-auth@example.org",
+					"qop":       "auth",
+					"algorithm": test.algorithm,
+					"nonce":     "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v",
+					"opaque":    "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS",
+				},
+			}
+
+			if err := h.solve(c, r, "/dir/index.html"); err != nil {
+				t.Fatal(err)
+			}
+
+			exp := `Digest username="Mufasa", realm="http-auth@example.org", nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", uri="/dir/index.html", response="` + test.exp + `", algorithm=` + test.algorithm + `, cnonce="f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS", qop=auth, nc=00000001`
+			if got := r.Header.Get("Authorization"); got != exp {
+				t.Errorf("expected '%s' but got '%s'", exp, got)
+			}
+		})
+	}
+}
+
+func TestDigestAuthenticationHandler_nonceCount(t *testing.T) {
+	uri, err := ParseURI("sip:test@localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewDigestHandler("user", "password")
+	c := AuthenticationChallenge{
+		Method: "Digest",
+		Properties: map[string]string{
+			"nonce":     "123456789",
+			"qop":       "auth",
+			"algorithm": "SHA-256-sess",
+		},
+	}
+
+	for _, exp := range []string{"nc=00000001", "nc=00000002"} {
+		r := NewRequest("INVITE", uri)
+		if err := h.Solve(c, r); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := r.Header.Get("Authorization"); !strings.HasSuffix(got, exp) {
+			t.Errorf("expected '%s' to end with '%s'", got, exp)
+		}
+	}
+}
+
+func TestDigestAuthenticationHandler_unsupportedAlgorithm(t *testing.T) {
+	uri, err := ParseURI("sip:test@localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewDigestHandler("user", "password")
+	c := AuthenticationChallenge{
+		Method: "Digest",
+		Properties: map[string]string{
+			"nonce":     "123456789",
+			"algorithm": "SHA-1",
+		},
+	}
+
+	if err := h.Solve(c, NewRequest("INVITE", uri)); !errors.Is(err, ErrUnsolveableAuthenticationChallenge) {
+		t.Errorf("expected unsolveable challenge but got %v", err)
+	}
+
+	c = AuthenticationChallenge{Method: "Basic", Properties: map[string]string{"realm": "pbx"}}
+	if err := h.Solve(c, NewRequest("INVITE", uri)); !errors.Is(err, ErrUnsolveableAuthenticationChallenge) {
+		t.Errorf("expected unsolveable Basic challenge but got %v", err)
+	}
+}
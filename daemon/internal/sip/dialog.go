@@ -1,161 +1,821 @@
 package sip
 
 import (
-	"errors"
+	"context"
 	"fmt"
-	"net/http"
+	"net"
+	"net/textproto"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/halimath/raspidoor/daemon/internal/sdp"
 )
 
 const (
 	StatusOK               = 200
+	StatusCallDoesNotExist = 481
+	StatusUnsupportedMedia = 415
 	StatusRequestCancelled = 487
+	StatusNotImplemented   = 501
 	StatusDecline          = 603
 )
 
+// transactionTimeout bounds the time to wait for a final response to non-INVITE requests and cancelled
+// INVITEs; it equals Timer F (64*T1).
+const transactionTimeout = 64 * DefaultT1
+
+// ContentTypeDTMFRelay is the content type of INFO requests carrying DTMF digits.
+const ContentTypeDTMFRelay = "application/dtmf-relay"
+
+// dtmfBufferSize is the number of DTMF digits buffered until the AnswerHandler consumes them.
+const dtmfBufferSize = 32
+
+// Result describes the outcome of ringing a callee.
+type Result int
+
+const (
+	// ResultFailed is returned along with an error if the call failed due to a technical reason.
+	ResultFailed Result = iota
+	// ResultAnswered is returned if the callee answered the call.
+	ResultAnswered
+	// ResultDeclined is returned if the callee (or the server) rejected the call.
+	ResultDeclined
+	// ResultNotAnswered is returned if the callee did not answer the call in time.
+	ResultNotAnswered
+	// ResultBusy is returned if the callee is busy (486 Busy Here or 600 Busy Everywhere).
+	ResultBusy
+	// ResultRedirected is returned if the callee redirected the call but the redirect could not be followed,
+	// i.e. because of a redirect loop.
+	ResultRedirected
+)
+
+func (r Result) String() string {
+	switch r {
+	case ResultFailed:
+		return "failed"
+	case ResultAnswered:
+		return "answered"
+	case ResultDeclined:
+		return "declined"
+	case ResultNotAnswered:
+		return "not answered"
+	case ResultBusy:
+		return "busy"
+	case ResultRedirected:
+		return "redirected"
+	default:
+		return fmt.Sprintf("unknown (%d)", int(r))
+	}
+}
+
+// DialogState enumerates the states of a Dialog as defined in RFC 3261 section 12.
+type DialogState int
+
+const (
+	// DialogStateInit is the state of a dialog before the callee responded with a To tag.
+	DialogStateInit DialogState = iota
+	// DialogStateEarly is the state of a dialog established by a provisional response.
+	DialogStateEarly
+	// DialogStateConfirmed is the state of a dialog established by a 2xx response.
+	DialogStateConfirmed
+	// DialogStateTerminated is the state of a dialog that has been rejected, cancelled or hung up.
+	DialogStateTerminated
+)
+
+func (s DialogState) String() string {
+	switch s {
+	case DialogStateInit:
+		return "init"
+	case DialogStateEarly:
+		return "early"
+	case DialogStateConfirmed:
+		return "confirmed"
+	case DialogStateTerminated:
+		return "terminated"
+	default:
+		return fmt.Sprintf("unknown (%d)", int(s))
+	}
+}
+
+// Call describes an answered call.
+type Call struct {
+	// Media is the negotiated media session.
+	Media *sdp.Negotiation
+
+	// RTP is the local socket announced in the SDP offer. Use it to exchange RTP packets with Media.Remote.
+	RTP net.PacketConn
+
+	// DTMF receives the digits sent by the remote party via SIP INFO requests. Digits sent as RTP telephone
+	// events (Media.TelephoneEvent) must be received from RTP.
+	DTMF <-chan rune
+
+	// Peer is the remote party, i.e. the callee of outgoing and the caller of incoming calls.
+	Peer URI
+}
+
+// AnswerHandler handles an answered call, i.e. by playing an announcement. The call is hung up once the
+// handler returns. ctx is cancelled when the callee hangs up.
+type AnswerHandler func(ctx context.Context, call *Call) error
+
+// Dialog implements the caller's side of a single call. A Dialog must not be used to ring more than once.
 type Dialog struct {
-	transport              Transport
-	caller                 URI
-	authenticationHandlers []AuthenticationHandler
-	cseq                   int
-	callID                 string
+	transport  Transport
+	caller     URI
+	callee     URI
+	contact    URI
+	challenges *challengeSolver
+
+	answerHandler AnswerHandler
+	confirmed     func() bool
+
+	callID    string
+	localTag  string
+	remoteTag string
+	cseq      int
+
+	// target is the remote target taken from the Contact of the 2xx response and routes the route set taken
+	// from its Record-Route headers (RFC 3261 section 12.1.2); target is the zero URI until the dialog is
+	// confirmed.
+	target URI
+	routes []URI
+
+	// ack is the ACK sent for the 2xx response which is resent for retransmissions of the response; nil until
+	// sent.
+	ack     *Request
+	ackLock sync.Mutex
+
+	state DialogState
+	// provisional is set once a provisional response to the INVITE has been received; an INVITE must not be
+	// cancelled before.
+	provisional bool
+
+	offer *sdp.Session
+	media *sdp.Negotiation
+	rtp   net.PacketConn
+
+	// final is the last non-2xx final response to the INVITE; nil if none has been received.
+	final *Response
+
+	// byeReceived is closed when the callee hangs up.
+	byeReceived chan struct{}
+	byeOnce     sync.Once
+
+	// dtmf receives the digits sent via INFO requests; infoCSeq is the CSeq of the last INFO request and
+	// used to discard retransmissions.
+	dtmf     chan rune
+	infoCSeq int
 }
 
 func NewDialog(transport Transport, caller URI, authenticationHandlers ...AuthenticationHandler) *Dialog {
 	return &Dialog{
-		transport:              transport,
-		caller:                 caller,
-		authenticationHandlers: authenticationHandlers,
-		cseq:                   1,
-		callID:                 fmt.Sprintf("c%d", time.Now().Unix()),
+		transport:  transport,
+		caller:     caller,
+		challenges: newChallengeSolver(authenticationHandlers),
+		callID:     randomToken(12),
+		localTag:   newTag(),
+		cseq:       1,
+
+		byeReceived: make(chan struct{}),
+		dtmf:        make(chan rune, dtmfBufferSize),
 	}
 }
 
-func (d *Dialog) Ring(callee URI, maxRingingTime time.Duration) (bool, error) {
-	inviteRequest := d.invite(callee, maxRingingTime)
-	con, err := d.transport.Send(inviteRequest)
+// OnAnswer sets the handler invoked when the callee answers the call. If no handler is set or no media
+// session could be negotiated, answered calls are hung up immediately. OnAnswer must be called before Ring.
+func (d *Dialog) OnAnswer(h AnswerHandler) {
+	d.answerHandler = h
+}
+
+// OnConfirm sets the function invoked when the callee answers the call, even if no media session could be
+// negotiated. The call is hung up immediately if it returns false. OnConfirm must be called before Ring.
+func (d *Dialog) OnConfirm(f func() bool) {
+	d.confirmed = f
+}
+
+// State returns the dialog's current state.
+func (d *Dialog) State() DialogState {
+	return d.state
+}
+
+// Media returns the media session negotiated with the callee; nil if the callee did not send a usable SDP
+// answer.
+func (d *Dialog) Media() *sdp.Negotiation {
+	return d.media
+}
+
+// Ring calls callee and waits for maxRingingTime for the call to be answered. If the call is not answered
+// in time, the INVITE is cancelled and ResultNotAnswered is returned. Answered calls are passed to the
+// AnswerHandler and hung up once it returns unless the callee hung up before. If ctx is cancelled while
+// ringing, the call is cancelled as well and ctx's error is returned.
+//
+// Redirects (3xx) are followed to the most preferred Contact not rung before; maxRingingTime applies to all
+// targets. ResultRedirected is returned if there is no such Contact or too many redirects occurred.
+func (d *Dialog) Ring(ctx context.Context, callee URI, maxRingingTime time.Duration) (Result, error) {
+	ringCtx, cancel := context.WithTimeout(ctx, maxRingingTime)
+	defer cancel()
+
+	rung := map[string]bool{}
+	for redirects := 0; ; redirects++ {
+		d.callee = callee
+		rung[callee.String()] = true
+
+		result, err := d.ring(ctx, ringCtx)
+		if err != nil || result != ResultRedirected {
+			return result, err
+		}
+
+		if ringCtx.Err() != nil {
+			return ResultNotAnswered, nil
+		}
+
+		if redirects == maxRedirects {
+			return ResultRedirected, nil
+		}
+
+		next, ok := nextTarget(redirectTargets(d.final), rung)
+		if !ok {
+			return ResultRedirected, nil
+		}
+
+		callee = next
+		d.redirect()
+	}
+}
+
+// Callee returns the callee rung last, which differs from the URI passed to Ring if the call has been
+// redirected.
+func (d *Dialog) Callee() URI {
+	return d.callee
+}
+
+// RetryAfter returns the time the callee asked to wait before calling again as given by the Retry-After
+// header of the final response, i.e. along with 486 Busy Here or 503 Service Unavailable; 0 if none.
+func (d *Dialog) RetryAfter() time.Duration {
+	if d.final == nil {
+		return 0
+	}
+	return retryAfter(d.final)
+}
+
+// ring sends the INVITE to the dialog's callee and waits until ringCtx is done for the call to be answered.
+func (d *Dialog) ring(ctx, ringCtx context.Context) (Result, error) {
+	con, err := d.transport.Dial(ringCtx, d.callee)
 	if err != nil {
-		return false, err
+		d.state = DialogStateTerminated
+		return ResultFailed, err
 	}
 	defer con.Close()
 
-	inviteResponse, err := RecvFinal(con)
+	con.Handle(d.handle)
+
+	localIP := addrIP(con.LocalAddr())
+	d.contact = NewURI(d.caller.Scheme, d.caller.Address, localIP.String(), addrPort(con.LocalAddr()))
+
+	d.rtp, err = net.ListenPacket("udp", net.JoinHostPort(localIP.String(), "0"))
 	if err != nil {
-		return false, err
+		d.state = DialogStateTerminated
+		return ResultFailed, fmt.Errorf("failed to open RTP socket: %w", err)
 	}
+	defer d.rtp.Close()
 
-	var authenticationChallenge AuthenticationChallenge
+	d.offer = sdp.NewOffer(localIP, addrPort(d.rtp.LocalAddr()), sdp.PCMU, sdp.PCMA, sdp.TelephoneEvent)
 
-	if inviteResponse.StatusCode == http.StatusUnauthorized {
-		authenticationChallenge, err = parseWWWAuthenticateHeader(inviteResponse.Header.Get("WWW-Authenticate"))
-		if err != nil {
-			return false, err
+	deadline, _ := ringCtx.Deadline()
+	inviteRequest := d.invite(time.Until(deadline).Round(time.Second))
+	if err := con.Send(inviteRequest); err != nil {
+		d.state = DialogStateTerminated
+		return ResultFailed, err
+	}
+
+	responses := receive(con, func(res *Response) bool { return d.resendAck(con, res) })
+	defer responses.stop()
+
+	inviteResponse, err := d.inviteResponse(ringCtx, responses)
+	if err != nil {
+		return d.abort(ctx, ringCtx, con, responses, inviteRequest, err)
+	}
+
+	for attempt := 0; isChallenge(inviteResponse.StatusCode) && attempt < maxAuthenticationAttempts; attempt++ {
+		// The challenge completes the INVITE transaction which must be acknowledged before sending a new one.
+		if err := con.Send(d.failureAck(inviteRequest, inviteResponse)); err != nil {
+			return ResultFailed, err
 		}
 
-		if err := d.authenticate(inviteRequest, authenticationChallenge); err != nil {
-			return false, err
+		ok, err := d.authenticate(inviteRequest, inviteResponse)
+		if err != nil {
+			return ResultFailed, err
+		}
+		if !ok {
+			return ResultDeclined, nil
 		}
 
 		if err := con.Send(inviteRequest); err != nil {
-			return false, err
+			return ResultFailed, err
 		}
-		inviteResponse, err = RecvFinal(con)
+		inviteResponse, err = d.inviteResponse(ringCtx, responses)
 		if err != nil {
-			return false, err
+			return d.abort(ctx, ringCtx, con, responses, inviteRequest, err)
+		}
+	}
+
+	return d.complete(ctx, con, responses, inviteRequest, inviteResponse)
+}
+
+// redirect prepares the dialog to send a new INVITE after a redirect. The new INVITE keeps the Call-ID and
+// the local tag but uses a new CSeq (RFC 3261 section 8.1.3.4).
+func (d *Dialog) redirect() {
+	d.state = DialogStateInit
+	d.remoteTag = ""
+	d.provisional = false
+	d.media = nil
+	d.final = nil
+	d.cseq++
+}
+
+// nextTarget returns the first of targets not rung before.
+func nextTarget(targets []URI, rung map[string]bool) (URI, bool) {
+	for _, t := range targets {
+		if !rung[t.String()] {
+			return t, true
 		}
 	}
+	return URI{}, false
+}
+
+// abort handles err which occurred while waiting for a final response to invite. If err has been caused by
+// ringCtx being done - either because ringing timed out or because ctx has been cancelled - the INVITE is
+// cancelled.
+func (d *Dialog) abort(ctx, ringCtx context.Context, con Connection, responses *receiver, invite *Request, err error) (Result, error) {
+	if ringCtx.Err() == nil {
+		return ResultFailed, err
+	}
+
+	cleanupCtx, cancel := context.WithTimeout(context.Background(), transactionTimeout)
+	defer cancel()
+
+	res, err := d.cancel(cleanupCtx, con, responses, invite)
+	if err == nil {
+		// The callee may have answered (or declined) before the INVITE has been cancelled.
+		var result Result
+		result, err = d.complete(ctx, con, responses, invite, res)
+		if err == nil && ctx.Err() == nil {
+			return result, nil
+		}
+	}
+
+	if ctx.Err() != nil {
+		return ResultFailed, ctx.Err()
+	}
+
+	return ResultFailed, err
+}
+
+// complete completes the INVITE transaction after receiving the final response res. A 2xx response is
+// acknowledged and the established dialog is passed to the AnswerHandler before hanging up. Any other
+// response is acknowledged within the INVITE transaction. ctx bounds the time the AnswerHandler may take.
+func (d *Dialog) complete(ctx context.Context, con Connection, responses *receiver, invite *Request, res *Response) (Result, error) {
+	if res.StatusCode > 299 {
+		d.final = res
+		if err := con.Send(d.failureAck(invite, res)); err != nil {
+			return ResultFailed, err
+		}
 
-	accepted := inviteResponse.StatusCode == StatusOK
+		switch {
+		case res.StatusCode == StatusRequestCancelled:
+			return ResultNotAnswered, nil
+		case res.StatusCode < 400:
+			return ResultRedirected, nil
+		case res.StatusCode == StatusBusyHere || res.StatusCode == StatusBusyEverywhere:
+			return ResultBusy, nil
+		case res.StatusCode == StatusServiceUnavailable:
+			return ResultFailed, fmt.Errorf("%w: %d %s", ErrServiceUnavailable, res.StatusCode, res.StatusMessage)
+		default:
+			return ResultDeclined, nil
+		}
+	}
 
-	to := inviteResponse.Header.Get("To")
+	ack, err := d.createAck()
+	if err != nil {
+		return ResultFailed, err
+	}
 
-	ack, err := d.ack(callee, to, authenticationChallenge)
+	d.ackLock.Lock()
+	err = con.Send(ack)
+	d.ack = ack
+	d.ackLock.Unlock()
 	if err != nil {
-		return false, err
+		return ResultFailed, err
+	}
+
+	var answerErr error
+	if d.confirmed == nil || d.confirmed() {
+		answerErr = d.converse(ctx)
+	}
+
+	select {
+	case <-d.byeReceived:
+		d.state = DialogStateTerminated
+	default:
+		if err := d.hangUp(con, responses); err != nil {
+			return ResultFailed, err
+		}
+	}
+
+	if answerErr != nil {
+		return ResultFailed, fmt.Errorf("failed to handle answered call: %w", answerErr)
+	}
+
+	return ResultAnswered, nil
+}
+
+// converse passes the answered call to the AnswerHandler and waits for it to return. The handler's context
+// is cancelled when ctx is done or the callee hangs up; the resulting context error is not reported.
+func (d *Dialog) converse(ctx context.Context) error {
+	if d.answerHandler == nil || d.media == nil {
+		return nil
 	}
 
-	if err := con.Send(ack); err != nil {
-		return false, err
+	callCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-d.byeReceived:
+			cancel()
+		case <-callCtx.Done():
+		}
+	}()
+
+	err := d.answerHandler(callCtx, &Call{Media: d.media, RTP: d.rtp, DTMF: d.dtmf, Peer: d.callee})
+	if callCtx.Err() != nil {
+		return nil
 	}
 
+	return err
+}
+
+// handle handles requests sent by the callee.
+func (d *Dialog) handle(req *Request) *Response {
+	if req.Header.Get("Call-ID") != d.callID {
+		return NewResponse(req, StatusCallDoesNotExist, "Call/Transaction Does Not Exist")
+	}
+
+	switch req.Method {
+	case "BYE":
+		d.byeOnce.Do(func() { close(d.byeReceived) })
+		return NewResponse(req, StatusOK, "OK")
+	case "INFO":
+		return receiveDTMFInfo(req, d.dtmf, &d.infoCSeq)
+	default:
+		return NewResponse(req, StatusNotImplemented, "Not Implemented")
+	}
+}
+
+// receiveDTMFInfo handles an INFO request carrying a DTMF digit as application/dtmf-relay and sends the digit
+// to dtmf. lastCSeq holds the CSeq of the last INFO request and is used to ignore retransmissions. Digits are
+// dropped if they are not consumed.
+func receiveDTMFInfo(req *Request, dtmf chan<- rune, lastCSeq *int) *Response {
+	contentType := strings.TrimSpace(strings.Split(req.Header.Get("Content-Type"), ";")[0])
+	if len(req.Body) > 0 && !strings.EqualFold(contentType, ContentTypeDTMFRelay) {
+		res := NewResponse(req, StatusUnsupportedMedia, "Unsupported Media Type")
+		res.Header.Set("Accept", ContentTypeDTMFRelay)
+		return res
+	}
+
+	cseq, _ := strconv.Atoi(strings.Fields(req.Header.Get("CSeq") + " ")[0])
+	if cseq > *lastCSeq {
+		*lastCSeq = cseq
+
+		if digit, ok := parseDTMFRelay(req.Body); ok {
+			select {
+			case dtmf <- digit:
+			default:
+			}
+		}
+	}
+
+	return NewResponse(req, StatusOK, "OK")
+}
+
+// parseDTMFRelay parses the digit from an application/dtmf-relay body such as
+//
+//	Signal=5
+//	Duration=160
+func parseDTMFRelay(body []byte) (rune, bool) {
+	for _, l := range strings.Split(string(body), "\n") {
+		parts := strings.SplitN(l, "=", 2)
+		if len(parts) != 2 || !strings.EqualFold(strings.TrimSpace(parts[0]), "Signal") {
+			continue
+		}
+
+		signal := strings.ToUpper(strings.TrimSpace(parts[1]))
+		switch {
+		case signal == "10":
+			return '*', true
+		case signal == "11":
+			return '#', true
+		case len(signal) == 1 && strings.Contains("0123456789*#ABCD", signal):
+			return rune(signal[0]), true
+		}
+		return 0, false
+	}
+
+	return 0, false
+}
+
+// hangUp terminates the confirmed dialog by sending a BYE.
+func (d *Dialog) hangUp(con Connection, responses *receiver) error {
+	ctx, cancel := context.WithTimeout(context.Background(), transactionTimeout)
+	defer cancel()
+
 	d.cseq++
 
-	bye := d.request("BYE", callee)
-	bye.Header.Set("To", to)
-	if err := con.Send(bye); err != nil {
-		return false, err
+	if err := con.Send(d.request("BYE")); err != nil {
+		return err
 	}
 
-	resp, err := RecvFinal(con)
+	res, err := responses.final(ctx, "BYE")
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	if resp.StatusCode != StatusOK {
-		return false, fmt.Errorf("Got unexpected status from BYE: %d", resp.StatusCode)
+	d.state = DialogStateTerminated
+
+	if res.StatusCode != StatusOK {
+		return fmt.Errorf("Got unexpected status from BYE: %d", res.StatusCode)
 	}
 
-	return accepted, nil
+	return nil
 }
 
-func (d *Dialog) invite(callee URI, maxRingingTime time.Duration) *Request {
-	r := d.request("INVITE", callee)
-	r.Header.Add("Expires", strconv.Itoa(int(maxRingingTime.Seconds())))
-	return r
+// cancel cancels the pending INVITE transaction started with invite following RFC 3261 section 9.1. It
+// waits for a provisional response before sending the CANCEL and returns the final response to the INVITE,
+// which is expected to be 487 Request Terminated.
+func (d *Dialog) cancel(ctx context.Context, con Connection, responses *receiver, invite *Request) (*Response, error) {
+	for !d.provisional {
+		res, err := d.next(ctx, responses)
+		if err != nil {
+			return nil, err
+		}
+
+		if res.StatusCode > 199 {
+			// The INVITE completed before it could be cancelled.
+			return res, nil
+		}
+	}
+
+	cancel := NewRequest("CANCEL", invite.URI)
+	copyHeaders(cancel, invite, "Via", "From", "To", "Call-ID", "Max-Forwards")
+	cancel.Header.Set("CSeq", fmt.Sprintf("%d CANCEL", d.cseq))
+
+	if err := con.Send(cancel); err != nil {
+		return nil, err
+	}
+
+	// The callee may have answered (or declined) while the CANCEL was in transit.
+	return d.inviteResponse(ctx, responses)
 }
 
-func (d *Dialog) ack(callee URI, to string, authenticationChallenge AuthenticationChallenge) (*Request, error) {
-	r := d.request("ACK", callee)
-	r.Header.Set("To", to)
-	r.SetBody("application/sdp", []byte(d.formatSDP()))
+// inviteResponse returns the next final response to the INVITE.
+func (d *Dialog) inviteResponse(ctx context.Context, responses *receiver) (*Response, error) {
+	for {
+		res, err := d.next(ctx, responses)
+		if err != nil {
+			return nil, err
+		}
+
+		if res.StatusCode > 199 {
+			return res, nil
+		}
+	}
+}
 
-	if authenticationChallenge.Method != "" {
-		err := d.authenticate(r, authenticationChallenge)
+// next returns the next response to the INVITE and updates the dialog's state. Responses to other requests
+// are discarded.
+func (d *Dialog) next(ctx context.Context, responses *receiver) (*Response, error) {
+	for {
+		res, err := responses.next(ctx)
 		if err != nil {
 			return nil, err
 		}
+
+		if m := cseqMethod(res); m != "" && m != "INVITE" {
+			continue
+		}
+
+		d.update(res)
+		return res, nil
 	}
+}
 
-	return r, nil
+// update updates the dialog's state from res which is a response to the INVITE.
+func (d *Dialog) update(res *Response) {
+	tag := res.Header.Param("To", "tag")
+
+	if res.StatusCode < 300 {
+		d.negotiate(res)
+	}
+
+	switch {
+	case res.StatusCode < 200:
+		d.provisional = true
+		// Provisional responses without a tag (i.e. 100 Trying) do not establish a dialog.
+		if tag != "" && d.state == DialogStateInit {
+			d.state = DialogStateEarly
+			d.remoteTag = tag
+		}
+	case res.StatusCode < 300:
+		d.state = DialogStateConfirmed
+		d.remoteTag = tag
+		d.target, d.routes = remoteTarget(res)
+	default:
+		d.state = DialogStateTerminated
+	}
+}
+
+// negotiate negotiates the media session from the SDP answer contained in res, if any. Answers that cannot
+// be parsed or do not match the offer are ignored.
+func (d *Dialog) negotiate(res *Response) {
+	contentType := strings.TrimSpace(strings.Split(res.Header.Get("Content-Type"), ";")[0])
+	if len(res.Body) == 0 || !strings.EqualFold(contentType, sdp.ContentType) {
+		return
+	}
+
+	answer, err := sdp.Parse(res.Body)
+	if err != nil {
+		return
+	}
+
+	if media, err := sdp.Negotiate(d.offer, answer); err == nil {
+		d.media = media
+	}
+}
+
+func (d *Dialog) invite(maxRingingTime time.Duration) *Request {
+	r := d.request("INVITE")
+	r.Header.Add("Expires", strconv.Itoa(int(maxRingingTime.Seconds())))
+	r.SetBody(sdp.ContentType, d.offer.Marshal())
+	return r
+}
+
+// resendAck resends the ACK if res is a retransmission of the 2xx response to the INVITE that has already been
+// acknowledged. It reports whether res has been handled.
+func (d *Dialog) resendAck(con Connection, res *Response) bool {
+	if res.StatusCode < 200 || res.StatusCode > 299 || cseqMethod(res) != "INVITE" {
+		return false
+	}
+
+	d.ackLock.Lock()
+	defer d.ackLock.Unlock()
+
+	if d.ack == nil {
+		return false
+	}
+
+	// A failing ACK is resent on the next retransmission.
+	con.Send(d.ack)
+	return true
 }
 
-func (d *Dialog) formatSDP() string {
-	sessionId := time.Now().UnixMilli()
-	version := sessionId
+// createAck creates the ACK for a 2xx response which is sent as a transaction of its own.
+func (d *Dialog) createAck() (*Request, error) {
+	r := d.request("ACK")
 
-	addr := "fe80::b644:fe20:c499:dd4e"
+	if err := d.challenges.authenticate(r); err != nil {
+		return nil, err
+	}
 
-	return fmt.Sprintf("v=0\r\no=klingel1 %d %d IN IP6 %s\r\ns=\r\nc=IN IP6 %s\r\nt=0 0\r\n", sessionId, version, addr, addr)
+	return r, nil
 }
 
-func (d *Dialog) request(method string, callee URI) *Request {
-	req := NewRequest(method, callee)
-	req.Header.Set("From", d.caller.String())
-	req.Header.Set("To", callee.String())
-	req.Header.Set("Contact", d.caller.String())
+// failureAck creates the ACK for the non-2xx final response res to invite following RFC 3261 section
+// 17.1.1.3. The ACK is part of the INVITE transaction and thus uses the INVITE's branch and credentials.
+func (d *Dialog) failureAck(invite *Request, res *Response) *Request {
+	ack := NewRequest("ACK", invite.URI)
+	copyHeaders(ack, invite, "Via", "From", "Call-ID", "Max-Forwards", "Authorization", "Proxy-Authorization")
+	ack.Header.Set("To", res.Header.Get("To"))
+	ack.Header.Set("CSeq", fmt.Sprintf("%d ACK", d.cseq))
+	return ack
+}
+
+// request creates a new request within the dialog. Once the dialog is confirmed, the request is sent to the
+// remote target via the route set following RFC 3261 section 12.2.1.1.
+func (d *Dialog) request(method string) *Request {
+	to := fmt.Sprintf("<%s>", d.callee)
+	if d.remoteTag != "" {
+		to += ";tag=" + d.remoteTag
+	}
+
+	uri, routes := d.callee, d.routes
+	if d.target.Host != "" {
+		uri = d.target
+	}
+
+	if len(routes) > 0 && !routes[0].Params.Has("lr") {
+		// The first route is a strict router which expects the remote target as the last route.
+		uri, routes = routes[0], append(append([]URI(nil), routes[1:]...), uri)
+	}
+
+	req := NewRequest(method, uri)
+	for _, r := range routes {
+		req.Header.Add("Route", fmt.Sprintf("<%s>", r))
+	}
+	req.Header.Set("From", fmt.Sprintf("<%s>;tag=%s", d.caller, d.localTag))
+	req.Header.Set("To", to)
+	req.Header.Set("Contact", fmt.Sprintf("<%s>", d.contact))
 	req.Header.Set("Max-Forwards", "70")
-	req.Header.Set("Cseq", fmt.Sprintf("%d %s", d.cseq, req.Method))
+	req.Header.Set("CSeq", fmt.Sprintf("%d %s", d.cseq, req.Method))
 	req.Header.Set("Call-ID", d.callID)
 
 	return req
 }
 
-func (d *Dialog) authenticate(req *Request, c AuthenticationChallenge) error {
-	for _, h := range d.authenticationHandlers {
-		err := h.Solve(c, req)
-		if err == nil {
-			d.cseq++
-			req.Header.Set("Cseq", fmt.Sprintf("%d %s", d.cseq, req.Method))
-			return nil
+// remoteTarget returns the remote target and route set of the dialog established by the 2xx response res: the
+// target is taken from the Contact header and the route set from the Record-Route headers in reverse order
+// (RFC 3261 section 12.1.2). The target is the zero URI if res contains no valid Contact.
+func remoteTarget(res *Response) (URI, []URI) {
+	var target URI
+	if contacts := splitHeaderValues(res.Header.Get("Contact")); len(contacts) > 0 {
+		if a, err := ParseNameAddr(contacts[0]); err == nil {
+			target = a.URI
 		}
+	}
 
-		if errors.Is(err, ErrUnsolveableAuthenticationChallenge) {
-			continue
+	var routes []URI
+	for _, v := range res.Header[textproto.CanonicalMIMEHeaderKey("Record-Route")] {
+		for _, r := range splitHeaderValues(v) {
+			a, err := ParseNameAddr(r)
+			if err != nil {
+				continue
+			}
+			routes = append([]URI{a.URI}, routes...)
 		}
+	}
 
-		return err
+	return target, routes
+}
+
+// authenticate solves the challenges from res for req and prepares req to be resent as a new transaction.
+// It returns false if req should not be resent.
+func (d *Dialog) authenticate(req *Request, res *Response) (bool, error) {
+	ok, err := d.challenges.solve(res, req)
+	if !ok || err != nil {
+		return ok, err
+	}
+
+	// The challenge terminated any early dialog; the authenticated INVITE may establish a new one.
+	d.state = DialogStateInit
+	d.remoteTag = ""
+	d.provisional = false
+
+	d.cseq++
+	req.Header.Set("CSeq", fmt.Sprintf("%d %s", d.cseq, req.Method))
+	// The authenticated request starts a new transaction and thus requires a new branch.
+	req.Header.Del("Via")
+	return true, nil
+}
+
+// addrIP returns the IP address of addr.
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP
+	case *net.TCPAddr:
+		return a.IP
+	}
+
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return net.ParseIP(addr.String())
+	}
+	return net.ParseIP(host)
+}
+
+// addrPort returns the port of addr; 0 if addr does not contain a port.
+func addrPort(addr net.Addr) int {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.Port
+	case *net.TCPAddr:
+		return a.Port
 	}
 
-	return ErrUnsolveableAuthenticationChallenge
+	_, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return 0
+	}
+	p, _ := strconv.Atoi(port)
+	return p
+}
+
+// copyHeaders copies all values of the given headers from src to dst.
+func copyHeaders(dst, src *Request, keys ...string) {
+	for _, k := range keys {
+		k = textproto.CanonicalMIMEHeaderKey(k)
+		if vals, ok := src.Header[k]; ok {
+			dst.Header[k] = append([]string(nil), vals...)
+		}
+	}
 }
@@ -1,42 +1,162 @@
 package sip
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/go-test/deep"
 )
 
 type transportMock struct {
 	resps []*Response
+	// calleeResps contains the responses returned by connections to the callee with the given user; resps is
+	// used for all other callees.
+	calleeResps map[string][]*Response
+	// holdUntil holds back the response with the given index until a request with the given method has been
+	// sent.
+	holdUntil map[int]string
+	// incoming contains requests sent by the server once all responses have been returned.
+	incoming []*Request
+	cons     []*connectionMock
 }
 
 var _ Transport = &transportMock{}
 
-func (t *transportMock) Send(r *Request) (Connection, error) {
-	return &connectionMock{
-		reqs:  []*Request{r},
-		resps: t.resps,
-	}, nil
+// mockAddr is used as the local address of connectionMock.
+var mockAddr = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5060}
+
+func (t *transportMock) Dial(ctx context.Context, uri URI) (Connection, error) {
+	resps, ok := t.calleeResps[uri.Address]
+	if !ok {
+		resps = t.resps
+	}
+
+	c := &connectionMock{
+		resps:     resps,
+		holdUntil: t.holdUntil,
+		incoming:  t.incoming,
+	}
+	c.cond = sync.NewCond(&c.lock)
+	t.cons = append(t.cons, c)
+	return c, nil
+}
+
+func (t *transportMock) Send(ctx context.Context, r *Request) (Connection, error) {
+	return send(ctx, t, r)
+}
+
+// snapshot copies r so that recorded requests are not affected when r is modified and resent.
+func snapshot(r *Request) *Request {
+	c := *r
+	c.Header = Header{}
+	for k, v := range r.Header {
+		c.Header[k] = append([]string(nil), v...)
+	}
+	return &c
 }
 
 type connectionMock struct {
+	lock      sync.Mutex
+	cond      *sync.Cond
 	reqs      []*Request
 	respIndex int
 	resps     []*Response
+	holdUntil map[int]string
+	incoming  []*Request
+	answers   []*Response
+	handler   RequestHandler
+	closed    bool
 }
 
 func (c *connectionMock) Send(r *Request) error {
-	c.reqs = append(c.reqs, r)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	setVia(r, "TCP", mockAddr)
+	c.reqs = append(c.reqs, snapshot(r))
+	c.cond.Broadcast()
 	return nil
 }
 
-func (c *connectionMock) Recv() (*Response, error) {
+// Recv returns the next response once it is not held back anymore. Once all responses have been returned,
+// the incoming requests are passed to the handler. It blocks until the connection is closed afterwards.
+func (c *connectionMock) Recv(ctx context.Context) (*Response, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.lock.Lock()
+			c.cond.Broadcast()
+			c.lock.Unlock()
+		case <-stop:
+		}
+	}()
+
+	for !c.closed && ctx.Err() == nil && (c.respIndex >= len(c.resps) || c.held(c.respIndex)) {
+		if c.respIndex >= len(c.resps) && len(c.incoming) > 0 {
+			req := c.incoming[0]
+			c.incoming = c.incoming[1:]
+			c.answers = append(c.answers, handleRequest(c.handler, req))
+			continue
+		}
+		c.cond.Wait()
+	}
+
+	if c.closed {
+		return nil, io.EOF
+	}
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
 	r := c.resps[c.respIndex]
 	c.respIndex++
 	return r, nil
 }
 
-func (*connectionMock) Close() error { return nil }
+func (c *connectionMock) held(idx int) bool {
+	method, ok := c.holdUntil[idx]
+	if !ok {
+		return false
+	}
+
+	for _, r := range c.reqs {
+		if r.Method == method {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *connectionMock) LocalAddr() net.Addr {
+	return mockAddr
+}
+
+func (c *connectionMock) Handle(h RequestHandler) {
+	c.handler = h
+}
+
+func (c *connectionMock) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.closed = true
+	c.cond.Broadcast()
+	return nil
+}
 
 type authHandlerMock struct{}
 
@@ -47,12 +167,235 @@ func (*authHandlerMock) Solve(challenge AuthenticationChallenge, req *Request) e
 	return nil
 }
 
-func TestDialog_Ring_decline(t *testing.T) {
+func TestDialog_Ring(t *testing.T) {
+	const unauthorized = "SIP/2.0 401 Unauthorized\r\nCSeq: %d INVITE\r\nWWW-Authenticate: Digest nonce=\"1234\", realm=\"test.example.com\"\r\nContent-Length: 0\r\n\r\n"
+
+	tests := []struct {
+		name         string
+		resps        []string
+		wantResult   Result
+		wantRequests []string
+	}{
+		{
+			name: "answered",
+			resps: []string{
+				"SIP/2.0 180 Ringing\r\nCSeq: 1 INVITE\r\nTo: <sip:callee@localhost>;tag=a\r\nContent-Length: 0\r\n\r\n",
+				"SIP/2.0 200 OK\r\nCSeq: 1 INVITE\r\nTo: <sip:callee@localhost>;tag=a\r\nContent-Length: 0\r\n\r\n",
+				"SIP/2.0 200 OK\r\nCSeq: 2 BYE\r\nContent-Length: 0\r\n\r\n",
+			},
+			wantResult:   ResultAnswered,
+			wantRequests: []string{"1 INVITE", "1 ACK", "2 BYE"},
+		},
+		{
+			name: "declined",
+			resps: []string{
+				"SIP/2.0 603 Decline\r\nCSeq: 1 INVITE\r\nContent-Length: 0\r\n\r\n",
+			},
+			wantResult:   ResultDeclined,
+			wantRequests: []string{"1 INVITE", "1 ACK"},
+		},
+		{
+			name: "busy",
+			resps: []string{
+				"SIP/2.0 180 Ringing\r\nCSeq: 1 INVITE\r\nTo: <sip:callee@localhost>;tag=a\r\nContent-Length: 0\r\n\r\n",
+				"SIP/2.0 486 Busy Here\r\nCSeq: 1 INVITE\r\nTo: <sip:callee@localhost>;tag=a\r\nContent-Length: 0\r\n\r\n",
+			},
+			wantResult:   ResultBusy,
+			wantRequests: []string{"1 INVITE", "1 ACK"},
+		},
+		{
+			name: "busy everywhere",
+			resps: []string{
+				"SIP/2.0 600 Busy Everywhere\r\nCSeq: 1 INVITE\r\nContent-Length: 0\r\n\r\n",
+			},
+			wantResult:   ResultBusy,
+			wantRequests: []string{"1 INVITE", "1 ACK"},
+		},
+		{
+			name: "declined after authentication",
+			resps: []string{
+				fmt.Sprintf(unauthorized, 1),
+				"SIP/2.0 603 Decline\r\nCSeq: 2 INVITE\r\nContent-Length: 0\r\n\r\n",
+			},
+			wantResult:   ResultDeclined,
+			wantRequests: []string{"1 INVITE", "1 ACK", "2 INVITE", "2 ACK"},
+		},
+		{
+			name: "answered after authentication",
+			resps: []string{
+				fmt.Sprintf(unauthorized, 1),
+				"SIP/2.0 180 Ringing\r\nCSeq: 2 INVITE\r\nContent-Length: 0\r\n\r\n",
+				"SIP/2.0 200 OK\r\nCSeq: 2 INVITE\r\nContent-Length: 0\r\n\r\n",
+				"SIP/2.0 200 OK\r\nCSeq: 3 BYE\r\nContent-Length: 0\r\n\r\n",
+			},
+			wantResult:   ResultAnswered,
+			wantRequests: []string{"1 INVITE", "1 ACK", "2 INVITE", "2 ACK", "3 BYE"},
+		},
+		{
+			name: "stale nonce",
+			resps: []string{
+				fmt.Sprintf(unauthorized, 1),
+				"SIP/2.0 401 Unauthorized\r\nCSeq: 2 INVITE\r\nWWW-Authenticate: Digest nonce=\"5678\", realm=\"test.example.com\", stale=true\r\nContent-Length: 0\r\n\r\n",
+				"SIP/2.0 200 OK\r\nCSeq: 3 INVITE\r\nContent-Length: 0\r\n\r\n",
+				"SIP/2.0 200 OK\r\nCSeq: 4 BYE\r\nContent-Length: 0\r\n\r\n",
+			},
+			wantResult:   ResultAnswered,
+			wantRequests: []string{"1 INVITE", "1 ACK", "2 INVITE", "2 ACK", "3 INVITE", "3 ACK", "4 BYE"},
+		},
+		{
+			name: "rejected credentials",
+			resps: []string{
+				fmt.Sprintf(unauthorized, 1),
+				fmt.Sprintf(unauthorized, 2),
+			},
+			wantResult:   ResultDeclined,
+			wantRequests: []string{"1 INVITE", "1 ACK", "2 INVITE", "2 ACK"},
+		},
+	}
+
+	caller, err := ParseURI("sip:caller@localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	callee, err := ParseURI("sip:callee@localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tm := &transportMock{}
+			for _, r := range test.resps {
+				tm.resps = append(tm.resps, resp(r))
+			}
+
+			d := NewDialog(tm, caller, &authHandlerMock{})
+			result, err := d.Ring(context.Background(), callee, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result != test.wantResult {
+				t.Errorf("expected %s but got %s", test.wantResult, result)
+			}
+
+			if d.State() != DialogStateTerminated {
+				t.Errorf("expected dialog to be terminated but got %s", d.State())
+			}
+
+			var requests []string
+			for _, r := range tm.cons[0].reqs {
+				requests = append(requests, r.Header.Get("CSeq"))
+			}
+
+			if strings.Join(requests, ", ") != strings.Join(test.wantRequests, ", ") {
+				t.Errorf("expected requests %v but got %v", test.wantRequests, requests)
+			}
+		})
+	}
+}
+
+func TestDialog_Ring_redirect(t *testing.T) {
+	tm := &transportMock{
+		calleeResps: map[string][]*Response{
+			"callee": {
+				resp("SIP/2.0 302 Moved Temporarily\r\nCSeq: 1 INVITE\r\nContact: <sip:mobile@localhost>;q=0.5, \"Desk, Office\" <sip:desk@localhost>;q=0.9\r\nContact: <tel:+4930123456>\r\nContent-Length: 0\r\n\r\n"),
+			},
+			"desk": {
+				resp("SIP/2.0 180 Ringing\r\nCSeq: 2 INVITE\r\nTo: <sip:desk@localhost>;tag=d\r\nContent-Length: 0\r\n\r\n"),
+				resp("SIP/2.0 200 OK\r\nCSeq: 2 INVITE\r\nTo: <sip:desk@localhost>;tag=d\r\nContent-Length: 0\r\n\r\n"),
+				resp("SIP/2.0 200 OK\r\nCSeq: 3 BYE\r\nContent-Length: 0\r\n\r\n"),
+			},
+		},
+	}
+
+	caller, _ := ParseURI("sip:caller@localhost")
+	callee, _ := ParseURI("sip:callee@localhost")
+
+	d := NewDialog(tm, caller)
+	result, err := d.Ring(context.Background(), callee, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != ResultAnswered {
+		t.Errorf("expected answered but got %s", result)
+	}
+
+	if got := d.Callee().String(); got != "sip:desk@localhost" {
+		t.Errorf("expected call to be redirected to desk but got %s", got)
+	}
+
+	if len(tm.cons) != 2 {
+		t.Fatalf("expected 2 connections but got %d", len(tm.cons))
+	}
+
+	invite := tm.cons[1].reqs[0]
+	if invite.URI.String() != "sip:desk@localhost" || invite.Header.Get("CSeq") != "2 INVITE" {
+		t.Errorf("unexpected redirected INVITE: %s %s", invite.URI, invite.Header.Get("CSeq"))
+	}
+	if invite.Header.Get("Call-ID") != tm.cons[0].reqs[0].Header.Get("Call-ID") {
+		t.Error("expected redirected INVITE to keep the Call-ID")
+	}
+}
+
+func TestDialog_Ring_redirectLoop(t *testing.T) {
+	tm := &transportMock{
+		calleeResps: map[string][]*Response{
+			"callee": {resp("SIP/2.0 302 Moved Temporarily\r\nCSeq: 1 INVITE\r\nContact: <sip:other@localhost>\r\nContent-Length: 0\r\n\r\n")},
+			"other":  {resp("SIP/2.0 302 Moved Temporarily\r\nCSeq: 2 INVITE\r\nContact: <sip:callee@localhost>\r\nContent-Length: 0\r\n\r\n")},
+		},
+	}
+
+	caller, _ := ParseURI("sip:caller@localhost")
+	callee, _ := ParseURI("sip:callee@localhost")
+
+	result, err := NewDialog(tm, caller).Ring(context.Background(), callee, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != ResultRedirected {
+		t.Errorf("expected redirected but got %s", result)
+	}
+
+	if len(tm.cons) != 2 {
+		t.Errorf("expected 2 connections but got %d", len(tm.cons))
+	}
+}
+
+func TestDialog_Ring_serviceUnavailable(t *testing.T) {
 	tm := &transportMock{
 		resps: []*Response{
-			resp("SIP/2.0/TCP 401 Unauthorized\r\nContent-Length: 0\r\nWWW-Authenticate: Digest nonce=\"1234\", realm=\"test.example.com\"\r\n"),
-			resp("SIP/2.0/TCP 603 Declined\r\nContent-Length: 0\r\n\r\n"),
-			resp("SIP/2.0/TCP 200 OK\r\nContent-Length: 0\r\n\r\n"),
+			resp("SIP/2.0 503 Service Unavailable\r\nCSeq: 1 INVITE\r\nRetry-After: 30 (overloaded)\r\nContent-Length: 0\r\n\r\n"),
+		},
+	}
+
+	caller, _ := ParseURI("sip:caller@localhost")
+	callee, _ := ParseURI("sip:callee@localhost")
+
+	d := NewDialog(tm, caller)
+	result, err := d.Ring(context.Background(), callee, time.Second)
+	if !errors.Is(err, ErrServiceUnavailable) {
+		t.Errorf("expected service unavailable but got %v", err)
+	}
+
+	if result != ResultFailed {
+		t.Errorf("expected failed but got %s", result)
+	}
+
+	if got := d.RetryAfter(); got != 30*time.Second {
+		t.Errorf("expected retry after 30s but got %s", got)
+	}
+}
+
+func TestDialog_Ring_dialogIdentifiers(t *testing.T) {
+	tm := &transportMock{
+		resps: []*Response{
+			resp("SIP/2.0 100 Trying\r\nCSeq: 1 INVITE\r\nTo: <sip:callee@localhost>\r\nContent-Length: 0\r\n\r\n"),
+			resp("SIP/2.0 180 Ringing\r\nCSeq: 1 INVITE\r\nTo: <sip:callee@localhost>;tag=callee1\r\nContent-Length: 0\r\n\r\n"),
+			resp("SIP/2.0 200 OK\r\nCSeq: 1 INVITE\r\nTo: <sip:callee@localhost>;tag=callee1\r\nContent-Length: 0\r\n\r\n"),
+			resp("SIP/2.0 200 OK\r\nCSeq: 2 BYE\r\nContent-Length: 0\r\n\r\n"),
 		},
 	}
 
@@ -66,24 +409,353 @@ func TestDialog_Ring_decline(t *testing.T) {
 	}
 
 	d := NewDialog(tm, caller, &authHandlerMock{})
-	accepted, err := d.Ring(callee, time.Second)
+	if _, err := d.Ring(context.Background(), callee, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := tm.cons[0].reqs
+	if len(reqs) != 3 {
+		t.Fatalf("expected INVITE, ACK and BYE but got %d requests", len(reqs))
+	}
+	invite, ack, bye := reqs[0], reqs[1], reqs[2]
+
+	fromTag := invite.Header.Param("From", "tag")
+	if fromTag == "" {
+		t.Error("expected INVITE to carry a From tag")
+	}
+
+	if invite.Header.Param("To", "tag") != "" {
+		t.Error("expected INVITE not to carry a To tag")
+	}
+
+	branches := map[string]bool{}
+	for _, r := range reqs {
+		if r.Header.Get("Call-ID") != invite.Header.Get("Call-ID") {
+			t.Errorf("expected %s to use the INVITE's Call-ID", r.Method)
+		}
+
+		if r.Header.Param("From", "tag") != fromTag {
+			t.Errorf("expected %s to use the INVITE's From tag", r.Method)
+		}
+
+		branch := r.Header.Param("Via", "branch")
+		if !strings.HasPrefix(branch, branchMagicCookie) {
+			t.Errorf("expected %s's branch to start with magic cookie but got %s", r.Method, branch)
+		}
+		branches[branch] = true
+	}
+
+	if len(branches) != 3 {
+		t.Errorf("expected INVITE, ACK and BYE to use different branches")
+	}
+
+	for _, r := range []*Request{ack, bye} {
+		if tag := r.Header.Param("To", "tag"); tag != "callee1" {
+			t.Errorf("expected %s to carry the callee's To tag but got '%s'", r.Method, tag)
+		}
+	}
+
+	if other := NewDialog(tm, caller); other.callID == d.callID || other.localTag == d.localTag {
+		t.Error("expected dialogs to use unique Call-IDs and tags")
+	}
+}
+
+func TestDialog_Ring_media(t *testing.T) {
+	answer := "v=0\r\no=- 1 1 IN IP4 192.168.1.1\r\ns=-\r\nc=IN IP4 192.168.1.1\r\nt=0 0\r\nm=audio 7078 RTP/AVP 8 101\r\na=rtpmap:101 telephone-event/8000\r\n"
+	tm := &transportMock{
+		resps: []*Response{
+			resp(fmt.Sprintf("SIP/2.0 200 OK\r\nCSeq: 1 INVITE\r\nContent-Type: application/sdp\r\nContent-Length: %d\r\n\r\n%s", len(answer), answer)),
+			resp("SIP/2.0 200 OK\r\nCSeq: 2 BYE\r\nContent-Length: 0\r\n\r\n"),
+		},
+	}
+
+	caller, err := ParseURI("sip:caller@localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	callee, err := ParseURI("sip:callee@localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDialog(tm, caller)
+	if _, err := d.Ring(context.Background(), callee, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	invite, ack := tm.cons[0].reqs[0], tm.cons[0].reqs[1]
+
+	if invite.Header.Get("Content-Type") != "application/sdp" || !strings.Contains(string(invite.Body), "c=IN IP4 127.0.0.1\r\n") {
+		t.Errorf("expected INVITE to carry an offer using the local address: %s", invite.Body)
+	}
+
+	if len(ack.Body) != 0 {
+		t.Errorf("expected ACK without body")
+	}
+
+	media := d.Media()
+	if media == nil {
+		t.Fatal("expected negotiated media")
+	}
+
+	if media.Codec.Name != "PCMA" || media.TelephoneEvent == nil || media.Remote.String() != "192.168.1.1:7078" {
+		t.Errorf("unexpected media: %+v", media)
+	}
+}
+
+func TestDialog_Ring_remoteTarget(t *testing.T) {
+	tests := map[string]struct {
+		recordRoute string
+		uri         string
+		routes      []string
+	}{
+		"no route set": {
+			uri: "sip:callee@10.0.0.2:5070",
+		},
+		"loose routers": {
+			recordRoute: "<sip:p2.example.com;lr>, <sip:p1.example.com;lr>",
+			uri:         "sip:callee@10.0.0.2:5070",
+			routes:      []string{"<sip:p1.example.com;lr>", "<sip:p2.example.com;lr>"},
+		},
+		"strict router": {
+			recordRoute: "<sip:p1.example.com>",
+			uri:         "sip:p1.example.com",
+			routes:      []string{"<sip:callee@10.0.0.2:5070>"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ok := "SIP/2.0 200 OK\r\nCSeq: 1 INVITE\r\nTo: <sip:callee@localhost>;tag=callee1\r\nContact: <sip:callee@10.0.0.2:5070>\r\n"
+			if test.recordRoute != "" {
+				ok += "Record-Route: " + test.recordRoute + "\r\n"
+			}
+
+			tm := &transportMock{
+				resps: []*Response{
+					resp(ok + "Content-Length: 0\r\n\r\n"),
+					resp("SIP/2.0 200 OK\r\nCSeq: 2 BYE\r\nContent-Length: 0\r\n\r\n"),
+				},
+			}
+
+			caller, _ := ParseURI("sip:caller@localhost")
+			callee, _ := ParseURI("sip:callee@localhost")
+
+			if _, err := NewDialog(tm, caller).Ring(context.Background(), callee, time.Second); err != nil {
+				t.Fatal(err)
+			}
+
+			reqs := tm.cons[0].reqs
+			if len(reqs) != 3 {
+				t.Fatalf("expected INVITE, ACK and BYE but got %d requests", len(reqs))
+			}
+
+			if reqs[0].URI.String() != callee.String() || reqs[0].Header.Contains("Route") {
+				t.Errorf("expected INVITE to be sent to the callee: %s", reqs[0].URI)
+			}
+
+			for _, r := range reqs[1:] {
+				if r.URI.String() != test.uri {
+					t.Errorf("expected %s to be sent to %s but got %s", r.Method, test.uri, r.URI)
+				}
+				if diff := deep.Equal(r.Header["Route"], test.routes); diff != nil {
+					t.Errorf("unexpected route set of %s: %v", r.Method, diff)
+				}
+				if to := r.Header.Get("To"); to != "<sip:callee@localhost>;tag=callee1" {
+					t.Errorf("expected %s to keep the To header but got %s", r.Method, to)
+				}
+			}
+		})
+	}
+}
+
+func TestDialog_Ring_retransmitted2xx(t *testing.T) {
+	ok := resp("SIP/2.0 200 OK\r\nCSeq: 1 INVITE\r\nTo: <sip:callee@localhost>;tag=callee1\r\nContent-Length: 0\r\n\r\n")
+	tm := &transportMock{
+		resps: []*Response{
+			ok,
+			ok,
+			resp("SIP/2.0 200 OK\r\nCSeq: 2 BYE\r\nContent-Length: 0\r\n\r\n"),
+		},
+		holdUntil: map[int]string{1: "ACK"},
+	}
+
+	caller, _ := ParseURI("sip:caller@localhost")
+	callee, _ := ParseURI("sip:callee@localhost")
+
+	result, err := NewDialog(tm, caller).Ring(context.Background(), callee, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != ResultAnswered {
+		t.Errorf("expected answered but got %s", result)
+	}
+
+	var acks []*Request
+	for _, r := range tm.cons[0].reqs {
+		if r.Method == "ACK" {
+			acks = append(acks, r)
+		}
+	}
+
+	if len(acks) != 2 {
+		t.Fatalf("expected ACK to be resent but got %d ACKs", len(acks))
+	}
+	if acks[0].Header.Get("Via") != acks[1].Header.Get("Via") || acks[0].Header.Get("CSeq") != acks[1].Header.Get("CSeq") {
+		t.Errorf("expected resent ACK to match the first one")
+	}
+}
+
+func TestDialog_Ring_answerHandler(t *testing.T) {
+	answer := "v=0\r\no=- 1 1 IN IP4 127.0.0.1\r\ns=-\r\nc=IN IP4 127.0.0.1\r\nt=0 0\r\nm=audio 7078 RTP/AVP 0\r\n"
+	tm := &transportMock{
+		resps: []*Response{
+			resp(fmt.Sprintf("SIP/2.0 200 OK\r\nCSeq: 1 INVITE\r\nContent-Type: application/sdp\r\nContent-Length: %d\r\n\r\n%s", len(answer), answer)),
+			resp("SIP/2.0 200 OK\r\nCSeq: 2 BYE\r\nContent-Length: 0\r\n\r\n"),
+		},
+	}
+
+	caller, err := ParseURI("sip:caller@localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	callee, err := ParseURI("sip:callee@localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var call *Call
+	d := NewDialog(tm, caller)
+	d.OnAnswer(func(ctx context.Context, c *Call) error {
+		call = c
+		return nil
+	})
+
+	result, err := d.Ring(context.Background(), callee, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != ResultAnswered {
+		t.Errorf("expected answered but got %s", result)
+	}
+
+	if call == nil || call.Media.Codec.Name != "PCMU" || call.RTP == nil {
+		t.Fatalf("expected handler to be called with media: %+v", call)
+	}
+
+	reqs := tm.cons[0].reqs
+	if len(reqs) != 3 || reqs[2].Method != "BYE" {
+		t.Errorf("expected call to be hung up after the handler returned")
+	}
+
+	if contact := reqs[0].Header.Get("Contact"); contact != "<sip:caller@127.0.0.1:5060>" {
+		t.Errorf("expected contact with local address but got %s", contact)
+	}
+
+	if !strings.Contains(string(reqs[0].Body), fmt.Sprintf("m=audio %d ", call.RTP.LocalAddr().(*net.UDPAddr).Port)) {
+		t.Errorf("expected offer to announce the RTP socket: %s", reqs[0].Body)
+	}
+}
+
+func TestDialog_Ring_confirm(t *testing.T) {
+	answer := "v=0\r\no=- 1 1 IN IP4 127.0.0.1\r\ns=-\r\nc=IN IP4 127.0.0.1\r\nt=0 0\r\nm=audio 7078 RTP/AVP 0\r\n"
+	tm := &transportMock{
+		resps: []*Response{
+			resp(fmt.Sprintf("SIP/2.0 200 OK\r\nCSeq: 1 INVITE\r\nContent-Type: application/sdp\r\nContent-Length: %d\r\n\r\n%s", len(answer), answer)),
+			resp("SIP/2.0 200 OK\r\nCSeq: 2 BYE\r\nContent-Length: 0\r\n\r\n"),
+		},
+	}
+
+	caller, _ := ParseURI("sip:caller@localhost")
+	callee, _ := ParseURI("sip:callee@localhost")
+
+	var confirmed, answered bool
+	d := NewDialog(tm, caller)
+	d.OnConfirm(func() bool {
+		confirmed = true
+		return false
+	})
+	d.OnAnswer(func(ctx context.Context, c *Call) error {
+		answered = true
+		return nil
+	})
+
+	if _, err := d.Ring(context.Background(), callee, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if !confirmed || answered {
+		t.Errorf("expected call to be hung up without invoking the answer handler: %t/%t", confirmed, answered)
+	}
+
+	if reqs := tm.cons[0].reqs; len(reqs) != 3 || reqs[2].Method != "BYE" {
+		t.Errorf("expected call to be hung up")
+	}
+}
+
+func TestDialog_Ring_calleeHangsUp(t *testing.T) {
+	answer := "v=0\r\no=- 1 1 IN IP4 127.0.0.1\r\ns=-\r\nc=IN IP4 127.0.0.1\r\nt=0 0\r\nm=audio 7078 RTP/AVP 0\r\n"
+	tm := &transportMock{
+		resps: []*Response{
+			resp(fmt.Sprintf("SIP/2.0 200 OK\r\nCSeq: 1 INVITE\r\nTo: <sip:callee@localhost>;tag=callee1\r\nContent-Type: application/sdp\r\nContent-Length: %d\r\n\r\n%s", len(answer), answer)),
+		},
+	}
 
+	caller, err := ParseURI("sip:caller@localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	callee, err := ParseURI("sip:callee@localhost")
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
+	}
+
+	d := NewDialog(tm, caller)
+	d.OnAnswer(func(ctx context.Context, c *Call) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	bye := NewRequest("BYE", caller)
+	bye.Header.Set("Call-ID", d.callID)
+	bye.Header.Set("CSeq", "1 BYE")
+	unknown := NewRequest("BYE", caller)
+	unknown.Header.Set("Call-ID", "unknown")
+	tm.incoming = []*Request{unknown, bye}
+
+	result, err := d.Ring(context.Background(), callee, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != ResultAnswered {
+		t.Errorf("expected answered but got %s", result)
+	}
+
+	if d.State() != DialogStateTerminated {
+		t.Errorf("expected dialog to be terminated but got %s", d.State())
+	}
+
+	c := tm.cons[0]
+	if len(c.reqs) != 2 {
+		t.Errorf("expected no BYE to be sent but got %d requests", len(c.reqs))
+	}
+
+	if len(c.answers) != 2 || c.answers[0].StatusCode != StatusCallDoesNotExist || c.answers[1].StatusCode != StatusOK {
+		t.Fatalf("expected BYEs to be answered with 481 and 200: %v", c.answers)
 	}
 
-	if accepted {
-		t.Errorf("expected declined but got accepted")
+	if c.answers[1].Header.Get("CSeq") != "1 BYE" {
+		t.Errorf("expected response to copy the CSeq")
 	}
 }
 
-func TestDialog_Ring_ringingThenOK(t *testing.T) {
+func TestDialog_Ring_dtmfInfo(t *testing.T) {
+	answer := "v=0\r\no=- 1 1 IN IP4 127.0.0.1\r\ns=-\r\nc=IN IP4 127.0.0.1\r\nt=0 0\r\nm=audio 7078 RTP/AVP 0\r\n"
 	tm := &transportMock{
 		resps: []*Response{
-			resp("SIP/2.0/TCP 401 Unauthorized\r\nContent-Length: 0\r\nWWW-Authenticate: Digest nonce=\"1234\", realm=\"test.example.com\"\r\n"),
-			resp("SIP/2.0/TCP 180 Ringing\r\nContent-Length: 0\r\n\r\n"),
-			resp("SIP/2.0/TCP 200 OK\r\nContent-Length: 0\r\n\r\n"),
-			resp("SIP/2.0/TCP 200 OK\r\nContent-Length: 0\r\n\r\n"),
+			resp(fmt.Sprintf("SIP/2.0 200 OK\r\nCSeq: 1 INVITE\r\nTo: <sip:callee@localhost>;tag=callee1\r\nContent-Type: application/sdp\r\nContent-Length: %d\r\n\r\n%s", len(answer), answer)),
 		},
 	}
 
@@ -96,14 +768,225 @@ func TestDialog_Ring_ringingThenOK(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	d := NewDialog(tm, caller)
+
+	var digits []rune
+	d.OnAnswer(func(ctx context.Context, c *Call) error {
+		<-ctx.Done()
+		for {
+			select {
+			case digit := <-c.DTMF:
+				digits = append(digits, digit)
+			default:
+				return nil
+			}
+		}
+	})
+
+	request := func(method string, cseq int, contentType, body string) *Request {
+		r := NewRequest(method, caller)
+		r.Header.Set("Call-ID", d.callID)
+		r.Header.Set("CSeq", fmt.Sprintf("%d %s", cseq, method))
+		if body != "" {
+			r.SetBody(contentType, []byte(body))
+		}
+		return r
+	}
+
+	tm.incoming = []*Request{
+		request("INFO", 2, ContentTypeDTMFRelay, "Signal=5\r\nDuration=160\r\n"),
+		// Retransmission
+		request("INFO", 2, ContentTypeDTMFRelay, "Signal=5\r\nDuration=160\r\n"),
+		request("INFO", 3, ContentTypeDTMFRelay, "Signal=11\r\nDuration=160\r\n"),
+		request("INFO", 4, "text/plain", "5"),
+		request("BYE", 5, "", ""),
+	}
+
+	result, err := d.Ring(context.Background(), callee, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != ResultAnswered {
+		t.Errorf("expected answered but got %s", result)
+	}
+
+	if string(digits) != "5#" {
+		t.Errorf("expected digits 5# but got %q", string(digits))
+	}
+
+	c := tm.cons[0]
+	var codes []string
+	for _, a := range c.answers {
+		codes = append(codes, strconv.Itoa(a.StatusCode))
+	}
+
+	if strings.Join(codes, ", ") != "200, 200, 200, 415, 200" {
+		t.Errorf("expected requests to be answered with 200, 200, 200, 415, 200 but got %v", codes)
+	}
+}
+
+func TestDialog_Ring_failureAck(t *testing.T) {
+	tm := &transportMock{
+		resps: []*Response{
+			resp("SIP/2.0 401 Unauthorized\r\nCSeq: 1 INVITE\r\nTo: <sip:callee@localhost>;tag=proxy\r\nWWW-Authenticate: Digest nonce=\"1234\", realm=\"test.example.com\"\r\nContent-Length: 0\r\n\r\n"),
+			resp("SIP/2.0 603 Decline\r\nCSeq: 2 INVITE\r\nTo: <sip:callee@localhost>;tag=callee1\r\nContent-Length: 0\r\n\r\n"),
+		},
+	}
+
+	caller, err := ParseURI("sip:caller@localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	callee, err := ParseURI("sip:callee@localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDialog(tm, caller, NewDigestHandler("user", "password"))
+	if _, err := d.Ring(context.Background(), callee, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := tm.cons[0].reqs
+	if len(reqs) != 4 {
+		t.Fatalf("expected 2 INVITEs and ACKs but got %d requests", len(reqs))
+	}
+
+	for i, tag := range map[int]string{1: "proxy", 3: "callee1"} {
+		invite, ack := reqs[i-1], reqs[i]
+
+		if ack.Method != "ACK" || ack.Header.Get("Via") != invite.Header.Get("Via") {
+			t.Errorf("expected ACK %d to use the INVITE's Via", i)
+		}
+
+		if got := ack.Header.Param("To", "tag"); got != tag {
+			t.Errorf("expected ACK %d to carry To tag %s but got '%s'", i, tag, got)
+		}
+	}
+
+	if reqs[2].Header.Get("Via") == reqs[0].Header.Get("Via") {
+		t.Error("expected authenticated INVITE to use a new branch")
+	}
+
+	if reqs[2].Header.Param("To", "tag") != "" {
+		t.Error("expected authenticated INVITE not to carry a To tag")
+	}
+
+	if reqs[1].Header.Get("Authorization") != "" || reqs[3].Header.Get("Authorization") != reqs[2].Header.Get("Authorization") {
+		t.Error("expected ACKs to carry the same credentials as the INVITEs")
+	}
+}
+
+func TestDialog_Ring_proxyThenServerAuthentication(t *testing.T) {
+	tm := &transportMock{
+		resps: []*Response{
+			resp("SIP/2.0 407 Proxy Authentication Required\r\nContent-Length: 0\r\nProxy-Authenticate: Digest nonce=\"1234\", realm=\"proxy.example.com\"\r\n"),
+			resp("SIP/2.0 401 Unauthorized\r\nContent-Length: 0\r\nWWW-Authenticate: Digest nonce=\"5678\", realm=\"test.example.com\"\r\n"),
+			resp("SIP/2.0 200 OK\r\nContent-Length: 0\r\n\r\n"),
+			resp("SIP/2.0 200 OK\r\nContent-Length: 0\r\n\r\n"),
+		},
+	}
+
+	caller, err := ParseURI("sip:caller@localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	callee, err := ParseURI("sip:callee@localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDialog(tm, caller, NewDigestHandler("user", "password"))
+	result, err := d.Ring(context.Background(), callee, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != ResultAnswered {
+		t.Errorf("expected answered but got %s", result)
+	}
+
+	reqs := tm.cons[0].reqs
+	ack := reqs[len(reqs)-2]
+	if ack.Method != "ACK" || ack.Header.Get("Proxy-Authorization") == "" || ack.Header.Get("Authorization") == "" {
+		t.Errorf("expected ACK to carry both credentials: %s", ack.Header.DebugString())
+	}
+}
+
+func TestDialog_Ring_notAnswered(t *testing.T) {
+	tm := &transportMock{
+		resps: []*Response{
+			resp("SIP/2.0 180 Ringing\r\nCSeq: 1 INVITE\r\nContent-Length: 0\r\n\r\n"),
+			resp("SIP/2.0 200 OK\r\nCSeq: 1 CANCEL\r\nContent-Length: 0\r\n\r\n"),
+			resp("SIP/2.0 487 Request Terminated\r\nCSeq: 1 INVITE\r\nTo: <sip:callee@localhost>;tag=1\r\nContent-Length: 0\r\n\r\n"),
+		},
+		holdUntil: map[int]string{1: "CANCEL"},
+	}
+
+	caller, err := ParseURI("sip:caller@localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	callee, err := ParseURI("sip:callee@localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	d := NewDialog(tm, caller, &authHandlerMock{})
-	accepted, err := d.Ring(callee, time.Second)
+	result, err := d.Ring(context.Background(), callee, 10*time.Millisecond)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
+	}
+
+	if result != ResultNotAnswered {
+		t.Errorf("expected not answered but got %s", result)
+	}
+
+	reqs := tm.cons[0].reqs
+	if len(reqs) != 3 || reqs[1].Method != "CANCEL" || reqs[2].Method != "ACK" {
+		t.Fatalf("expected INVITE, CANCEL and ACK but got %d requests", len(reqs))
+	}
+
+	if reqs[1].Header.Get("Via") != reqs[0].Header.Get("Via") || reqs[2].Header.Get("Via") != reqs[0].Header.Get("Via") {
+		t.Errorf("expected CANCEL and ACK to use the INVITE's Via")
+	}
+
+	if reqs[1].Header.Get("CSeq") != "1 CANCEL" {
+		t.Errorf("expected CANCEL to use the INVITE's sequence number but got %s", reqs[1].Header.Get("CSeq"))
+	}
+}
+
+func TestDialog_Ring_contextCancelled(t *testing.T) {
+	tm := &transportMock{
+		resps: []*Response{
+			resp("SIP/2.0 180 Ringing\r\nCSeq: 1 INVITE\r\nContent-Length: 0\r\n\r\n"),
+			resp("SIP/2.0 200 OK\r\nCSeq: 1 CANCEL\r\nContent-Length: 0\r\n\r\n"),
+			resp("SIP/2.0 487 Request Terminated\r\nCSeq: 1 INVITE\r\nContent-Length: 0\r\n\r\n"),
+		},
+		holdUntil: map[int]string{1: "CANCEL"},
+	}
+
+	caller, err := ParseURI("sip:caller@localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	callee, err := ParseURI("sip:callee@localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	d := NewDialog(tm, caller, &authHandlerMock{})
+	_, err = d.Ring(ctx, callee, time.Minute)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled but got %v", err)
 	}
 
-	if !accepted {
-		t.Errorf("expected accepted but got declined")
+	if reqs := tm.cons[0].reqs; len(reqs) != 3 || reqs[1].Method != "CANCEL" {
+		t.Errorf("expected call to be cancelled")
 	}
 }
 
@@ -0,0 +1,264 @@
+package sip
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// typeNAPTR is the DNS resource record type of NAPTR records (RFC 3403).
+	typeNAPTR dnsmessage.Type = 35
+
+	// resolvConf is the path of the resolver configuration listing the name servers to query.
+	resolvConf = "/etc/resolv.conf"
+
+	// dnsTimeout bounds the time to wait for a name server's answer.
+	dnsTimeout = 5 * time.Second
+)
+
+// NAPTR is a DNS naming authority pointer record as defined in RFC 3403.
+type NAPTR struct {
+	Order       uint16
+	Preference  uint16
+	Flags       string
+	Service     string
+	Regexp      string
+	Replacement string
+}
+
+// Resolver looks up the DNS records required to locate SIP servers following RFC 3263.
+type Resolver interface {
+	// LookupNAPTR returns the NAPTR records of name; no records and no error if name has none.
+	LookupNAPTR(ctx context.Context, name string) ([]*NAPTR, error)
+
+	// LookupSRV returns the SRV records of name, i.e. _sip._udp.example.com; no records and no error if
+	// name has none.
+	LookupSRV(ctx context.Context, name string) ([]*net.SRV, error)
+
+	// LookupIPAddr returns the IPv4 and IPv6 addresses of host.
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// DefaultResolver is the Resolver used if none is given. It uses the system's resolver for SRV and address
+// lookups and queries the name servers listed in /etc/resolv.conf for NAPTR records, which are not supported
+// by the net package.
+var DefaultResolver Resolver = &dnsResolver{}
+
+type dnsResolver struct{}
+
+func (*dnsResolver) LookupSRV(ctx context.Context, name string) ([]*net.SRV, error) {
+	_, srvs, err := net.DefaultResolver.LookupSRV(ctx, "", "", name)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	return srvs, err
+}
+
+func (*dnsResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return net.DefaultResolver.LookupIPAddr(ctx, host)
+}
+
+func (*dnsResolver) LookupNAPTR(ctx context.Context, name string) ([]*NAPTR, error) {
+	q, err := dnsmessage.NewName(dnsName(name))
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, server := range nameservers() {
+		records, err := queryNAPTR(ctx, server, q)
+		if err == nil {
+			return records, nil
+		}
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
+// queryNAPTR queries server for the NAPTR records of name.
+func queryNAPTR(ctx context.Context, server string, name dnsmessage.Name) ([]*NAPTR, error) {
+	var idBytes [2]byte
+	if _, err := rand.Read(idBytes[:]); err != nil {
+		return nil, err
+	}
+	id := binary.BigEndian.Uint16(idBytes[:])
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: name, Type: typeNAPTR, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	query, err := b.Finish()
+	if err != nil {
+		return nil, err
+	}
+
+	var d net.Dialer
+	con, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer con.Close()
+
+	deadline := time.Now().Add(dnsTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	con.SetDeadline(deadline)
+
+	if _, err := con.Write(query); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := con.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		var p dnsmessage.Parser
+		h, err := p.Start(buf[:n])
+		if err != nil || h.ID != id || !h.Response {
+			// Not an answer to the query.
+			continue
+		}
+
+		return parseNAPTRAnswer(&p, h)
+	}
+}
+
+// parseNAPTRAnswer parses the NAPTR records from the answer section of a DNS response.
+func parseNAPTRAnswer(p *dnsmessage.Parser, h dnsmessage.Header) ([]*NAPTR, error) {
+	switch h.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("NAPTR lookup failed: %s", h.RCode)
+	}
+
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, err
+	}
+
+	var records []*NAPTR
+	for {
+		rh, err := p.AnswerHeader()
+		if errors.Is(err, dnsmessage.ErrSectionDone) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if rh.Type != typeNAPTR {
+			if err := p.SkipAnswer(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		r, err := p.UnknownResource()
+		if err != nil {
+			return nil, err
+		}
+
+		n, err := parseNAPTR(r.Data)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, n)
+	}
+}
+
+// parseNAPTR parses the RDATA of a NAPTR record. The replacement is not compressed as required by RFC 3403
+// section 4.1.
+func parseNAPTR(data []byte) (*NAPTR, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("invalid NAPTR record")
+	}
+
+	n := &NAPTR{
+		Order:      binary.BigEndian.Uint16(data[0:2]),
+		Preference: binary.BigEndian.Uint16(data[2:4]),
+	}
+	data = data[4:]
+
+	for _, s := range []*string{&n.Flags, &n.Service, &n.Regexp} {
+		if len(data) < 1 || len(data) < 1+int(data[0]) {
+			return nil, fmt.Errorf("invalid NAPTR record")
+		}
+		*s = string(data[1 : 1+int(data[0])])
+		data = data[1+int(data[0]):]
+	}
+
+	var labels []string
+	for {
+		if len(data) < 1 || len(data) < 1+int(data[0]) {
+			return nil, fmt.Errorf("invalid NAPTR replacement")
+		}
+		l := int(data[0])
+		if l == 0 {
+			break
+		}
+		labels = append(labels, string(data[1:1+l]))
+		data = data[1+l:]
+	}
+	n.Replacement = strings.Join(labels, ".")
+
+	return n, nil
+}
+
+// nameservers returns the addresses of the name servers listed in /etc/resolv.conf; the local name server if
+// none are listed.
+func nameservers() []string {
+	var servers []string
+
+	f, err := os.Open(resolvConf)
+	if err == nil {
+		defer f.Close()
+
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			fields := strings.Fields(s.Text())
+			if len(fields) > 1 && fields[0] == "nameserver" {
+				servers = append(servers, net.JoinHostPort(fields[1], "53"))
+			}
+		}
+	}
+
+	if len(servers) == 0 {
+		servers = append(servers, "127.0.0.1:53")
+	}
+
+	return servers
+}
+
+// dnsName returns name as fully qualified domain name.
+func dnsName(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// isNotFound reports whether err reports that a DNS name does not exist or has no records of the requested
+// type.
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
@@ -26,6 +26,25 @@ func (h Header) Get(key string) string {
 	return textproto.MIMEHeader(h).Get(key)
 }
 
+func (h Header) Del(key string) {
+	textproto.MIMEHeader(h).Del(key)
+}
+
+// Param returns the value of parameter name from the first value of header key. Parameters are separated
+// from the value and from each other by semicolons (i.e. Via: SIP/2.0/UDP host;branch=z9hG4bK1). An empty
+// string is returned if either the header or the parameter does not exist.
+func (h Header) Param(key, name string) string {
+	params := strings.Split(h.Get(key), ";")
+	for _, p := range params[1:] {
+		keyVal := strings.SplitN(strings.TrimSpace(p), "=", 2)
+		if strings.EqualFold(keyVal[0], name) && len(keyVal) == 2 {
+			return strings.TrimSpace(keyVal[1])
+		}
+	}
+
+	return ""
+}
+
 func (h Header) ParseHeader(line string) error {
 	parts := strings.Split(line, ":")
 	if len(parts) == 1 {
@@ -0,0 +1,237 @@
+package sip
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Locator locates the SIP servers to send requests to following RFC 3263. The zero value uses the
+// DefaultResolver and no outbound proxy.
+type Locator struct {
+	// Resolver is used for DNS lookups; DefaultResolver if nil.
+	Resolver Resolver
+
+	// OutboundProxy receives all requests regardless of their request URI if set.
+	OutboundProxy *URI
+}
+
+// transportServices maps transport names to the NAPTR services and SRV prefixes defined in RFC 3263.
+var transportServices = map[string]struct {
+	naptr string
+	srv   string
+}{
+	"UDP": {naptr: "SIP+D2U", srv: "_sip._udp."},
+	"TCP": {naptr: "SIP+D2T", srv: "_sip._tcp."},
+	"TLS": {naptr: "SIPS+D2T", srv: "_sips._tcp."},
+}
+
+// Locate returns the network addresses (host:port) of the servers to send requests for uri to using transport
+// (UDP, TCP or TLS), ordered by preference. Addresses must be tried in order until a server can be reached.
+//
+// IP addresses are used as given. An explicit port suppresses NAPTR and SRV lookups (RFC 3263 section 4.2).
+// Otherwise, the NAPTR records of the host select the SRV records to look up, falling back to the transport's
+// SRV record and finally to the host's A/AAAA records using the scheme's default port. Failing NAPTR and SRV
+// lookups are treated like missing records since many (home) routers do not answer them.
+func (l *Locator) Locate(ctx context.Context, uri URI, transport string) ([]string, error) {
+	if l != nil && l.OutboundProxy != nil {
+		uri = *l.OutboundProxy
+	}
+
+	if net.ParseIP(uri.Host) != nil {
+		return []string{hostPort(uri)}, nil
+	}
+
+	if uri.Port != 0 {
+		return l.lookupHost(ctx, uri.Host, uri.Port)
+	}
+
+	srvs, err := l.lookupSRV(ctx, uri.Host, transport)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(srvs) == 0 {
+		return l.lookupHost(ctx, uri.Host, defaultPort(uri.Scheme))
+	}
+
+	var addrs []string
+	for _, srv := range srvs {
+		a, lookupErr := l.lookupHost(ctx, strings.TrimSuffix(srv.Target, "."), int(srv.Port))
+		if lookupErr != nil {
+			err = lookupErr
+			continue
+		}
+		addrs = append(addrs, a...)
+	}
+
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("failed to resolve SRV targets of %s: %w", uri.Host, err)
+	}
+
+	return addrs, nil
+}
+
+// lookupSRV returns the SRV records to use for domain and transport in order of preference.
+func (l *Locator) lookupSRV(ctx context.Context, domain, transport string) ([]*net.SRV, error) {
+	service, ok := transportServices[transport]
+	if !ok {
+		return nil, fmt.Errorf("unsupported transport: %s", transport)
+	}
+
+	naptrs, err := l.resolver().LookupNAPTR(ctx, domain)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		naptrs = nil
+	}
+
+	var names []string
+	sort.SliceStable(naptrs, func(i, j int) bool {
+		if naptrs[i].Order != naptrs[j].Order {
+			return naptrs[i].Order < naptrs[j].Order
+		}
+		return naptrs[i].Preference < naptrs[j].Preference
+	})
+	for _, n := range naptrs {
+		if strings.EqualFold(n.Flags, "s") && strings.EqualFold(n.Service, service.naptr) && n.Replacement != "" {
+			names = append(names, n.Replacement)
+		}
+	}
+
+	if len(names) == 0 {
+		names = []string{service.srv + domain}
+	}
+
+	var result []*net.SRV
+	for _, name := range names {
+		srvs, err := l.resolver().LookupSRV(ctx, name)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			continue
+		}
+		result = append(result, orderSRV(srvs)...)
+	}
+
+	return result, nil
+}
+
+// lookupHost returns the addresses of host combined with port.
+func (l *Locator) lookupHost(ctx context.Context, host string, port int) ([]string, error) {
+	ips, err := l.resolver().LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.JoinHostPort(ip.String(), strconv.Itoa(port))
+	}
+
+	return addrs, nil
+}
+
+func (l *Locator) resolver() Resolver {
+	if l == nil || l.Resolver == nil {
+		return DefaultResolver
+	}
+	return l.Resolver
+}
+
+// orderSRV orders srvs by priority and randomly by weight within the same priority as defined in RFC 2782.
+// "." as target denotes that the service is not available; such records are dropped.
+func orderSRV(srvs []*net.SRV) []*net.SRV {
+	var pending []*net.SRV
+	for _, s := range srvs {
+		if s.Target != "." {
+			pending = append(pending, s)
+		}
+	}
+
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].Priority < pending[j].Priority
+	})
+
+	ordered := make([]*net.SRV, 0, len(pending))
+	for len(pending) > 0 {
+		n := 1
+		for n < len(pending) && pending[n].Priority == pending[0].Priority {
+			n++
+		}
+
+		// Records with weight 0 are placed first so that they have a small chance of being selected.
+		group := append([]*net.SRV(nil), pending[:n]...)
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].Weight == 0 && group[j].Weight != 0
+		})
+		for len(group) > 0 {
+			i := pickByWeight(group)
+			ordered = append(ordered, group[i])
+			group = append(group[:i:i], group[i+1:]...)
+		}
+
+		pending = pending[n:]
+	}
+
+	return ordered
+}
+
+// pickByWeight selects an index of srvs randomly with probabilities proportional to the records' weights
+// following the selection algorithm of RFC 2782.
+func pickByWeight(srvs []*net.SRV) int {
+	total := 0
+	for _, s := range srvs {
+		total += int(s.Weight)
+	}
+
+	n := rand.Intn(total + 1)
+	sum := 0
+	for i, s := range srvs {
+		sum += int(s.Weight)
+		if sum >= n {
+			return i
+		}
+	}
+
+	return len(srvs) - 1
+}
+
+// defaultPort returns the default port of scheme.
+func defaultPort(scheme string) int {
+	if scheme == SchemeSIPS {
+		return DefaultTLSPort
+	}
+	return DefaultPort
+}
+
+// dialLocated dials the addresses l locates for uri in order until a connection has been established.
+func dialLocated(ctx context.Context, l *Locator, uri URI, transport string, dial func(ctx context.Context, addr string) (net.Conn, error)) (net.Conn, error) {
+	addrs, err := l.Locate(ctx, uri, transport)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, addr := range addrs {
+		var con net.Conn
+		con, err = dial(ctx, addr)
+		if err == nil {
+			return con, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+
+	if err == nil {
+		err = fmt.Errorf("no address found for %s", uri.Host)
+	}
+
+	return nil, err
+}
@@ -0,0 +1,272 @@
+package sip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+// resolverMock implements Resolver using static records. Unknown names have no records. NAPTR and SRV lookups
+// of names contained in failing return an error.
+type resolverMock struct {
+	naptr   map[string][]*NAPTR
+	srv     map[string][]*net.SRV
+	ip      map[string][]net.IPAddr
+	failing map[string]bool
+	lookups []string
+}
+
+func (r *resolverMock) LookupNAPTR(ctx context.Context, name string) ([]*NAPTR, error) {
+	r.lookups = append(r.lookups, "NAPTR "+name)
+	if r.failing[name] {
+		return nil, &net.DNSError{Err: "server misbehaving", Name: name, IsTemporary: true}
+	}
+	return r.naptr[name], nil
+}
+
+func (r *resolverMock) LookupSRV(ctx context.Context, name string) ([]*net.SRV, error) {
+	r.lookups = append(r.lookups, "SRV "+name)
+	if r.failing[name] {
+		return nil, &net.DNSError{Err: "i/o timeout", Name: name, IsTimeout: true}
+	}
+	return r.srv[name], nil
+}
+
+func (r *resolverMock) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	r.lookups = append(r.lookups, "A "+host)
+	ips, ok := r.ip[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return ips, nil
+}
+
+func ipAddr(s string) []net.IPAddr {
+	return []net.IPAddr{{IP: net.ParseIP(s)}}
+}
+
+func TestLocator_Locate(t *testing.T) {
+	r := &resolverMock{
+		naptr: map[string][]*NAPTR{
+			"example.com": {
+				{Order: 20, Preference: 10, Flags: "s", Service: "SIP+D2U", Replacement: "_sip._udp.example.com"},
+				{Order: 10, Preference: 10, Flags: "s", Service: "SIP+D2T", Replacement: "_sip._tcp.example.com"},
+			},
+		},
+		srv: map[string][]*net.SRV{
+			"_sip._udp.example.com": {
+				{Target: "b.example.com.", Port: 5080, Priority: 20},
+				{Target: "a.example.com.", Port: 5070, Priority: 10},
+			},
+			"_sip._tcp.example.com": {
+				{Target: "c.example.com.", Port: 5090, Priority: 10},
+			},
+			"_sip._udp.example.org": {
+				{Target: "a.example.com.", Port: 5070, Priority: 10},
+			},
+		},
+		ip: map[string][]net.IPAddr{
+			"a.example.com": ipAddr("192.0.2.1"),
+			"b.example.com": ipAddr("2001:db8::1"),
+			"example.net":   ipAddr("192.0.2.3"),
+		},
+	}
+
+	proxy := NewURI("sip", "", "example.net", 5060)
+
+	tests := []struct {
+		uri       string
+		transport string
+		proxy     *URI
+		want      string
+	}{
+		{"sip:alice@example.com", "UDP", nil, "192.0.2.1:5070 [2001:db8::1]:5080"},
+		{"sip:alice@example.com", "TLS", nil, ""},
+		{"sip:alice@example.org", "UDP", nil, "192.0.2.1:5070"},
+		{"sip:alice@example.net", "UDP", nil, "192.0.2.3:5060"},
+		{"sip:alice@example.net:5070", "UDP", nil, "192.0.2.3:5070"},
+		{"sip:alice@192.0.2.10:5070", "UDP", nil, "192.0.2.10:5070"},
+		{"sip:alice@example.com", "UDP", &proxy, "192.0.2.3:5060"},
+	}
+
+	for _, test := range tests {
+		uri, err := ParseURI(test.uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		l := &Locator{Resolver: r, OutboundProxy: test.proxy}
+		addrs, err := l.Locate(context.Background(), uri, test.transport)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("%s/%s: expected error but got %v", test.uri, test.transport, addrs)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s/%s: %s", test.uri, test.transport, err)
+			continue
+		}
+
+		if got := strings.Join(addrs, " "); got != test.want {
+			t.Errorf("%s/%s: expected %s but got %s", test.uri, test.transport, test.want, got)
+		}
+	}
+}
+
+func TestLocator_Locate_explicitPort(t *testing.T) {
+	r := &resolverMock{ip: map[string][]net.IPAddr{"example.com": ipAddr("192.0.2.1")}}
+
+	uri, _ := ParseURI("sip:alice@example.com:5070")
+	if _, err := (&Locator{Resolver: r}).Locate(context.Background(), uri, "UDP"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(r.lookups, ", "); got != "A example.com" {
+		t.Errorf("expected address lookup only but got %s", got)
+	}
+}
+
+func TestLocator_Locate_failingResolver(t *testing.T) {
+	r := &resolverMock{
+		srv: map[string][]*net.SRV{
+			"_sip._udp.example.com": {{Target: "a.example.com.", Port: 5070, Priority: 10}},
+		},
+		ip: map[string][]net.IPAddr{
+			"a.example.com": ipAddr("192.0.2.1"),
+			"fritz.box":     ipAddr("192.168.178.1"),
+		},
+		failing: map[string]bool{
+			"example.com":         true,
+			"fritz.box":           true,
+			"_sip._udp.fritz.box": true,
+		},
+	}
+
+	tests := map[string]string{
+		// The NAPTR lookup fails; the SRV record is used.
+		"sip:alice@example.com": "192.0.2.1:5070",
+		// Both NAPTR and SRV lookups fail; the host's address is used with the default port.
+		"sip:**610@fritz.box": "192.168.178.1:5060",
+	}
+
+	for u, want := range tests {
+		uri, err := ParseURI(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		addrs, err := (&Locator{Resolver: r}).Locate(context.Background(), uri, "UDP")
+		if err != nil {
+			t.Errorf("%s: %s", u, err)
+			continue
+		}
+
+		if got := strings.Join(addrs, " "); got != want {
+			t.Errorf("%s: expected %s but got %s", u, want, got)
+		}
+	}
+}
+
+func TestLocator_Locate_cancelled(t *testing.T) {
+	r := &resolverMock{
+		ip:      map[string][]net.IPAddr{"fritz.box": ipAddr("192.168.178.1")},
+		failing: map[string]bool{"fritz.box": true},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := (&Locator{Resolver: r}).Locate(ctx, NewURI("sip", "alice", "fritz.box", 0), "UDP"); err != context.Canceled {
+		t.Errorf("expected context.Canceled but got %v", err)
+	}
+}
+
+func TestOrderSRV(t *testing.T) {
+	srvs := []*net.SRV{
+		{Target: "c.", Priority: 20, Weight: 0},
+		{Target: "a.", Priority: 10, Weight: 100},
+		{Target: ".", Priority: 5},
+		{Target: "b.", Priority: 10, Weight: 0},
+		{Target: "d.", Priority: 20, Weight: 50},
+	}
+
+	first := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		ordered := orderSRV(srvs)
+		if len(ordered) != 4 {
+			t.Fatalf("expected 4 records but got %d", len(ordered))
+		}
+		if ordered[2].Priority != 20 || ordered[3].Priority != 20 {
+			t.Fatalf("expected records to be ordered by priority")
+		}
+		first[ordered[0].Target]++
+	}
+
+	// The record with weight 0 is selected first in about 1% of all cases.
+	if first["a."] < 900 || first["b."] == 0 {
+		t.Errorf("expected records to be selected by weight but got %v", first)
+	}
+}
+
+func TestTCPTransport_failover(t *testing.T) {
+	unreachable, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		con, err := l.Accept()
+		if err == nil {
+			con.Close()
+		}
+	}()
+
+	r := &resolverMock{
+		srv: map[string][]*net.SRV{
+			"_sip._tcp.example.com": {
+				{Target: "a.example.com.", Port: uint16(unreachable.Addr().(*net.TCPAddr).Port), Priority: 10},
+				{Target: "a.example.com.", Port: uint16(l.Addr().(*net.TCPAddr).Port), Priority: 20},
+			},
+		},
+		ip: map[string][]net.IPAddr{"a.example.com": ipAddr("127.0.0.1")},
+	}
+
+	transport := &TCPTransport{Locator: &Locator{Resolver: r}}
+	con, err := transport.Dial(context.Background(), NewURI("sip", "alice", "example.com", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	if got, want := con.(*tcpConnection).con.RemoteAddr().String(), l.Addr().String(); got != want {
+		t.Errorf("expected connection to %s but got %s", want, got)
+	}
+}
+
+func TestParseNAPTR(t *testing.T) {
+	data := []byte{0, 10, 0, 20, 1, 's', 7, 'S', 'I', 'P', '+', 'D', '2', 'U', 0}
+	data = append(data, 4, '_', 's', 'i', 'p', 4, '_', 'u', 'd', 'p', 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0)
+
+	n, err := parseNAPTR(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := fmt.Sprintf("%d %d %s %s %q %s", n.Order, n.Preference, n.Flags, n.Service, n.Regexp, n.Replacement); got != `10 20 s SIP+D2U "" _sip._udp.example.com` {
+		t.Errorf("unexpected record: %s", got)
+	}
+
+	if _, err := parseNAPTR(data[:len(data)-3]); err == nil {
+		t.Errorf("expected error for truncated record")
+	}
+}
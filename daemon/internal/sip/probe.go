@@ -0,0 +1,222 @@
+package sip
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	StatusServiceUnavailable = 503
+
+	// DefaultProbeInterval is the interval between two probes if none is given.
+	DefaultProbeInterval = time.Minute
+)
+
+// ProbeInfo describes the health of a SIP server as determined by a Probe.
+type ProbeInfo struct {
+	Server URI
+
+	// Healthy reports whether the last probe succeeded.
+	Healthy bool
+
+	// LastProbe is the time of the last probe; zero if the server has not been probed yet.
+	LastProbe time.Time
+
+	// LastSuccess is the time of the last successful probe; zero if no probe succeeded.
+	LastSuccess time.Time
+
+	// Latency is the round trip time of the last successful probe.
+	Latency time.Duration
+
+	// Error is the error of the last probe; nil if it succeeded.
+	Error error
+}
+
+// Probe periodically sends OPTIONS requests to a SIP server to check that the server is reachable and
+// accepts the credentials. Any final response other than 401/407 (after answering the challenge), 403 and
+// 503 counts as success; servers not supporting OPTIONS (i.e. answering 405) are reachable nonetheless.
+type Probe struct {
+	transport              Transport
+	server                 URI
+	from                   URI
+	interval               time.Duration
+	authenticationHandlers []AuthenticationHandler
+	onChange               func(info ProbeInfo)
+
+	callID string
+	tag    string
+	cseq   int
+
+	lock sync.RWMutex
+	info ProbeInfo
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewProbe creates a new Probe sending OPTIONS requests from from to server every interval. Use Start to
+// actually probe.
+func NewProbe(transport Transport, server, from URI, interval time.Duration, authenticationHandlers ...AuthenticationHandler) *Probe {
+	if interval <= 0 {
+		interval = DefaultProbeInterval
+	}
+
+	return &Probe{
+		transport:              transport,
+		server:                 server,
+		from:                   from,
+		interval:               interval,
+		authenticationHandlers: authenticationHandlers,
+		callID:                 randomToken(12),
+		tag:                    newTag(),
+		info:                   ProbeInfo{Server: server},
+	}
+}
+
+// OnChange sets a callback invoked whenever the server's health changes, i.e. after the first probe and
+// whenever a probe fails after a successful one or vice versa. OnChange must be called before Start.
+func (p *Probe) OnChange(f func(info ProbeInfo)) {
+	p.onChange = f
+}
+
+// Start probes in the background until Close is called.
+func (p *Probe) Start() {
+	var ctx context.Context
+	ctx, p.cancel = context.WithCancel(context.Background())
+	p.done = make(chan struct{})
+
+	go p.run(ctx)
+}
+
+// Close stops probing, aborting any probe in progress.
+func (p *Probe) Close() error {
+	if p.cancel == nil {
+		return nil
+	}
+
+	p.cancel()
+	<-p.done
+
+	return nil
+}
+
+// Info returns the result of the last probe.
+func (p *Probe) Info() ProbeInfo {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.info
+}
+
+func (p *Probe) run(ctx context.Context) {
+	defer close(p.done)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		probeCtx, cancel := context.WithTimeout(ctx, transactionTimeout)
+		latency, err := p.probe(probeCtx)
+		cancel()
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		p.update(latency, err)
+		timer.Reset(p.interval)
+	}
+}
+
+// update records the result of a probe and notifies the OnChange callback if the health changed.
+func (p *Probe) update(latency time.Duration, err error) {
+	p.lock.Lock()
+
+	first := p.info.LastProbe.IsZero()
+	changed := first || p.info.Healthy != (err == nil)
+
+	p.info.LastProbe = time.Now()
+	p.info.Healthy = err == nil
+	p.info.Error = err
+	if err == nil {
+		p.info.LastSuccess = p.info.LastProbe
+		p.info.Latency = latency
+	}
+	info := p.info
+
+	p.lock.Unlock()
+
+	if changed && p.onChange != nil {
+		p.onChange(info)
+	}
+}
+
+// probe sends a single OPTIONS request and returns the round trip time of the first final response.
+func (p *Probe) probe(ctx context.Context) (time.Duration, error) {
+	req := p.request()
+
+	start := time.Now()
+	con, err := p.transport.Send(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	defer con.Close()
+
+	res, err := RecvFinal(ctx, con)
+	if err != nil {
+		return 0, err
+	}
+	latency := time.Since(start)
+
+	challenges := newChallengeSolver(p.authenticationHandlers)
+	for attempt := 0; isChallenge(res.StatusCode) && attempt < maxAuthenticationAttempts; attempt++ {
+		ok, err := challenges.solve(res, req)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			break
+		}
+
+		p.cseq++
+		req.Header.Set("CSeq", fmt.Sprintf("%d %s", p.cseq, req.Method))
+		req.Header.Del("Via")
+
+		if err := con.Send(req); err != nil {
+			return 0, err
+		}
+
+		res, err = RecvFinal(ctx, con)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if isChallenge(res.StatusCode) || res.StatusCode == StatusForbidden || res.StatusCode == StatusServiceUnavailable {
+		return 0, fmt.Errorf("probe rejected: %d %s", res.StatusCode, res.StatusMessage)
+	}
+
+	return latency, nil
+}
+
+func (p *Probe) request() *Request {
+	p.cseq++
+
+	req := NewRequest("OPTIONS", p.server)
+	req.Header.Set("From", fmt.Sprintf("<%s>;tag=%s", p.from, p.tag))
+	req.Header.Set("To", fmt.Sprintf("<%s>", p.server))
+	req.Header.Set("Accept", "application/sdp")
+	req.Header.Set("Max-Forwards", "70")
+	req.Header.Set("CSeq", fmt.Sprintf("%d %s", p.cseq, req.Method))
+	req.Header.Set("Call-ID", p.callID)
+
+	return req
+}
@@ -0,0 +1,78 @@
+package sip
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func TestProbe(t *testing.T) {
+	tm := &transportMock{
+		resps: []*Response{
+			resp("SIP/2.0 401 Unauthorized\r\nContent-Length: 0\r\nWWW-Authenticate: Digest nonce=\"1234\", realm=\"test.example.com\"\r\n"),
+			resp("SIP/2.0 200 OK\r\nContent-Length: 0\r\n\r\n"),
+		},
+	}
+
+	info := probe(t, tm)
+
+	if !info.Healthy || info.Error != nil {
+		t.Errorf("expected healthy but got %v", info.Error)
+	}
+	if info.LastSuccess.IsZero() || info.LastSuccess != info.LastProbe {
+		t.Errorf("expected last success to be set")
+	}
+
+	options := tm.cons[0].reqs[1]
+	if options.Method != "OPTIONS" || options.Header.Get("Authorize") != "Solved" {
+		t.Errorf("expected authenticated OPTIONS but got %s", options.DebugString())
+	}
+}
+
+func TestProbe_rejected(t *testing.T) {
+	tm := &transportMock{
+		resps: []*Response{
+			resp("SIP/2.0 403 Forbidden\r\nContent-Length: 0\r\n\r\n"),
+		},
+	}
+
+	info := probe(t, tm)
+
+	if info.Healthy || info.Error == nil {
+		t.Errorf("expected unhealthy")
+	}
+	if !info.LastSuccess.IsZero() {
+		t.Errorf("expected no last success but got %s", info.LastSuccess)
+	}
+}
+
+// probe runs a Probe using tm until the first probe has finished and returns the result.
+func probe(t *testing.T, tm *transportMock) ProbeInfo {
+	server, err := ParseURI("sip:localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, err := ParseURI("sip:caller@localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := make(chan ProbeInfo, 1)
+
+	p := NewProbe(tm, server, from, time.Hour, &authHandlerMock{})
+	p.OnChange(func(info ProbeInfo) { changes <- info })
+	p.Start()
+	defer p.Close()
+
+	select {
+	case info := <-changes:
+		if diff := deep.Equal(info, p.Info()); diff != nil {
+			t.Errorf("expected %+v but got %+v", p.Info(), info)
+		}
+		return info
+	case <-time.After(time.Second):
+		t.Fatal("expected health to change")
+		return ProbeInfo{}
+	}
+}
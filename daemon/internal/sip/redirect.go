@@ -0,0 +1,106 @@
+package sip
+
+import (
+	"errors"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	StatusBusyEverywhere = 600
+
+	// maxRedirects bounds the number of redirects followed by a single call to Dialog.Ring.
+	maxRedirects = 5
+)
+
+// ErrServiceUnavailable is returned when the server responds with 503 Service Unavailable. Use
+// Dialog.RetryAfter to obtain the time the server asked to wait before retrying.
+var ErrServiceUnavailable = errors.New("service unavailable")
+
+// redirectTargets returns the SIP URIs listed in the Contact headers of the redirect response res ordered by
+// their q parameter, most preferred first. Contacts that cannot be parsed (i.e. tel URIs) are skipped.
+func redirectTargets(res *Response) []URI {
+	type target struct {
+		uri URI
+		q   float64
+	}
+
+	var targets []target
+	for _, v := range res.Header[textproto.CanonicalMIMEHeaderKey("Contact")] {
+		for _, c := range splitHeaderValues(v) {
+			a, err := ParseNameAddr(c)
+			if err != nil {
+				continue
+			}
+
+			q := 1.0
+			if v, ok := a.Params.Get("q"); ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+			targets = append(targets, target{uri: a.URI, q: q})
+		}
+	}
+
+	sort.SliceStable(targets, func(i, j int) bool { return targets[i].q > targets[j].q })
+
+	uris := make([]URI, len(targets))
+	for i, t := range targets {
+		uris[i] = t.uri
+	}
+	return uris
+}
+
+// splitHeaderValues splits a comma separated list of header values such as multiple contacts. Commas within
+// quoted strings or angle brackets do not separate values.
+func splitHeaderValues(v string) []string {
+	var values []string
+	var quoted, escaped bool
+	angle := 0
+	start := 0
+
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; {
+		case escaped:
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '<':
+			angle++
+		case c == '>' && angle > 0:
+			angle--
+		case c == ',' && angle == 0:
+			values = append(values, strings.TrimSpace(v[start:i]))
+			start = i + 1
+		}
+	}
+
+	if s := strings.TrimSpace(v[start:]); s != "" {
+		values = append(values, s)
+	}
+	return values
+}
+
+// retryAfter returns the duration given by the Retry-After header of res; 0 if the header is missing or
+// invalid. Comments and parameters such as "120 (in a meeting);duration=60" are ignored.
+func retryAfter(res *Response) time.Duration {
+	v := strings.TrimSpace(res.Header.Get("Retry-After"))
+	i := 0
+	for i < len(v) && v[i] >= '0' && v[i] <= '9' {
+		i++
+	}
+
+	// delta-seconds must not exceed 2^32-1 (RFC 3261 section 20.33).
+	seconds, err := strconv.ParseUint(v[:i], 10, 32)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
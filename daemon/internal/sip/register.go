@@ -0,0 +1,292 @@
+package sip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	StatusIntervalTooBrief = 423
+
+	// DefaultRegistrationExpiry is the registration expiry requested if none is given.
+	DefaultRegistrationExpiry = time.Hour
+
+	// registrationRetryInterval is the time to wait before retrying a failed registration.
+	registrationRetryInterval = time.Minute
+)
+
+// RegistrationState enumerates the states of a Registration.
+type RegistrationState int
+
+const (
+	RegistrationStateUnregistered RegistrationState = iota
+	RegistrationStateRegistering
+	RegistrationStateRegistered
+	RegistrationStateFailed
+)
+
+func (s RegistrationState) String() string {
+	switch s {
+	case RegistrationStateUnregistered:
+		return "unregistered"
+	case RegistrationStateRegistering:
+		return "registering"
+	case RegistrationStateRegistered:
+		return "registered"
+	case RegistrationStateFailed:
+		return "failed"
+	default:
+		return fmt.Sprintf("unknown (%d)", int(s))
+	}
+}
+
+// RegistrationInfo describes the current state of a Registration.
+type RegistrationInfo struct {
+	State     RegistrationState
+	Registrar URI
+	ExpiresAt time.Time
+	Error     error
+}
+
+// Registration maintains the binding of an address of record at a registrar. Once started, the binding is
+// refreshed in the background before it expires. Closing the Registration removes the binding.
+type Registration struct {
+	transport              Transport
+	registrar              URI
+	aor                    URI
+	expires                time.Duration
+	authenticationHandlers []AuthenticationHandler
+
+	// contactPort is the port announced in the Contact header; 0 to announce the local port of the
+	// connection used to register.
+	contactPort int
+
+	callID string
+	tag    string
+	cseq   int
+
+	lock      sync.RWMutex
+	state     RegistrationState
+	expiresAt time.Time
+	lastErr   error
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewRegistration creates a new Registration binding aor at registrar requesting the given expiry. Use
+// Start to actually register.
+func NewRegistration(transport Transport, registrar, aor URI, expires time.Duration, authenticationHandlers ...AuthenticationHandler) *Registration {
+	if expires <= 0 {
+		expires = DefaultRegistrationExpiry
+	}
+
+	return &Registration{
+		transport:              transport,
+		registrar:              registrar,
+		aor:                    aor,
+		expires:                expires,
+		authenticationHandlers: authenticationHandlers,
+		callID:                 randomToken(12),
+		tag:                    newTag(),
+	}
+}
+
+// SetContactPort sets the port announced in the Contact header, i.e. the port incoming calls are accepted on.
+// By default the local port of the connection used to register is announced. It must be called before Start.
+func (r *Registration) SetContactPort(port int) {
+	r.contactPort = port
+}
+
+// Start registers in the background and keeps refreshing the registration until Close is called.
+func (r *Registration) Start() {
+	var ctx context.Context
+	ctx, r.cancel = context.WithCancel(context.Background())
+	r.done = make(chan struct{})
+
+	go r.run(ctx)
+}
+
+// Close stops refreshing the registration - aborting any registration in progress - and unregisters if
+// registered.
+func (r *Registration) Close() error {
+	if r.cancel == nil {
+		return nil
+	}
+
+	r.cancel()
+	<-r.done
+
+	if r.Info().State != RegistrationStateRegistered {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), transactionTimeout)
+	defer cancel()
+
+	_, err := r.register(ctx, 0)
+	r.update(RegistrationStateUnregistered, time.Time{}, err)
+
+	return err
+}
+
+// Info returns the current state of the registration.
+func (r *Registration) Info() RegistrationInfo {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return RegistrationInfo{
+		State:     r.state,
+		Registrar: r.registrar,
+		ExpiresAt: r.expiresAt,
+		Error:     r.lastErr,
+	}
+}
+
+func (r *Registration) run(ctx context.Context) {
+	defer close(r.done)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		state := r.Info()
+		r.update(RegistrationStateRegistering, state.ExpiresAt, nil)
+
+		registerCtx, cancel := context.WithTimeout(ctx, transactionTimeout)
+		granted, err := r.register(registerCtx, r.expires)
+		cancel()
+
+		if ctx.Err() != nil {
+			// Restore the previous state so that Close unregisters if required.
+			r.update(state.State, state.ExpiresAt, state.Error)
+			return
+		}
+
+		if err != nil {
+			r.update(RegistrationStateFailed, time.Time{}, err)
+			timer.Reset(registrationRetryInterval)
+			continue
+		}
+
+		r.update(RegistrationStateRegistered, time.Now().Add(granted), nil)
+
+		// Refresh the registration when 90% of the granted expiry have passed.
+		timer.Reset(granted * 9 / 10)
+	}
+}
+
+func (r *Registration) update(state RegistrationState, expiresAt time.Time, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.state = state
+	r.expiresAt = expiresAt
+	r.lastErr = err
+}
+
+// register sends a REGISTER request asking for expires and returns the expiry granted by the registrar.
+// An expires value of 0 removes the binding.
+func (r *Registration) register(ctx context.Context, expires time.Duration) (time.Duration, error) {
+	con, err := r.transport.Dial(ctx, r.registrar)
+	if err != nil {
+		return 0, err
+	}
+	defer con.Close()
+
+	req := r.request(con.LocalAddr(), expires)
+	if err := con.Send(req); err != nil {
+		return 0, err
+	}
+
+	res, err := RecvFinal(ctx, con)
+	if err != nil {
+		return 0, err
+	}
+
+	if res.StatusCode == StatusIntervalTooBrief {
+		minExpires, err := strconv.Atoi(res.Header.Get("Min-Expires"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid Min-Expires header: %s", err)
+		}
+		if time.Duration(minExpires)*time.Second <= expires {
+			return 0, fmt.Errorf("registration rejected: %d %s", res.StatusCode, res.StatusMessage)
+		}
+		r.expires = time.Duration(minExpires) * time.Second
+		return r.register(ctx, r.expires)
+	}
+
+	challenges := newChallengeSolver(r.authenticationHandlers)
+	for attempt := 0; isChallenge(res.StatusCode) && attempt < maxAuthenticationAttempts; attempt++ {
+		ok, err := challenges.solve(res, req)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			break
+		}
+
+		r.cseq++
+		req.Header.Set("CSeq", fmt.Sprintf("%d %s", r.cseq, req.Method))
+		req.Header.Del("Via")
+
+		if err := con.Send(req); err != nil {
+			return 0, err
+		}
+
+		res, err = RecvFinal(ctx, con)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if res.StatusCode != StatusOK {
+		return 0, fmt.Errorf("registration rejected: %d %s", res.StatusCode, res.StatusMessage)
+	}
+
+	return grantedExpiry(res, expires), nil
+}
+
+// request creates a REGISTER request binding the contact reachable via localAddr.
+func (r *Registration) request(localAddr net.Addr, expires time.Duration) *Request {
+	port := r.contactPort
+	if port == 0 {
+		port = addrPort(localAddr)
+	}
+	contact := NewURI(r.aor.Scheme, r.aor.Address, addrIP(localAddr).String(), port)
+
+	r.cseq++
+
+	req := NewRequest("REGISTER", r.registrar)
+	req.Header.Set("From", fmt.Sprintf("<%s>;tag=%s", r.aor, r.tag))
+	req.Header.Set("To", fmt.Sprintf("<%s>", r.aor))
+	req.Header.Set("Contact", fmt.Sprintf("<%s>", contact))
+	req.Header.Set("Expires", strconv.Itoa(int(expires.Seconds())))
+	req.Header.Set("Max-Forwards", "70")
+	req.Header.Set("CSeq", fmt.Sprintf("%d %s", r.cseq, req.Method))
+	req.Header.Set("Call-ID", r.callID)
+
+	return req
+}
+
+// grantedExpiry determines the expiry granted by the registrar from res. The expires parameter of the
+// Contact header takes precedence over the Expires header. If neither is present, requested is returned.
+func grantedExpiry(res *Response, requested time.Duration) time.Duration {
+	for _, v := range []string{res.Header.Param("Contact", "expires"), res.Header.Get("Expires")} {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+
+	return requested
+}
@@ -0,0 +1,96 @@
+package sip
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRegistration(t *testing.T) {
+	tm := &transportMock{
+		resps: []*Response{
+			resp("SIP/2.0 401 Unauthorized\r\nContent-Length: 0\r\nWWW-Authenticate: Digest nonce=\"1234\", realm=\"test.example.com\"\r\n"),
+			resp("SIP/2.0 200 OK\r\nContact: <sip:caller@127.0.0.1:5060>;expires=120\r\nContent-Length: 0\r\n\r\n"),
+		},
+	}
+
+	registrar, err := ParseURI("sip:localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	aor, err := ParseURI("sip:caller@localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRegistration(tm, registrar, aor, time.Minute, &authHandlerMock{})
+	r.Start()
+
+	deadline := time.Now().Add(time.Second)
+	for r.Info().State != RegistrationStateRegistered {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected registered but got %s (%v)", r.Info().State, r.Info().Error)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if expiresIn := time.Until(r.Info().ExpiresAt); expiresIn < 110*time.Second || expiresIn > 120*time.Second {
+		t.Errorf("expected registration to expire in 120s but got %s", expiresIn)
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := r.Info().State; s != RegistrationStateUnregistered {
+		t.Errorf("expected unregistered but got %s", s)
+	}
+
+	if len(tm.cons) != 2 {
+		t.Fatalf("expected 2 connections but got %d", len(tm.cons))
+	}
+
+	register := tm.cons[0].reqs[1]
+	if register.Header.Get("Authorize") != "Solved" {
+		t.Errorf("expected authenticated REGISTER")
+	}
+	if register.Header.Get("Expires") != "60" {
+		t.Errorf("expected Expires: 60 but got %s", register.Header.Get("Expires"))
+	}
+	if contact := register.Header.Get("Contact"); contact != "<sip:caller@127.0.0.1:5060>" {
+		t.Errorf("expected contact with local address but got %s", contact)
+	}
+
+	unregister := tm.cons[1].reqs[1]
+	if unregister.Header.Get("Expires") != "0" {
+		t.Errorf("expected Expires: 0 but got %s", unregister.Header.Get("Expires"))
+	}
+	if unregister.Header.Get("Call-ID") != register.Header.Get("Call-ID") {
+		t.Errorf("expected same Call-ID for all registrations")
+	}
+}
+
+func TestRegistration_contactPort(t *testing.T) {
+	tm := &transportMock{
+		resps: []*Response{
+			resp("SIP/2.0 200 OK\r\nContent-Length: 0\r\n\r\n"),
+		},
+	}
+
+	registrar, _ := ParseURI("sip:localhost")
+	aor, _ := ParseURI("sip:caller@localhost")
+
+	r := NewRegistration(tm, registrar, aor, time.Minute)
+	r.SetContactPort(5070)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if _, err := r.register(ctx, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if contact := tm.cons[0].reqs[0].Header.Get("Contact"); contact != "<sip:caller@127.0.0.1:5070>" {
+		t.Errorf("expected contact with configured port but got %s", contact)
+	}
+}
@@ -12,7 +12,7 @@ import (
 )
 
 var (
-	ErrParsingError = errors.New("error parsing response")
+	ErrParsingError = errors.New("error parsing message")
 )
 
 type Request struct {
@@ -75,6 +75,54 @@ type Response struct {
 	LocalAddr, RemoteAddr net.Addr
 }
 
+// NewResponse creates a response to req copying the headers required by RFC 3261 section 8.2.6.2.
+func NewResponse(req *Request, statusCode int, statusMessage string) *Response {
+	res := &Response{
+		Protocol:      "SIP/2.0",
+		StatusCode:    statusCode,
+		StatusMessage: statusMessage,
+		Header:        Header{},
+	}
+
+	for _, h := range []string{"Via", "From", "To", "Call-ID", "CSeq"} {
+		h = textproto.CanonicalMIMEHeaderKey(h)
+		if vals, ok := req.Header[h]; ok {
+			res.Header[h] = append([]string(nil), vals...)
+		}
+	}
+	res.Header.Set("Content-Length", "0")
+
+	return res
+}
+
+// SetBody sets the response's body and the Content-Type and Content-Length headers.
+func (r *Response) SetBody(contentType string, body []byte) {
+	r.Header.Set("Content-Type", contentType)
+	r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	r.Body = body
+}
+
+func (r *Response) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, fmt.Sprintf("%s %d %s\r\n", r.Protocol, r.StatusCode, r.StatusMessage)); err != nil {
+		return err
+	}
+
+	if err := r.Header.Write(w); err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, "\r\n"); err != nil {
+		return err
+	}
+
+	if len(r.Body) == 0 {
+		return nil
+	}
+
+	_, err := w.Write(r.Body)
+	return err
+}
+
 func (r *Response) DebugString() string {
 	var b strings.Builder
 	fmt.Fprintf(&b, "%s %d %s\n", r.Protocol, r.StatusCode, r.StatusMessage)
@@ -89,79 +137,143 @@ func (r *Response) DebugString() string {
 }
 
 func ParseResponse(r io.Reader) (*Response, error) {
+	req, res, err := parseMessage(r)
+	if err != nil {
+		return nil, err
+	}
+	if req != nil {
+		return nil, fmt.Errorf("%w: expected response but got %s request", ErrParsingError, req.Method)
+	}
+	return res, nil
+}
+
+// ParseRequest parses a request received from a SIP server, i.e. a BYE sent by the callee.
+func ParseRequest(r io.Reader) (*Request, error) {
+	req, res, err := parseMessage(r)
+	if err != nil {
+		return nil, err
+	}
+	if res != nil {
+		return nil, fmt.Errorf("%w: expected request but got %d response", ErrParsingError, res.StatusCode)
+	}
+	return req, nil
+}
+
+// parseMessage parses either a request or a response from r. Exactly one of the returned messages is
+// non-nil unless an error is returned.
+func parseMessage(r io.Reader) (*Request, *Response, error) {
 	br := bufio.NewReader(r)
 	pr := textproto.NewReader(br)
 
 	l, err := pr.ReadLine()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	protocol, statusCodeAsString, statusMessage, err := parseFirstResponseLine(l)
+	var req *Request
+	var res *Response
+
+	if strings.HasPrefix(l, "SIP/") {
+		res, err = parseFirstResponseLine(l)
+	} else {
+		req, err = parseRequestLine(l)
+	}
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	statusCode, err := strconv.ParseInt(statusCodeAsString, 10, 32)
+	header, body, err := parseHeaderAndBody(br, pr)
 	if err != nil {
-		return nil, fmt.Errorf("%w: error parsing status code: %s", ErrParsingError, err)
+		return nil, nil, err
 	}
 
-	res := &Response{
-		Protocol:      protocol,
-		StatusCode:    int(statusCode),
-		StatusMessage: statusMessage,
-		Header:        Header{},
-		Body:          nil,
+	if req != nil {
+		req.Header = header
+		req.Body = body
+		return req, nil, nil
 	}
 
+	res.Header = header
+	res.Body = body
+	return nil, res, nil
+}
+
+func parseHeaderAndBody(br *bufio.Reader, pr *textproto.Reader) (Header, []byte, error) {
+	header := Header{}
+
 	for {
 		line, err := pr.ReadContinuedLine()
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				break
 			}
-			return nil, err
+			return nil, nil, err
 		}
 
 		if len(strings.TrimSpace(line)) == 0 {
-			// End of body
-			// TODO: Parse body
+			// End of header
 			break
 		}
 
-		if err := res.Header.ParseHeader(line); err != nil {
-			return nil, err
+		if err := header.ParseHeader(line); err != nil {
+			return nil, nil, err
 		}
 	}
 
 	var contentLength int
+	var err error
 
-	contentLengthHeader := res.Header.Get("Content-Length")
+	contentLengthHeader := header.Get("Content-Length")
 	if contentLengthHeader != "" {
 		contentLength, err = strconv.Atoi(contentLengthHeader)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
-	if contentLength > 0 {
-		body := make([]byte, contentLength)
-		_, err = io.ReadFull(br, body)
-		if err != nil {
-			return nil, err
-		}
-		res.Body = body
+	if contentLength == 0 {
+		return header, nil, nil
 	}
 
-	return res, nil
+	body := make([]byte, contentLength)
+	if _, err := io.ReadFull(br, body); err != nil {
+		return nil, nil, err
+	}
+
+	return header, body, nil
 }
 
-func parseFirstResponseLine(l string) (protocol, statusCode, statusMessage string, err error) {
+func parseFirstResponseLine(l string) (*Response, error) {
 	parts := strings.Split(l, " ")
 	if len(parts) < 3 {
-		return "", "", "", fmt.Errorf("%w: invalid response line: %s", ErrParsingError, l)
+		return nil, fmt.Errorf("%w: invalid response line: %s", ErrParsingError, l)
+	}
+
+	statusCode, err := strconv.ParseInt(parts[1], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%w: error parsing status code: %s", ErrParsingError, err)
+	}
+
+	return &Response{
+		Protocol:      parts[0],
+		StatusCode:    int(statusCode),
+		StatusMessage: strings.TrimSpace(strings.Join(parts[2:], " ")),
+	}, nil
+}
+
+func parseRequestLine(l string) (*Request, error) {
+	parts := strings.Split(l, " ")
+	if len(parts) != 3 || !strings.HasPrefix(parts[2], "SIP/") {
+		return nil, fmt.Errorf("%w: invalid request line: %s", ErrParsingError, l)
 	}
 
-	return parts[0], parts[1], strings.TrimSpace(strings.Join(parts[2:], " ")), nil
+	uri, err := ParseURI(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid request uri: %s", ErrParsingError, err)
+	}
+
+	return &Request{
+		Method: parts[0],
+		URI:    uri,
+	}, nil
 }
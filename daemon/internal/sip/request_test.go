@@ -1,6 +1,7 @@
 package sip
 
 import (
+	"errors"
 	"strings"
 	"testing"
 
@@ -32,18 +33,46 @@ WWW-Authenticate: Digest nonce="1234", realm="test.example.com"
 	}
 }
 
-func TestParseURI(t *testing.T) {
-	uri, err := ParseURI("sip:**612@192.168.1.1:5060")
+func TestParseRequest(t *testing.T) {
+	requestData := "BYE sip:caller@192.168.1.10:5060;transport=udp SIP/2.0\r\nVia: SIP/2.0/UDP 192.168.1.1;branch=z9hG4bK1\r\nCall-ID: c1\r\nCSeq: 2 BYE\r\nContent-Length: 0\r\n\r\n"
+
+	req, err := ParseRequest(strings.NewReader(requestData))
 	if err != nil {
 		t.Fatal(err)
 	}
-	if diff := deep.Equal(uri, URI{
-		Scheme:  "sip",
-		Address: "**612",
-		Host:    "192.168.1.1",
-		Port:    5060,
-	}); diff != nil {
-		t.Error(diff)
+
+	if req.Method != "BYE" || req.URI.String() != "sip:caller@192.168.1.10:5060;transport=udp" || req.Header.Get("Call-ID") != "c1" {
+		t.Errorf("unexpected request: %s", req.DebugString())
+	}
+
+	if _, err := ParseRequest(strings.NewReader("SIP/2.0 200 OK\r\n\r\n")); !errors.Is(err, ErrParsingError) {
+		t.Errorf("expected parsing error but got %v", err)
+	}
+
+	if _, err := ParseResponse(strings.NewReader(requestData)); !errors.Is(err, ErrParsingError) {
+		t.Errorf("expected parsing error but got %v", err)
+	}
+}
+
+func TestNewResponse(t *testing.T) {
+	req := NewRequest("BYE", NewURI("sip", "caller", "localhost", 5060))
+	req.Header.Add("Via", "SIP/2.0/UDP proxy;branch=z9hG4bK2")
+	req.Header.Add("Via", "SIP/2.0/UDP phone;branch=z9hG4bK1")
+	req.Header.Set("Call-ID", "c1")
+	req.Header.Set("CSeq", "2 BYE")
+
+	var b strings.Builder
+	if err := NewResponse(req, StatusOK, "OK").Write(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := ParseResponse(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.StatusCode != StatusOK || len(res.Header["Via"]) != 2 || res.Header.Get("CSeq") != "2 BYE" || res.Header.Get("Call-ID") != "c1" {
+		t.Errorf("unexpected response: %s", b.String())
 	}
 }
 
@@ -89,7 +118,7 @@ func TestRequest_Write(t *testing.T) {
 	}
 
 	got := w.String()
-	wantLike := "INVITE sip:test@localhost:5060 SIP/2.0\r\nMax-Forwards: 70\r\nContent-Length: 0\r\n\r\n"
+	wantLike := "INVITE sip:test@localhost SIP/2.0\r\nMax-Forwards: 70\r\nContent-Length: 0\r\n\r\n"
 
 	if len(got) != len(wantLike) {
 		t.Errorf("expected '%s' to have length %d but got %d", got, len(wantLike), len(got))
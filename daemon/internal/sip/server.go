@@ -0,0 +1,565 @@
+package sip
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/halimath/raspidoor/daemon/internal/sdp"
+)
+
+const (
+	StatusForbidden         = 403
+	StatusBusyHere          = 486
+	StatusNotAcceptableHere = 488
+	StatusServerError       = 500
+
+	// allowedMethods lists the methods supported by the Server.
+	allowedMethods = "INVITE, ACK, BYE, CANCEL, INFO, OPTIONS"
+)
+
+// Server implements a user agent server (UAS) accepting incoming calls via UDP and TCP. Accepted calls are
+// answered immediately and passed to the Handler. A single call is handled at a time; further calls are
+// rejected as busy.
+type Server struct {
+	// Accept reports whether to accept the call offered by the INVITE req received from the remote address.
+	// Calls not accepted are rejected with 403 Forbidden. All calls are accepted if Accept is nil.
+	Accept func(req *Request, remote net.Addr) bool
+
+	// Handler handles accepted calls. The call is hung up once the handler returns; ctx is cancelled when the
+	// caller hangs up.
+	Handler AnswerHandler
+
+	// Tracer traces all messages sent and received; optional.
+	Tracer Tracer
+
+	lock  sync.Mutex
+	call  *serverCall
+	calls sync.WaitGroup
+}
+
+// AllowNetworks returns a function to use as Server.Accept which accepts requests sent from an IP address
+// contained in one of the given networks. The caller's From header is not considered as it is set by the
+// caller and not authenticated.
+func AllowNetworks(networks ...*net.IPNet) func(req *Request, remote net.Addr) bool {
+	return func(req *Request, remote net.Addr) bool {
+		ip := addrIP(remote)
+		if ip == nil {
+			return false
+		}
+
+		for _, n := range networks {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// ListenAndServe listens on the UDP and TCP address addr and serves incoming requests until ctx is done.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	udp, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+
+	tcp, err := net.Listen("tcp", addr)
+	if err != nil {
+		udp.Close()
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, 2)
+	go func() { errs <- s.ServeUDP(ctx, udp) }()
+	go func() { errs <- s.ServeTCP(ctx, tcp) }()
+
+	// Stop serving both transports as soon as one fails.
+	err = <-errs
+	cancel()
+	if err2 := <-errs; err == nil {
+		err = err2
+	}
+
+	return err
+}
+
+// ServeUDP serves requests received from con until ctx is done. con is closed when ServeUDP returns.
+func (s *Server) ServeUDP(ctx context.Context, con net.PacketConn) error {
+	defer con.Close()
+	defer s.calls.Wait()
+	defer watchContext(ctx, con)()
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := con.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		trace(s.Tracer, Received, "UDP", con.LocalAddr(), addr, buf[:n])
+
+		req, res, err := parseMessage(bytes.NewReader(buf[:n]))
+		if err != nil {
+			// Malformed datagrams are silently discarded (RFC 3261 section 18.1.2).
+			continue
+		}
+
+		p := &udpPeer{con: con, addr: addr, tracer: s.Tracer}
+		if req != nil {
+			s.handle(ctx, req, p)
+		} else {
+			s.response(res)
+		}
+	}
+}
+
+// ServeTCP serves requests received from connections accepted from l until ctx is done. l is closed when
+// ServeTCP returns.
+func (s *Server) ServeTCP(ctx context.Context, l net.Listener) error {
+	defer l.Close()
+
+	var cons sync.WaitGroup
+	defer cons.Wait()
+	defer s.calls.Wait()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			l.Close()
+		case <-stop:
+		}
+	}()
+
+	for {
+		con, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		cons.Add(1)
+		go func() {
+			defer cons.Done()
+			s.serveTCPConnection(ctx, con)
+		}()
+	}
+}
+
+func (s *Server) serveTCPConnection(ctx context.Context, con net.Conn) {
+	defer con.Close()
+	defer watchContext(ctx, con)()
+
+	r := bufio.NewReader(con)
+	p := &tcpPeer{con: con, tracer: s.Tracer}
+
+	for {
+		req, res, err := parseMessage(r)
+		if err != nil {
+			return
+		}
+
+		if req != nil {
+			traceMessage(s.Tracer, Received, "TCP", con.LocalAddr(), con.RemoteAddr(), req)
+			s.handle(ctx, req, p)
+		} else {
+			traceMessage(s.Tracer, Received, "TCP", con.LocalAddr(), con.RemoteAddr(), res)
+			s.response(res)
+		}
+	}
+}
+
+// handle handles req received from p and sends the response.
+func (s *Server) handle(ctx context.Context, req *Request, p peer) {
+	if res := s.respond(ctx, req, p); res != nil {
+		p.respond(res)
+	}
+}
+
+// respond handles req and returns the response to send; nil if req must not be answered.
+func (s *Server) respond(ctx context.Context, req *Request, p peer) *Response {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if req.Method == "INVITE" {
+		return s.invite(ctx, req, p)
+	}
+
+	if req.Method == "OPTIONS" {
+		res := NewResponse(req, StatusOK, "OK")
+		res.Header.Set("Allow", allowedMethods)
+		return res
+	}
+
+	c := s.call
+	if c != nil && c.callID != req.Header.Get("Call-ID") {
+		c = nil
+	}
+
+	if req.Method == "ACK" {
+		if c != nil {
+			c.ackOnce.Do(func() { close(c.acked) })
+		}
+		return nil
+	}
+
+	if c == nil {
+		return NewResponse(req, StatusCallDoesNotExist, "Call/Transaction Does Not Exist")
+	}
+
+	switch req.Method {
+	case "BYE":
+		c.byeOnce.Do(func() { close(c.bye) })
+		return NewResponse(req, StatusOK, "OK")
+	case "CANCEL":
+		// The INVITE has already been answered, so the CANCEL has no effect (RFC 3261 section 9.2).
+		return NewResponse(req, StatusOK, "OK")
+	case "INFO":
+		return receiveDTMFInfo(req, c.dtmf, &c.infoCSeq)
+	default:
+		return NewResponse(req, StatusNotImplemented, "Not Implemented")
+	}
+}
+
+// invite handles an INVITE. New calls are answered with the SDP answer and handled in the background.
+func (s *Server) invite(ctx context.Context, req *Request, p peer) *Response {
+	if c := s.call; c != nil {
+		if c.callID == req.Header.Get("Call-ID") {
+			// A retransmission or a re-INVITE which is answered with the session negotiated before.
+			return c.ok(req)
+		}
+		return NewResponse(req, StatusBusyHere, "Busy Here")
+	}
+
+	if s.Accept != nil && !s.Accept(req, p.remoteAddr()) {
+		return NewResponse(req, StatusForbidden, "Forbidden")
+	}
+
+	contentType := strings.TrimSpace(strings.Split(req.Header.Get("Content-Type"), ";")[0])
+	if !strings.EqualFold(contentType, sdp.ContentType) {
+		return NewResponse(req, StatusNotAcceptableHere, "Not Acceptable Here")
+	}
+
+	offer, err := sdp.Parse(req.Body)
+	if err != nil {
+		return NewResponse(req, StatusNotAcceptableHere, "Not Acceptable Here")
+	}
+
+	localAddr := p.localAddr()
+	localIP := addrIP(localAddr)
+
+	rtp, err := net.ListenPacket("udp", net.JoinHostPort(localIP.String(), "0"))
+	if err != nil {
+		return NewResponse(req, StatusServerError, "Server Internal Error")
+	}
+
+	answer, media, err := sdp.NewAnswer(offer, localIP, addrPort(rtp.LocalAddr()), sdp.PCMU, sdp.PCMA, sdp.TelephoneEvent)
+	if err != nil {
+		rtp.Close()
+		return NewResponse(req, StatusNotAcceptableHere, "Not Acceptable Here")
+	}
+
+	caller, _ := ParseNameAddr(req.Header.Get("From"))
+	target, err := ParseNameAddr(req.Header.Get("Contact"))
+	if err != nil {
+		target = caller
+	}
+
+	c := &serverCall{
+		peer:     p,
+		callID:   req.Header.Get("Call-ID"),
+		localTag: newTag(),
+		remote:   req.Header.Get("From"),
+		caller:   caller.URI,
+		target:   target.URI,
+		contact:  NewURI(req.URI.Scheme, req.URI.Address, localIP.String(), addrPort(localAddr)),
+		answer:   answer.Marshal(),
+		media:    media,
+		rtp:      rtp,
+		acked:    make(chan struct{}),
+		bye:      make(chan struct{}),
+		byeDone:  make(chan struct{}),
+		dtmf:     make(chan rune, dtmfBufferSize),
+	}
+	c.local = req.Header.Get("To") + ";tag=" + c.localTag
+
+	s.call = c
+	s.calls.Add(1)
+	go s.run(ctx, c, c.ok(req))
+
+	return c.ok(req)
+}
+
+// run handles the answered call c until either side hangs up. ok is the 2xx response to the INVITE which
+// is retransmitted via unreliable transports until the ACK has been received.
+func (s *Server) run(ctx context.Context, c *serverCall, ok *Response) {
+	defer s.calls.Done()
+	defer c.rtp.Close()
+	defer func() {
+		s.lock.Lock()
+		s.call = nil
+		s.lock.Unlock()
+	}()
+
+	if c.waitForAck(ctx, ok) && s.Handler != nil {
+		callCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-c.bye:
+				cancel()
+			case <-callCtx.Done():
+			}
+		}()
+
+		s.Handler(callCtx, &Call{Media: c.media, RTP: c.rtp, DTMF: c.dtmf, Peer: c.caller})
+		cancel()
+	}
+
+	select {
+	case <-c.bye:
+	default:
+		c.hangUp(ctx)
+	}
+}
+
+// response handles a response received for a request sent by the server, i.e. a BYE.
+func (s *Server) response(res *Response) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if c := s.call; c != nil && c.callID == res.Header.Get("Call-ID") && cseqMethod(res) == "BYE" && res.StatusCode > 199 {
+		c.byeDoneOnce.Do(func() { close(c.byeDone) })
+	}
+}
+
+// serverCall is a call accepted by a Server.
+type serverCall struct {
+	peer     peer
+	callID   string
+	localTag string
+	// local and remote are the values of the From and To headers used for requests sent to the caller.
+	local  string
+	remote string
+	caller URI
+	// target is the URI to send requests to the caller to.
+	target  URI
+	contact URI
+	cseq    int
+
+	answer []byte
+	media  *sdp.Negotiation
+	rtp    net.PacketConn
+
+	acked   chan struct{}
+	ackOnce sync.Once
+
+	// bye is closed when the caller hangs up; byeDone is closed when the caller answered the BYE sent by
+	// the server.
+	bye         chan struct{}
+	byeOnce     sync.Once
+	byeDone     chan struct{}
+	byeDoneOnce sync.Once
+
+	dtmf     chan rune
+	infoCSeq int
+}
+
+// ok creates the 2xx response to the INVITE req.
+func (c *serverCall) ok(req *Request) *Response {
+	res := NewResponse(req, StatusOK, "OK")
+	res.Header.Set("To", c.local)
+	res.Header.Set("Contact", fmt.Sprintf("<%s>", c.contact))
+	res.Header.Set("Allow", allowedMethods)
+	res.SetBody(sdp.ContentType, c.answer)
+	return res
+}
+
+// waitForAck waits for the ACK confirming the call. ok is retransmitted via unreliable transports following
+// RFC 3261 section 13.3.1.4. It reports whether the ACK has been received.
+func (c *serverCall) waitForAck(ctx context.Context, ok *Response) bool {
+	return c.retransmit(ctx, c.acked, func() error { return c.peer.respond(ok) })
+}
+
+// hangUp sends a BYE to the caller and waits for the response. If ctx is done, the BYE is sent without
+// waiting.
+func (c *serverCall) hangUp(ctx context.Context) {
+	c.cseq++
+
+	bye := NewRequest("BYE", c.target)
+	bye.Header.Set("From", c.local)
+	bye.Header.Set("To", c.remote)
+	bye.Header.Set("Call-ID", c.callID)
+	bye.Header.Set("CSeq", fmt.Sprintf("%d BYE", c.cseq))
+	bye.Header.Set("Max-Forwards", "70")
+
+	if err := c.peer.send(bye); err != nil || ctx.Err() != nil {
+		return
+	}
+
+	c.retransmit(ctx, c.byeDone, func() error { return c.peer.send(bye) })
+}
+
+// retransmit retransmits a message using send via unreliable transports until done is closed, ctx is done or
+// 64*T1 have elapsed. It reports whether done has been closed.
+func (c *serverCall) retransmit(ctx context.Context, done <-chan struct{}, send func() error) bool {
+	timeout := time.NewTimer(64 * DefaultT1)
+	defer timeout.Stop()
+
+	interval := DefaultT1
+	retransmit := time.NewTicker(interval)
+	defer retransmit.Stop()
+
+	for {
+		select {
+		case <-done:
+			return true
+		case <-c.bye:
+			return false
+		case <-ctx.Done():
+			return false
+		case <-timeout.C:
+			return false
+		case <-retransmit.C:
+			if c.peer.reliable() {
+				continue
+			}
+
+			if err := send(); err != nil {
+				return false
+			}
+
+			if interval < DefaultT2 {
+				interval *= 2
+				retransmit.Reset(interval)
+			}
+		}
+	}
+}
+
+// peer is the remote side of a connection served by a Server.
+type peer interface {
+	// send sends req setting its Via header.
+	send(req *Request) error
+
+	respond(res *Response) error
+
+	// localAddr returns the local address to announce to the peer.
+	localAddr() net.Addr
+
+	// remoteAddr returns the address messages are received from.
+	remoteAddr() net.Addr
+
+	// reliable reports whether messages sent to the peer must not be retransmitted.
+	reliable() bool
+}
+
+type udpPeer struct {
+	con    net.PacketConn
+	addr   net.Addr
+	tracer Tracer
+}
+
+func (p *udpPeer) send(req *Request) error {
+	setVia(req, "UDP", p.localAddr())
+	return p.write(req)
+}
+
+func (p *udpPeer) respond(res *Response) error {
+	return p.write(res)
+}
+
+// message is a request or response to send.
+type message interface {
+	Write(w io.Writer) error
+}
+
+func (p *udpPeer) write(msg message) error {
+	var buf bytes.Buffer
+	if err := msg.Write(&buf); err != nil {
+		return fmt.Errorf("%w: failed to write message: %s", ErrRoundTripFailed, err)
+	}
+
+	trace(p.tracer, Sent, "UDP", p.con.LocalAddr(), p.addr, buf.Bytes())
+
+	if _, err := p.con.WriteTo(buf.Bytes(), p.addr); err != nil {
+		return fmt.Errorf("%w: failed to write message: %s", ErrRoundTripFailed, err)
+	}
+
+	return nil
+}
+
+func (p *udpPeer) localAddr() net.Addr {
+	addr, ok := p.con.LocalAddr().(*net.UDPAddr)
+	if !ok || !addr.IP.IsUnspecified() {
+		return p.con.LocalAddr()
+	}
+
+	// The socket is bound to all interfaces; use the address the peer is reachable from.
+	ip, err := localIP(addrIP(p.addr).String())
+	if err != nil {
+		return addr
+	}
+
+	return &net.UDPAddr{IP: ip, Port: addr.Port}
+}
+
+func (p *udpPeer) remoteAddr() net.Addr { return p.addr }
+
+func (p *udpPeer) reliable() bool { return false }
+
+type tcpPeer struct {
+	con    net.Conn
+	tracer Tracer
+
+	// lock serializes writes of the request handler and the calls.
+	lock sync.Mutex
+}
+
+func (p *tcpPeer) send(req *Request) error {
+	setVia(req, "TCP", p.con.LocalAddr())
+	return p.write(req)
+}
+
+func (p *tcpPeer) respond(res *Response) error {
+	return p.write(res)
+}
+
+func (p *tcpPeer) write(msg message) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	traceMessage(p.tracer, Sent, "TCP", p.con.LocalAddr(), p.con.RemoteAddr(), msg)
+
+	if err := p.con.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return fmt.Errorf("%w: failed to set write deadline: %s", ErrRoundTripFailed, err)
+	}
+
+	if err := msg.Write(p.con); err != nil {
+		return fmt.Errorf("%w: failed to write message: %s", ErrRoundTripFailed, err)
+	}
+
+	return nil
+}
+
+func (p *tcpPeer) localAddr() net.Addr { return p.con.LocalAddr() }
+
+func (p *tcpPeer) remoteAddr() net.Addr { return p.con.RemoteAddr() }
+
+func (p *tcpPeer) reliable() bool { return true }
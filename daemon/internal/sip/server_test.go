@@ -0,0 +1,170 @@
+package sip
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/halimath/raspidoor/daemon/internal/sdp"
+)
+
+func TestServer_call(t *testing.T) {
+	con, addr := serveUDP(t, &Server{
+		Accept: AllowNetworks(mustParseCIDR(t, "127.0.0.0/8")),
+		Handler: func(ctx context.Context, call *Call) error {
+			if call.Peer.Address != "alice" {
+				return fmt.Errorf("unexpected peer: %s", call.Peer)
+			}
+
+			if call.Media.Codec != sdp.PCMA {
+				return fmt.Errorf("unexpected codec: %s", call.Media.Codec)
+			}
+
+			// Hang up once the caller sent #.
+			for {
+				select {
+				case d := <-call.DTMF:
+					if d == '#' {
+						return nil
+					}
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		},
+	})
+
+	offer := sdp.NewOffer(net.IPv4(127, 0, 0, 1), 40000, sdp.PCMA)
+	invite := fmt.Sprintf("INVITE sip:door@%s SIP/2.0\r\nVia: SIP/2.0/UDP %s;branch=z9hG4bKinvite\r\nFrom: \"Alice\" <sip:alice@example.com>;tag=a1\r\nTo: <sip:door@%s>\r\nCall-ID: c1\r\nCSeq: 1 INVITE\r\nContact: <sip:alice@%s>\r\nContent-Type: application/sdp\r\nContent-Length: %d\r\n\r\n%s",
+		addr, con.LocalAddr(), addr, con.LocalAddr(), len(offer.Marshal()), offer.Marshal())
+	con.WriteTo([]byte(invite), addr)
+
+	res := readResponse(t, con)
+	if res.StatusCode != StatusOK {
+		t.Fatalf("expected 200 but got %d", res.StatusCode)
+	}
+
+	toTag := res.Header.Param("To", "tag")
+	if toTag == "" {
+		t.Errorf("expected To tag")
+	}
+
+	answer, err := sdp.Parse(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sdp.Negotiate(offer, answer); err != nil {
+		t.Errorf("unexpected answer: %s", err)
+	}
+
+	to := res.Header.Get("To")
+	con.WriteTo([]byte(fmt.Sprintf("ACK sip:door@%s SIP/2.0\r\nVia: SIP/2.0/UDP %s;branch=z9hG4bKack\r\nFrom: <sip:alice@example.com>;tag=a1\r\nTo: %s\r\nCall-ID: c1\r\nCSeq: 1 ACK\r\nContent-Length: 0\r\n\r\n", addr, con.LocalAddr(), to)), addr)
+
+	info := "Signal=11\r\nDuration=160\r\n"
+	con.WriteTo([]byte(fmt.Sprintf("INFO sip:door@%s SIP/2.0\r\nVia: SIP/2.0/UDP %s;branch=z9hG4bKinfo\r\nFrom: <sip:alice@example.com>;tag=a1\r\nTo: %s\r\nCall-ID: c1\r\nCSeq: 2 INFO\r\nContent-Type: application/dtmf-relay\r\nContent-Length: %d\r\n\r\n%s", addr, con.LocalAddr(), to, len(info), info)), addr)
+
+	if res := readResponse(t, con); res.StatusCode != StatusOK || cseqMethod(res) != "INFO" {
+		t.Errorf("expected INFO to be answered with 200 but got %d %s", res.StatusCode, res.Header.Get("CSeq"))
+	}
+
+	// Skip retransmissions of the 200 to the INVITE.
+	var bye *Request
+	for bye == nil {
+		bye, _ = readMessage(t, con)
+	}
+
+	if bye.Method != "BYE" {
+		t.Fatalf("expected BYE but got %s", bye.Method)
+	}
+	if bye.URI.Address != "alice" || bye.Header.Param("From", "tag") != toTag || bye.Header.Get("Call-ID") != "c1" {
+		t.Errorf("unexpected BYE: %s", bye.DebugString())
+	}
+
+	var buf bytes.Buffer
+	NewResponse(bye, StatusOK, "OK").Write(&buf)
+	con.WriteTo(buf.Bytes(), addr)
+}
+
+func TestServer_rejected(t *testing.T) {
+	con, addr := serveUDP(t, &Server{
+		// The From header is set by the caller and thus not considered.
+		Accept: AllowNetworks(mustParseCIDR(t, "192.0.2.0/24")),
+	})
+
+	offer := sdp.NewOffer(net.IPv4(127, 0, 0, 1), 40000, sdp.PCMA)
+	con.WriteTo([]byte(fmt.Sprintf("INVITE sip:door@%s SIP/2.0\r\nVia: SIP/2.0/UDP %s;branch=z9hG4bKinvite\r\nFrom: <sip:alice@example.com>;tag=m1\r\nTo: <sip:door@%s>\r\nCall-ID: c1\r\nCSeq: 1 INVITE\r\nContent-Type: application/sdp\r\nContent-Length: %d\r\n\r\n%s",
+		addr, con.LocalAddr(), addr, len(offer.Marshal()), offer.Marshal())), addr)
+
+	if res := readResponse(t, con); res.StatusCode != StatusForbidden {
+		t.Errorf("expected 403 but got %d", res.StatusCode)
+	}
+
+	con.WriteTo([]byte(fmt.Sprintf("BYE sip:door@%s SIP/2.0\r\nVia: SIP/2.0/UDP %s;branch=z9hG4bKbye\r\nFrom: <sip:alice@example.com>;tag=m1\r\nTo: <sip:door@%s>\r\nCall-ID: c1\r\nCSeq: 2 BYE\r\nContent-Length: 0\r\n\r\n",
+		addr, con.LocalAddr(), addr)), addr)
+
+	if res := readResponse(t, con); res.StatusCode != StatusCallDoesNotExist {
+		t.Errorf("expected 481 but got %d", res.StatusCode)
+	}
+}
+
+func mustParseCIDR(t *testing.T, s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// serveUDP starts s on a local UDP socket and returns a client socket along with the server's address.
+func serveUDP(t *testing.T, s *Server) (net.PacketConn, net.Addr) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.ServeUDP(ctx, server)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	client, _ := listenUDP(t)
+	return client, server.LocalAddr()
+}
+
+// readMessage reads the next message from con.
+func readMessage(t *testing.T, con net.PacketConn) (*Request, *Response) {
+	con.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	buf := make([]byte, maxDatagramSize)
+	n, _, err := con.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, res, err := parseMessage(bufio.NewReader(bytes.NewReader(buf[:n])))
+	if err != nil {
+		t.Fatalf("%s: %s", err, strings.SplitN(string(buf[:n]), "\r\n", 2)[0])
+	}
+
+	return req, res
+}
+
+// readResponse reads the next response from con skipping requests.
+func readResponse(t *testing.T, con net.PacketConn) *Response {
+	for {
+		if _, res := readMessage(t, con); res != nil {
+			return res
+		}
+	}
+}
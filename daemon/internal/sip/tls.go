@@ -0,0 +1,51 @@
+package sip
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+)
+
+// TLSTransport implements a Transport sending requests via TLS over TCP as required for sips URIs.
+type TLSTransport struct {
+	// Config is the TLS configuration used to establish connections. If nil, the default configuration is
+	// used which verifies the server's certificate against the system roots.
+	Config *tls.Config
+
+	// Locator locates the servers to connect to; the zero Locator is used if nil.
+	Locator *Locator
+
+	// Tracer traces all messages sent and received; optional.
+	Tracer Tracer
+}
+
+var _ Transport = &TLSTransport{}
+
+func (t *TLSTransport) Dial(ctx context.Context, uri URI) (Connection, error) {
+	// The server's certificate is verified against the host of the URI (or the outbound proxy) rather than
+	// the located address (RFC 5922 section 7.2).
+	config := &tls.Config{}
+	if t.Config != nil {
+		config = t.Config.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = uri.Host
+		if t.Locator != nil && t.Locator.OutboundProxy != nil {
+			config.ServerName = t.Locator.OutboundProxy.Host
+		}
+	}
+
+	d := tls.Dialer{Config: config}
+	con, err := dialLocated(ctx, t.Locator, uri, "TLS", func(ctx context.Context, addr string) (net.Conn, error) {
+		return d.DialContext(ctx, "tcp", addr)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newTCPConnection(con, "TLS", t.Tracer), nil
+}
+
+func (t *TLSTransport) Send(ctx context.Context, req *Request) (Connection, error) {
+	return send(ctx, t, req)
+}
@@ -0,0 +1,162 @@
+package sip
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestTLSTransport(t *testing.T) {
+	cert, pool := selfSignedCertificate(t)
+	uri := listenTLS(t, cert)
+
+	transport := &TLSTransport{
+		Config: &tls.Config{
+			RootCAs:    pool,
+			ServerName: "localhost",
+		},
+	}
+
+	req := NewRequest("OPTIONS", uri)
+	req.Header.Set("Call-ID", "c1")
+	req.Header.Set("CSeq", "1 OPTIONS")
+
+	con, err := transport.Send(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	res, err := RecvFinal(context.Background(), con)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.StatusCode != StatusOK {
+		t.Errorf("expected 200 but got %d", res.StatusCode)
+	}
+
+	if via := res.Header.Get("Via"); via[:12] != "SIP/2.0/TLS " {
+		t.Errorf("expected TLS via header but got '%s'", via)
+	}
+}
+
+func TestTLSTransport_untrustedCertificate(t *testing.T) {
+	cert, _ := selfSignedCertificate(t)
+	uri := listenTLS(t, cert)
+
+	transport := &TLSTransport{
+		Config: &tls.Config{
+			ServerName: "localhost",
+		},
+	}
+
+	if _, err := transport.Send(context.Background(), NewRequest("OPTIONS", uri)); err == nil {
+		t.Error("expected certificate verification to fail")
+	}
+
+	transport.Config.InsecureSkipVerify = true
+
+	con, err := transport.Send(context.Background(), NewRequest("OPTIONS", uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	con.Close()
+}
+
+// listenTLS starts a TLS server answering every request with 200 OK.
+func listenTLS(t *testing.T, cert tls.Certificate) URI {
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			con, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer con.Close()
+
+				r := textproto.NewReader(bufio.NewReader(con))
+				h, err := readTestRequest(r)
+				if err != nil {
+					return
+				}
+
+				fmt.Fprintf(con, "SIP/2.0 200 OK\r\nVia: %s\r\nCall-ID: %s\r\nCSeq: %s\r\nContent-Length: 0\r\n\r\n", h.Get("Via"), h.Get("Call-ID"), h.Get("CSeq"))
+			}()
+		}
+	}()
+
+	addr := l.Addr().(*net.TCPAddr)
+	return NewURI(SchemeSIPS, "callee", addr.IP.String(), addr.Port)
+}
+
+func readTestRequest(r *textproto.Reader) (Header, error) {
+	if _, err := r.ReadLine(); err != nil {
+		return nil, err
+	}
+
+	h := Header{}
+	for {
+		l, err := r.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		if l == "" {
+			return h, nil
+		}
+		h.ParseHeader(l)
+	}
+}
+
+func selfSignedCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(parsed)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
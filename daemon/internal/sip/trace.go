@@ -0,0 +1,126 @@
+package sip
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// Sent denotes a message sent to the remote address.
+	Sent Direction = iota
+	// Received denotes a message received from the remote address.
+	Received
+)
+
+type (
+	// Direction tells whether a traced message has been sent or received.
+	Direction int
+
+	// TraceEvent describes a single message sent or received by a transport or a server.
+	TraceEvent struct {
+		Time      time.Time
+		Direction Direction
+
+		// Transport is the transport protocol, i.e. UDP, TCP or TLS.
+		Transport string
+
+		Local  net.Addr
+		Remote net.Addr
+
+		// Message contains the message as sent over the wire.
+		Message []byte
+	}
+
+	// Tracer traces the messages sent and received by transports and servers. Trace is invoked concurrently
+	// and must not retain ev.Message.
+	Tracer interface {
+		Trace(ev TraceEvent)
+	}
+
+	// DebugLogger is implemented by loggers with a debug level such as logging.Logger.
+	DebugLogger interface {
+		Debug(format string, args ...interface{})
+	}
+
+	// LogTracer traces messages by writing them to a logger at debug level.
+	LogTracer struct {
+		Logger DebugLogger
+	}
+
+	// SwitchTracer forwards all messages to a tracer that can be replaced at runtime. The zero value traces
+	// nothing.
+	SwitchTracer struct {
+		lock   sync.RWMutex
+		tracer Tracer
+	}
+)
+
+func (d Direction) String() string {
+	if d == Sent {
+		return "sent"
+	}
+	return "received"
+}
+
+func (t *LogTracer) Trace(ev TraceEvent) {
+	t.Logger.Debug("SIP %s\n%s", summary(ev), ev.Message)
+}
+
+// summary returns a single line describing ev, i.e. "UDP sent 192.168.1.10:5060 -> 192.168.1.1:5060".
+func summary(ev TraceEvent) string {
+	if ev.Direction == Sent {
+		return fmt.Sprintf("%s %s %s -> %s", ev.Transport, ev.Direction, ev.Local, ev.Remote)
+	}
+	return fmt.Sprintf("%s %s %s <- %s", ev.Transport, ev.Direction, ev.Local, ev.Remote)
+}
+
+// Set replaces the tracer messages are forwarded to and returns the previous one. Pass nil to stop tracing.
+func (s *SwitchTracer) Set(t Tracer) Tracer {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	prev := s.tracer
+	s.tracer = t
+	return prev
+}
+
+func (s *SwitchTracer) Trace(ev TraceEvent) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.tracer != nil {
+		s.tracer.Trace(ev)
+	}
+}
+
+// trace passes data to t unless t is nil.
+func trace(t Tracer, dir Direction, transport string, local, remote net.Addr, data []byte) {
+	if t == nil {
+		return
+	}
+
+	t.Trace(TraceEvent{
+		Time:      time.Now(),
+		Direction: dir,
+		Transport: transport,
+		Local:     local,
+		Remote:    remote,
+		Message:   data,
+	})
+}
+
+// traceMessage serializes msg and passes it to t unless t is nil.
+func traceMessage(t Tracer, dir Direction, transport string, local, remote net.Addr, msg message) {
+	if t == nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := msg.Write(&buf); err != nil {
+		return
+	}
+	trace(t, dir, transport, local, remote, buf.Bytes())
+}
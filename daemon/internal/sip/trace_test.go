@@ -0,0 +1,182 @@
+package sip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+var traceEvent = TraceEvent{
+	Time:      time.Date(2022, 5, 1, 10, 15, 30, 123456000, time.UTC),
+	Direction: Sent,
+	Transport: "UDP",
+	Local:     &net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: 5062},
+	Remote:    &net.UDPAddr{IP: net.IPv4(192, 168, 1, 1), Port: 5060},
+	Message:   []byte("OPTIONS sip:192.168.1.1 SIP/2.0\r\nContent-Length: 0\r\n\r\n"),
+}
+
+type debugLoggerMock struct {
+	msgs []string
+}
+
+func (l *debugLoggerMock) Debug(format string, args ...interface{}) {
+	l.msgs = append(l.msgs, fmt.Sprintf(format, args...))
+}
+
+func TestLogTracer(t *testing.T) {
+	var l debugLoggerMock
+	tr := &LogTracer{Logger: &l}
+
+	ev := traceEvent
+	ev.Direction = Received
+	tr.Trace(ev)
+
+	if diff := deep.Equal(l.msgs, []string{"SIP UDP received 192.168.1.10:5062 <- 192.168.1.1:5060\n" + string(ev.Message)}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestSwitchTracer(t *testing.T) {
+	var s SwitchTracer
+	s.Trace(traceEvent)
+
+	var l debugLoggerMock
+	if prev := s.Set(&LogTracer{Logger: &l}); prev != nil {
+		t.Errorf("expected no previous tracer but got %v", prev)
+	}
+	s.Trace(traceEvent)
+
+	if prev := s.Set(nil); prev == nil {
+		t.Errorf("expected previous tracer")
+	}
+	s.Trace(traceEvent)
+
+	if len(l.msgs) != 1 {
+		t.Errorf("expected 1 message but got %d", len(l.msgs))
+	}
+}
+
+func TestFileTracer_text(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sip.trace")
+	tr, err := NewFileTracer(path, TraceText, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr.Trace(traceEvent)
+	if err := tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tr.Trace(traceEvent)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "2022-05-01T10:15:30.123456Z UDP sent 192.168.1.10:5062 -> 192.168.1.1:5060\nOPTIONS sip:192.168.1.1 SIP/2.0\r\nContent-Length: 0\n\n"
+	if diff := deep.Equal(string(data), want); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestFileTracer_rotate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sip.trace")
+	tr, err := NewFileTracer(path, TraceText, 200, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+
+	// Each event takes 128 bytes, so every event starts a new file.
+	for i := 0; i < 4; i++ {
+		tr.Trace(traceEvent)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+
+	if diff := deep.Equal(names, []string{"sip.trace", "sip.trace.1", "sip.trace.2"}); diff != nil {
+		t.Error(diff)
+	}
+
+	data, err := os.ReadFile(path + ".2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(data), "OPTIONS") != 1 {
+		t.Errorf("expected a single message per file but got %q", data)
+	}
+}
+
+func TestFileTracer_pcapNG(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sip.pcapng")
+	tr, err := NewFileTracer(path, TracePcapNG, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ev := traceEvent
+	ev.Direction = Received
+	tr.Trace(ev)
+	tr.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var blockTypes []uint32
+	var packet []byte
+	for len(data) > 0 {
+		blockType := binary.LittleEndian.Uint32(data)
+		length := binary.LittleEndian.Uint32(data[4:])
+		if length%4 != 0 || binary.LittleEndian.Uint32(data[length-4:]) != length {
+			t.Fatalf("invalid block length: %d", length)
+		}
+
+		blockTypes = append(blockTypes, blockType)
+		if blockType == pcapNGEnhancedPacketBlock {
+			captured := binary.LittleEndian.Uint32(data[20:])
+			packet = data[28 : 28+captured]
+		}
+		data = data[length:]
+	}
+
+	if diff := deep.Equal(blockTypes, []uint32{pcapNGSectionHeaderBlock, pcapNGInterfaceDescriptionBlock, pcapNGEnhancedPacketBlock}); diff != nil {
+		t.Error(diff)
+	}
+
+	tags := []byte{
+		0, 12, 0, 4, 's', 'i', 'p', 0,
+		0, 20, 0, 4, 192, 168, 1, 1,
+		0, 21, 0, 4, 192, 168, 1, 10,
+		0, 24, 0, 4, 0, 0, 0, 3,
+		0, 25, 0, 4, 0, 0, 0x13, 0xc4,
+		0, 26, 0, 4, 0, 0, 0x13, 0xc6,
+		0, 0, 0, 0,
+	}
+
+	if !bytes.HasPrefix(packet, tags) {
+		t.Errorf("unexpected exported PDU tags: %v", packet)
+	}
+	if diff := deep.Equal(string(packet[len(tags):]), string(ev.Message)); diff != nil {
+		t.Error(diff)
+	}
+}
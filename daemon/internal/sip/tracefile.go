@@ -0,0 +1,291 @@
+package sip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// TraceText writes messages as plain text preceded by a line giving the time, transport, direction and
+	// addresses.
+	TraceText TraceFormat = iota
+
+	// TracePcapNG writes messages in pcapng format which can be opened with Wireshark. Messages are written
+	// as exported PDUs passed to the SIP dissector; the IP and transport headers are not reconstructed.
+	TracePcapNG
+)
+
+// TraceFormat defines the format of the files written by a FileTracer.
+type TraceFormat int
+
+// FileTracer traces messages by writing them to a file which is rotated once it exceeds a maximum size.
+// Errors writing the file are ignored as tracing must not affect the messages being traced.
+type FileTracer struct {
+	path     string
+	format   TraceFormat
+	maxSize  int64
+	maxFiles int
+
+	lock sync.Mutex
+	f    *os.File
+	size int64
+}
+
+var _ Tracer = &FileTracer{}
+
+// NewFileTracer creates a FileTracer appending messages to the file at path in the given format. Once the
+// file exceeds maxSize bytes it is renamed to path.1 and a new file is started; files rotated before are
+// renamed to path.2 up to path.<maxFiles> and the oldest one is removed. The file is never rotated if
+// maxSize is zero.
+func NewFileTracer(path string, format TraceFormat, maxSize int64, maxFiles int) (*FileTracer, error) {
+	if format != TraceText && format != TracePcapNG {
+		return nil, fmt.Errorf("invalid trace format: %d", format)
+	}
+
+	t := &FileTracer{
+		path:     path,
+		format:   format,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+	}
+
+	if err := t.open(); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (t *FileTracer) Trace(ev TraceEvent) {
+	var buf bytes.Buffer
+	if t.format == TracePcapNG {
+		writePcapNGPacket(&buf, ev)
+	} else {
+		fmt.Fprintf(&buf, "%s %s\n%s\n\n", ev.Time.Format("2006-01-02T15:04:05.000000Z07:00"), summary(ev), bytes.TrimRight(ev.Message, "\r\n"))
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.f == nil {
+		return
+	}
+
+	if t.maxSize > 0 && t.size > 0 && t.size+int64(buf.Len()) > t.maxSize {
+		if err := t.rotate(); err != nil {
+			return
+		}
+	}
+
+	n, _ := t.f.Write(buf.Bytes())
+	t.size += int64(n)
+}
+
+// Close closes the file. Messages traced afterwards are dropped.
+func (t *FileTracer) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.f == nil {
+		return nil
+	}
+
+	err := t.f.Close()
+	t.f = nil
+	return err
+}
+
+// open opens the file at t.path for appending. pcapng files start a new section as a file may contain
+// multiple sections.
+func (t *FileTracer) open() error {
+	f, err := os.OpenFile(t.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open trace file: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to open trace file: %w", err)
+	}
+
+	t.f = f
+	t.size = info.Size()
+
+	if t.format == TracePcapNG {
+		var buf bytes.Buffer
+		writePcapNGHeader(&buf)
+		n, err := t.f.Write(buf.Bytes())
+		t.size += int64(n)
+		if err != nil {
+			return fmt.Errorf("failed to write trace file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// rotate closes the current file, shifts the rotated files and opens a new file.
+func (t *FileTracer) rotate() error {
+	t.f.Close()
+	t.f = nil
+
+	if t.maxFiles < 1 {
+		if err := os.Remove(t.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return t.open()
+	}
+
+	if err := os.Remove(rotatedPath(t.path, t.maxFiles)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	for i := t.maxFiles - 1; i > 0; i-- {
+		if err := os.Rename(rotatedPath(t.path, i), rotatedPath(t.path, i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	if err := os.Rename(t.path, rotatedPath(t.path, 1)); err != nil {
+		return err
+	}
+
+	return t.open()
+}
+
+func rotatedPath(path string, i int) string {
+	return fmt.Sprintf("%s.%d", path, i)
+}
+
+const (
+	pcapNGSectionHeaderBlock        = 0x0A0D0D0A
+	pcapNGInterfaceDescriptionBlock = 0x00000001
+	pcapNGEnhancedPacketBlock       = 0x00000006
+	pcapNGByteOrderMagic            = 0x1A2B3C4D
+
+	// linkTypeWiresharkUpperPDU is the link type of packets containing a PDU exported by Wireshark's
+	// dissectors. Each packet starts with tags describing the PDU followed by the PDU itself.
+	linkTypeWiresharkUpperPDU = 252
+
+	exportedPDUTagEnd           = 0
+	exportedPDUTagDissectorName = 12
+	exportedPDUTagIPv4Src       = 20
+	exportedPDUTagIPv4Dst       = 21
+	exportedPDUTagIPv6Src       = 22
+	exportedPDUTagIPv6Dst       = 23
+	exportedPDUTagPortType      = 24
+	exportedPDUTagSrcPort       = 25
+	exportedPDUTagDstPort       = 26
+
+	portTypeTCP = 2
+	portTypeUDP = 3
+)
+
+// writePcapNGHeader writes a section header block followed by the description of the single interface all
+// packets are captured on. Blocks are written in little endian byte order.
+func writePcapNGHeader(buf *bytes.Buffer) {
+	var shb bytes.Buffer
+	binary.Write(&shb, binary.LittleEndian, uint32(pcapNGByteOrderMagic))
+	binary.Write(&shb, binary.LittleEndian, uint16(1)) // major version
+	binary.Write(&shb, binary.LittleEndian, uint16(0)) // minor version
+	binary.Write(&shb, binary.LittleEndian, int64(-1)) // section length not specified
+	writePcapNGBlock(buf, pcapNGSectionHeaderBlock, shb.Bytes())
+
+	var idb bytes.Buffer
+	binary.Write(&idb, binary.LittleEndian, uint16(linkTypeWiresharkUpperPDU))
+	binary.Write(&idb, binary.LittleEndian, uint16(0)) // reserved
+	binary.Write(&idb, binary.LittleEndian, uint32(0)) // no snap length
+	writePcapNGBlock(buf, pcapNGInterfaceDescriptionBlock, idb.Bytes())
+}
+
+// writePcapNGPacket writes an enhanced packet block containing ev's message as an exported PDU.
+func writePcapNGPacket(buf *bytes.Buffer, ev TraceEvent) {
+	var pdu bytes.Buffer
+	writeExportedPDUTag(&pdu, exportedPDUTagDissectorName, []byte("sip"))
+
+	src, dst := ev.Local, ev.Remote
+	if ev.Direction == Received {
+		src, dst = dst, src
+	}
+
+	srcIP, srcPort := splitAddr(src)
+	dstIP, dstPort := splitAddr(dst)
+	if srcIP != nil && dstIP != nil {
+		if srcIP.To4() != nil && dstIP.To4() != nil {
+			writeExportedPDUTag(&pdu, exportedPDUTagIPv4Src, srcIP.To4())
+			writeExportedPDUTag(&pdu, exportedPDUTagIPv4Dst, dstIP.To4())
+		} else {
+			writeExportedPDUTag(&pdu, exportedPDUTagIPv6Src, srcIP.To16())
+			writeExportedPDUTag(&pdu, exportedPDUTagIPv6Dst, dstIP.To16())
+		}
+
+		portType := uint32(portTypeTCP)
+		if ev.Transport == "UDP" {
+			portType = portTypeUDP
+		}
+		writeExportedPDUTag(&pdu, exportedPDUTagPortType, uint32Bytes(portType))
+		writeExportedPDUTag(&pdu, exportedPDUTagSrcPort, uint32Bytes(uint32(srcPort)))
+		writeExportedPDUTag(&pdu, exportedPDUTagDstPort, uint32Bytes(uint32(dstPort)))
+	}
+	writeExportedPDUTag(&pdu, exportedPDUTagEnd, nil)
+	pdu.Write(ev.Message)
+
+	ts := uint64(ev.Time.UnixNano() / int64(time.Microsecond))
+
+	var epb bytes.Buffer
+	binary.Write(&epb, binary.LittleEndian, uint32(0)) // interface id
+	binary.Write(&epb, binary.LittleEndian, uint32(ts>>32))
+	binary.Write(&epb, binary.LittleEndian, uint32(ts))
+	binary.Write(&epb, binary.LittleEndian, uint32(pdu.Len())) // captured length
+	binary.Write(&epb, binary.LittleEndian, uint32(pdu.Len())) // original length
+	epb.Write(pdu.Bytes())
+	epb.Write(make([]byte, padding(pdu.Len())))
+	writePcapNGBlock(buf, pcapNGEnhancedPacketBlock, epb.Bytes())
+}
+
+// writePcapNGBlock writes a block of the given type. body must be padded to 32 bits.
+func writePcapNGBlock(buf *bytes.Buffer, blockType uint32, body []byte) {
+	length := uint32(12 + len(body))
+	binary.Write(buf, binary.LittleEndian, blockType)
+	binary.Write(buf, binary.LittleEndian, length)
+	buf.Write(body)
+	binary.Write(buf, binary.LittleEndian, length)
+}
+
+// writeExportedPDUTag writes a tag of an exported PDU. In contrast to pcapng blocks, tags are written in
+// network byte order. Values are padded to 32 bits; the length includes the padding.
+func writeExportedPDUTag(buf *bytes.Buffer, tag uint16, value []byte) {
+	binary.Write(buf, binary.BigEndian, tag)
+	binary.Write(buf, binary.BigEndian, uint16(len(value)+padding(len(value))))
+	buf.Write(value)
+	buf.Write(make([]byte, padding(len(value))))
+}
+
+func uint32Bytes(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+// padding returns the number of bytes needed to pad n bytes to 32 bits.
+func padding(n int) int {
+	return (4 - n%4) % 4
+}
+
+// splitAddr returns the IP and port of a UDP or TCP address; nil for any other address.
+func splitAddr(addr net.Addr) (net.IP, int) {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP, a.Port
+	case *net.TCPAddr:
+		return a.IP, a.Port
+	}
+	return nil, 0
+}
@@ -1,9 +1,24 @@
 package sip
 
 import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// branchMagicCookie is the prefix of all branch parameters generated by RFC 3261 compliant clients.
+	branchMagicCookie = "z9hG4bK"
+
+	// writeTimeout bounds the time to write a single request to a connection.
+	writeTimeout = 10 * time.Second
 )
 
 var (
@@ -11,47 +26,98 @@ var (
 )
 
 type (
+	// Connection is a connection to a SIP server used to send requests and receive responses.
 	Connection interface {
 		Send(*Request) error
-		Recv() (*Response, error)
+
+		// Recv receives the next response. It returns ctx's error if ctx is done before a response has been
+		// received. Requests received from the server in the meantime are passed to the RequestHandler and
+		// answered.
+		Recv(ctx context.Context) (*Response, error)
+
+		// Handle sets the handler for requests received from the server. It must be called before Recv.
+		// Requests are answered with 501 Not Implemented if no handler is set.
+		Handle(h RequestHandler)
+
+		// LocalAddr returns the local address of the connection which is the address to announce to the
+		// server, i.e. as part of an SDP offer.
+		LocalAddr() net.Addr
+
 		Close() error
 	}
 
+	// RequestHandler handles a request received from the server and returns the response to send.
+	RequestHandler func(req *Request) *Response
+
+	// Transport establishes connections to SIP servers.
 	Transport interface {
-		Send(*Request) (Connection, error)
+		// Dial connects to the server identified by uri without sending a request. ctx bounds the time to
+		// establish the connection.
+		Dial(ctx context.Context, uri URI) (Connection, error)
+
+		// Send connects to the server identified by the request's URI and sends the request. ctx bounds the
+		// time to establish the connection.
+		Send(ctx context.Context, req *Request) (Connection, error)
 	}
 )
 
 type TCPTransport struct {
-	DumpRoundTrips bool
+	// Locator locates the servers to connect to; the zero Locator is used if nil.
+	Locator *Locator
+
+	// Tracer traces all messages sent and received; optional.
+	Tracer Tracer
 }
 
 var _ Transport = &TCPTransport{}
 
-func (t *TCPTransport) Send(req *Request) (Connection, error) {
-	addr := fmt.Sprintf("%s:%d", req.URI.Host, req.URI.Port)
-	con, err := net.Dial("tcp", addr)
+func (t *TCPTransport) Dial(ctx context.Context, uri URI) (Connection, error) {
+	var d net.Dialer
+	con, err := dialLocated(ctx, t.Locator, uri, "TCP", func(ctx context.Context, addr string) (net.Conn, error) {
+		return d.DialContext(ctx, "tcp", addr)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	c := &tcpConnection{
-		addr: addr,
-		con:  con,
-		dump: t.DumpRoundTrips,
+	return newTCPConnection(con, "TCP", t.Tracer), nil
+}
+
+func (t *TCPTransport) Send(ctx context.Context, req *Request) (Connection, error) {
+	return send(ctx, t, req)
+}
+
+// send implements Transport.Send by dialing t and sending req.
+func send(ctx context.Context, t Transport, req *Request) (Connection, error) {
+	con, err := t.Dial(ctx, req.URI)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := c.Send(req); err != nil {
+	if err := con.Send(req); err != nil {
+		con.Close()
 		return nil, err
 	}
 
-	return c, nil
+	return con, nil
 }
 
+// tcpConnection implements a Connection on top of a stream oriented net.Conn, i.e. TCP or TLS.
 type tcpConnection struct {
-	addr string
-	con  net.Conn
-	dump bool
+	con       net.Conn
+	r         *bufio.Reader
+	transport string
+	tracer    Tracer
+	handler   RequestHandler
+}
+
+func newTCPConnection(con net.Conn, transport string, tracer Tracer) *tcpConnection {
+	return &tcpConnection{
+		con:       con,
+		r:         bufio.NewReader(con),
+		transport: transport,
+		tracer:    tracer,
+	}
 }
 
 var _ Connection = &tcpConnection{}
@@ -60,10 +126,20 @@ func (c *tcpConnection) Close() error {
 	return c.con.Close()
 }
 
+func (c *tcpConnection) LocalAddr() net.Addr {
+	return c.con.LocalAddr()
+}
+
+func (c *tcpConnection) Handle(h RequestHandler) {
+	c.handler = h
+}
+
 func (c *tcpConnection) Send(req *Request) error {
-	req.Header.Set("Via", fmt.Sprintf("SIP/2.0/TCP %s;branch=1", c.addr))
-	if c.dump {
-		fmt.Println(req.DebugString())
+	setVia(req, c.transport, c.con.LocalAddr())
+	traceMessage(c.tracer, Sent, c.transport, c.con.LocalAddr(), c.con.RemoteAddr(), req)
+
+	if err := c.con.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return fmt.Errorf("%w: failed to set write deadline: %s", ErrRoundTripFailed, err)
 	}
 
 	if err := req.Write(c.con); err != nil {
@@ -73,15 +149,54 @@ func (c *tcpConnection) Send(req *Request) error {
 	return nil
 }
 
-func (c *tcpConnection) Recv() (*Response, error) {
-	res, err := ParseResponse(c.con)
-	if err != nil {
-		return nil, fmt.Errorf("%w: failed to read response: %s", ErrRoundTripFailed, err)
+// respond handles req and writes the response.
+func (c *tcpConnection) respond(req *Request) error {
+	res := handleRequest(c.handler, req)
+	if res == nil {
+		return nil
+	}
+	traceMessage(c.tracer, Sent, c.transport, c.con.LocalAddr(), c.con.RemoteAddr(), res)
+
+	if err := c.con.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return fmt.Errorf("%w: failed to set write deadline: %s", ErrRoundTripFailed, err)
+	}
+
+	if err := res.Write(c.con); err != nil {
+		return fmt.Errorf("%w: failed to write response: %s", ErrRoundTripFailed, err)
 	}
 
-	if c.dump {
-		fmt.Println(res.DebugString())
+	return nil
+}
+
+func (c *tcpConnection) Recv(ctx context.Context) (*Response, error) {
+	deadline, _ := ctx.Deadline()
+	if err := c.con.SetReadDeadline(deadline); err != nil {
+		return nil, fmt.Errorf("%w: failed to set read deadline: %s", ErrRoundTripFailed, err)
 	}
+	defer watchContext(ctx, c.con)()
+
+	var res *Response
+	for res == nil {
+		var req *Request
+		var err error
+
+		req, res, err = parseMessage(c.r)
+		if err != nil {
+			if err := contextErr(ctx); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: failed to read response: %s", ErrRoundTripFailed, err)
+		}
+
+		if req != nil {
+			traceMessage(c.tracer, Received, c.transport, c.con.LocalAddr(), c.con.RemoteAddr(), req)
+			if err := c.respond(req); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	traceMessage(c.tracer, Received, c.transport, c.con.LocalAddr(), c.con.RemoteAddr(), res)
 
 	res.LocalAddr = c.con.LocalAddr()
 	res.RemoteAddr = c.con.RemoteAddr()
@@ -89,12 +204,62 @@ func (c *tcpConnection) Recv() (*Response, error) {
 	return res, nil
 }
 
-func RecvFinal(c Connection) (*Response, error) {
+// watchContext interrupts blocking reads from con once ctx is done. The returned function stops watching and
+// must be called when the read has finished.
+func watchContext(ctx context.Context, con interface{ SetReadDeadline(time.Time) error }) func() {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		select {
+		case <-ctx.Done():
+			con.SetReadDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+
+	// Wait for the goroutine to exit so that it cannot interrupt a subsequent read.
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// handleRequest passes req to h and returns the response to send; nil if req must not be answered.
+func handleRequest(h RequestHandler, req *Request) *Response {
+	if req.Method == "ACK" {
+		return nil
+	}
+
+	if h == nil {
+		return NewResponse(req, StatusNotImplemented, "Not Implemented")
+	}
+
+	return h(req)
+}
+
+// contextErr returns ctx's error if ctx is done or its deadline has passed. The latter covers reads that time
+// out at ctx's deadline before ctx itself noticed.
+func contextErr(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+func RecvFinal(ctx context.Context, c Connection) (*Response, error) {
 	var res *Response
 	var err error
 
 	for {
-		res, err = c.Recv()
+		res, err = c.Recv(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -106,3 +271,154 @@ func RecvFinal(c Connection) (*Response, error) {
 
 	return res, nil
 }
+
+// hostPort returns the network address to dial for u. If u contains no port, the scheme's default port is
+// used.
+func hostPort(u URI) string {
+	port := u.Port
+	if port == 0 {
+		port = defaultPort(u.Scheme)
+	}
+	return net.JoinHostPort(u.Host, strconv.Itoa(port))
+}
+
+// setVia sets the Via header of req using the given transport and local address. If req already contains a
+// Via header with a branch parameter, that branch is kept (i.e. the request is sent as part of an existing
+// transaction). Otherwise a new branch is generated.
+func setVia(req *Request, transport string, localAddr net.Addr) {
+	branch := req.Header.Param("Via", "branch")
+	if branch == "" {
+		branch = newBranch()
+	}
+
+	req.Header.Set("Via", fmt.Sprintf("SIP/2.0/%s %s;branch=%s", transport, localAddr, branch))
+}
+
+// newBranch generates a new unique branch parameter starting with the RFC 3261 magic cookie.
+func newBranch() string {
+	return branchMagicCookie + randomToken(8)
+}
+
+// newTag generates a new random tag parameter used to identify From and To headers.
+func newTag() string {
+	return randomToken(4)
+}
+
+// localIP returns the local IP address used to reach host. No packets are sent to host.
+func localIP(host string) (net.IP, error) {
+	con, err := net.Dial("udp", net.JoinHostPort(host, strconv.Itoa(DefaultPort)))
+	if err != nil {
+		return nil, err
+	}
+	defer con.Close()
+
+	return con.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+// randomToken returns a hex encoded random string generated from n random bytes.
+func randomToken(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("failed to read random bytes: %s", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// transactionKey returns the key used to match responses to client transactions following RFC 3261
+// section 17.1.3: the branch parameter of the topmost Via header and the method from the CSeq header.
+func transactionKey(h Header) string {
+	var method string
+	cseq := strings.Fields(h.Get("CSeq"))
+	if len(cseq) == 2 {
+		method = cseq[1]
+	}
+
+	return h.Param("Via", "branch") + " " + method
+}
+
+// receiver receives responses from a Connection in the background so that a single response stream can be
+// consumed with different contexts (i.e. one for ringing and one for cleaning up).
+type receiver struct {
+	responses chan *Response
+	err       error
+	cancel    context.CancelFunc
+}
+
+// receive starts receiving responses from con. Responses for which intercept returns true are not passed on;
+// intercept may be nil. Call stop to stop receiving.
+func receive(con Connection, intercept func(*Response) bool) *receiver {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r := &receiver{
+		responses: make(chan *Response),
+		cancel:    cancel,
+	}
+
+	go func() {
+		for {
+			res, err := con.Recv(ctx)
+			if err != nil {
+				r.err = err
+				close(r.responses)
+				return
+			}
+
+			if intercept != nil && intercept(res) {
+				continue
+			}
+
+			select {
+			case r.responses <- res:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return r
+}
+
+func (r *receiver) stop() {
+	r.cancel()
+}
+
+// next returns the next response or ctx's error if ctx is done before a response has been received.
+func (r *receiver) next(ctx context.Context) (*Response, error) {
+	select {
+	case res, ok := <-r.responses:
+		if !ok {
+			return nil, r.err
+		}
+		return res, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// final returns the next final response to a request with the given method. Responses to other requests are
+// discarded.
+func (r *receiver) final(ctx context.Context, method string) (*Response, error) {
+	for {
+		res, err := r.next(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if m := cseqMethod(res); m != "" && m != method {
+			continue
+		}
+
+		if res.StatusCode > 199 {
+			return res, nil
+		}
+	}
+}
+
+// cseqMethod returns the method from the CSeq header of res.
+func cseqMethod(res *Response) string {
+	cseq := strings.Fields(res.Header.Get("CSeq"))
+	if len(cseq) != 2 {
+		return ""
+	}
+	return cseq[1]
+}
@@ -0,0 +1,49 @@
+package sip
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTCPTransport_recvHonoursContext(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		// Accept the connection but never answer.
+		con, err := l.Accept()
+		if err == nil {
+			defer con.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	addr := l.Addr().(*net.TCPAddr)
+	transport := &TCPTransport{}
+
+	con, err := transport.Send(context.Background(), NewRequest("OPTIONS", NewURI("sip", "callee", addr.IP.String(), addr.Port)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := con.Recv(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded but got %v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	if _, err := con.Recv(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected canceled but got %v", err)
+	}
+}
@@ -0,0 +1,319 @@
+package sip
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultT1 is the default RTT estimate as defined in RFC 3261 section 17.1.1.1.
+	DefaultT1 = 500 * time.Millisecond
+
+	// DefaultT2 is the default maximum retransmit interval for non-INVITE requests as defined in RFC 3261
+	// section 17.1.2.2.
+	DefaultT2 = 4 * time.Second
+
+	maxDatagramSize = 65535
+)
+
+var (
+	ErrTransactionTimeout = errors.New("transaction timed out")
+)
+
+// UDPTransport implements a Transport sending requests via UDP. As UDP is unreliable, requests are
+// retransmitted following the timers defined in RFC 3261 section 17.1. As unreachable servers cannot be
+// detected when dialing, requests are always sent to the most preferred address located.
+type UDPTransport struct {
+	// Locator locates the servers to send requests to; the zero Locator is used if nil.
+	Locator *Locator
+
+	// T1 is the RTT estimate; defaults to DefaultT1 if zero.
+	T1 time.Duration
+
+	// T2 is the maximum retransmit interval for non-INVITE requests; defaults to DefaultT2 if zero.
+	T2 time.Duration
+
+	// Tracer traces all messages sent and received; optional.
+	Tracer Tracer
+}
+
+var _ Transport = &UDPTransport{}
+
+func (t *UDPTransport) Dial(ctx context.Context, uri URI) (Connection, error) {
+	var d net.Dialer
+	con, err := dialLocated(ctx, t.Locator, uri, "UDP", func(ctx context.Context, addr string) (net.Conn, error) {
+		return d.DialContext(ctx, "udp", addr)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c := &udpConnection{
+		con:          con,
+		tracer:       t.Tracer,
+		t1:           t.T1,
+		t2:           t.T2,
+		transactions: make(map[string]*udpTransaction),
+	}
+
+	if c.t1 == 0 {
+		c.t1 = DefaultT1
+	}
+
+	if c.t2 == 0 {
+		c.t2 = DefaultT2
+	}
+
+	return c, nil
+}
+
+func (t *UDPTransport) Send(ctx context.Context, req *Request) (Connection, error) {
+	return send(ctx, t, req)
+}
+
+// udpTransaction captures the state of a single client transaction sent via UDP.
+type udpTransaction struct {
+	callID string
+	invite bool
+	data   []byte
+
+	// confirmed is set once a 2xx response to an INVITE has been received. The transaction is kept so that
+	// retransmissions of the 2xx response are passed on for the ACK to be resent (RFC 3261 section 13.2.2.4).
+	confirmed bool
+
+	// interval is the current retransmit interval (Timer A for INVITE, Timer E otherwise).
+	interval time.Duration
+	// retransmitAt is the point in time to retransmit the request; zero if no retransmission is pending.
+	retransmitAt time.Time
+	// timeoutAt is the point in time the transaction times out (Timer B for INVITE, Timer F otherwise);
+	// zero if the transaction does not time out.
+	timeoutAt time.Time
+}
+
+type udpConnection struct {
+	con     net.Conn
+	tracer  Tracer
+	handler RequestHandler
+
+	t1, t2 time.Duration
+
+	lock         sync.Mutex
+	transactions map[string]*udpTransaction
+}
+
+var _ Connection = &udpConnection{}
+
+func (c *udpConnection) Close() error {
+	return c.con.Close()
+}
+
+func (c *udpConnection) LocalAddr() net.Addr {
+	return c.con.LocalAddr()
+}
+
+func (c *udpConnection) Handle(h RequestHandler) {
+	c.handler = h
+}
+
+func (c *udpConnection) Send(req *Request) error {
+	setVia(req, "UDP", c.con.LocalAddr())
+
+	var buf bytes.Buffer
+	if err := req.Write(&buf); err != nil {
+		return fmt.Errorf("%w: failed to write request: %s", ErrRoundTripFailed, err)
+	}
+
+	// ACK requests do not start a transaction; they are neither retransmitted nor answered.
+	if req.Method != "ACK" {
+		now := time.Now()
+		c.lock.Lock()
+		c.transactions[transactionKey(req.Header)] = &udpTransaction{
+			callID:       req.Header.Get("Call-ID"),
+			invite:       req.Method == "INVITE",
+			data:         buf.Bytes(),
+			interval:     c.t1,
+			retransmitAt: now.Add(c.t1),
+			timeoutAt:    now.Add(64 * c.t1),
+		}
+		c.lock.Unlock()
+	}
+
+	trace(c.tracer, Sent, "UDP", c.con.LocalAddr(), c.con.RemoteAddr(), buf.Bytes())
+	if _, err := c.con.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("%w: failed to write request: %s", ErrRoundTripFailed, err)
+	}
+
+	return nil
+}
+
+func (c *udpConnection) Recv(ctx context.Context) (*Response, error) {
+	buf := make([]byte, maxDatagramSize)
+
+	defer watchContext(ctx, c.con)()
+
+	for {
+		deadline := c.nextTimer()
+		if ctxDeadline, ok := ctx.Deadline(); ok && (deadline.IsZero() || ctxDeadline.Before(deadline)) {
+			deadline = ctxDeadline
+		}
+
+		if err := c.con.SetReadDeadline(deadline); err != nil {
+			return nil, fmt.Errorf("%w: failed to set read deadline: %s", ErrRoundTripFailed, err)
+		}
+
+		n, err := c.con.Read(buf)
+		if err != nil {
+			if err := contextErr(ctx); err != nil {
+				return nil, err
+			}
+
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				if err := c.fireTimers(time.Now()); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			return nil, fmt.Errorf("%w: failed to read response: %s", ErrRoundTripFailed, err)
+		}
+
+		trace(c.tracer, Received, "UDP", c.con.LocalAddr(), c.con.RemoteAddr(), buf[:n])
+
+		req, res, err := parseMessage(bytes.NewReader(buf[:n]))
+		if err != nil {
+			// Malformed datagrams are silently discarded (RFC 3261 section 18.1.2).
+			continue
+		}
+
+		if req != nil {
+			if err := c.respond(req); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if !c.match(res) {
+			continue
+		}
+
+		res.LocalAddr = c.con.LocalAddr()
+		res.RemoteAddr = c.con.RemoteAddr()
+
+		return res, nil
+	}
+}
+
+// respond handles req and sends the response. Retransmitted requests are handled again, so handlers must be
+// idempotent.
+func (c *udpConnection) respond(req *Request) error {
+	res := handleRequest(c.handler, req)
+	if res == nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := res.Write(&buf); err != nil {
+		return fmt.Errorf("%w: failed to write response: %s", ErrRoundTripFailed, err)
+	}
+
+	trace(c.tracer, Sent, "UDP", c.con.LocalAddr(), c.con.RemoteAddr(), buf.Bytes())
+	if _, err := c.con.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("%w: failed to write response: %s", ErrRoundTripFailed, err)
+	}
+
+	return nil
+}
+
+// match matches res to a pending transaction and updates the transaction's state. It reports whether a
+// matching transaction was found.
+func (c *udpConnection) match(res *Response) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key := transactionKey(res.Header)
+	tx, ok := c.transactions[key]
+	if !ok || tx.callID != res.Header.Get("Call-ID") {
+		return false
+	}
+
+	if tx.confirmed {
+		return res.StatusCode > 199 && res.StatusCode < 300
+	}
+
+	switch {
+	case tx.invite && res.StatusCode > 199 && res.StatusCode < 300:
+		tx.confirmed = true
+		tx.retransmitAt = time.Time{}
+		tx.timeoutAt = time.Time{}
+		return true
+	case res.StatusCode > 199:
+		delete(c.transactions, key)
+		return true
+	}
+
+	if tx.invite {
+		// A provisional response stops both Timer A and Timer B.
+		tx.retransmitAt = time.Time{}
+		tx.timeoutAt = time.Time{}
+	} else {
+		// A provisional response causes Timer E to fire every T2 until Timer F fires.
+		tx.interval = c.t2
+		tx.retransmitAt = time.Now().Add(c.t2)
+	}
+
+	return true
+}
+
+// nextTimer returns the point in time when the next timer fires or the zero time if no timer is active.
+func (c *udpConnection) nextTimer() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var next time.Time
+	for _, tx := range c.transactions {
+		for _, t := range []time.Time{tx.retransmitAt, tx.timeoutAt} {
+			if !t.IsZero() && (next.IsZero() || t.Before(next)) {
+				next = t
+			}
+		}
+	}
+
+	return next
+}
+
+// fireTimers handles all timers that expired before now by either retransmitting the request or
+// terminating the transaction with ErrTransactionTimeout.
+func (c *udpConnection) fireTimers(now time.Time) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for key, tx := range c.transactions {
+		if !tx.timeoutAt.IsZero() && !now.Before(tx.timeoutAt) {
+			delete(c.transactions, key)
+			return fmt.Errorf("%w: no final response for %s", ErrTransactionTimeout, key)
+		}
+
+		if tx.retransmitAt.IsZero() || now.Before(tx.retransmitAt) {
+			continue
+		}
+
+		trace(c.tracer, Sent, "UDP", c.con.LocalAddr(), c.con.RemoteAddr(), tx.data)
+		if _, err := c.con.Write(tx.data); err != nil {
+			return fmt.Errorf("%w: failed to retransmit request: %s", ErrRoundTripFailed, err)
+		}
+
+		tx.interval *= 2
+		if !tx.invite && tx.interval > c.t2 {
+			tx.interval = c.t2
+		}
+		tx.retransmitAt = now.Add(tx.interval)
+	}
+
+	return nil
+}
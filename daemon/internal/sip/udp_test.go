@@ -0,0 +1,198 @@
+package sip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUDPTransport_retransmitsUntilFinalResponse(t *testing.T) {
+	server, uri := listenUDP(t)
+
+	go func() {
+		buf := make([]byte, maxDatagramSize)
+
+		// Drop the first transmission to force a retransmit.
+		if _, _, err := server.ReadFrom(buf); err != nil {
+			return
+		}
+
+		n, addr, err := server.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		h := requestHeader(string(buf[:n]))
+
+		// A response with a different branch must be ignored.
+		server.WriteTo([]byte(fmt.Sprintf("SIP/2.0 486 Busy Here\r\nVia: SIP/2.0/UDP %s;branch=z9hG4bKother\r\nCall-ID: %s\r\nCSeq: %s\r\nContent-Length: 0\r\n\r\n", addr, h.Get("Call-ID"), h.Get("CSeq"))), addr)
+		server.WriteTo([]byte(fmt.Sprintf("SIP/2.0 180 Ringing\r\nVia: %s\r\nCall-ID: %s\r\nCSeq: %s\r\nContent-Length: 0\r\n\r\n", h.Get("Via"), h.Get("Call-ID"), h.Get("CSeq"))), addr)
+		ok := fmt.Sprintf("SIP/2.0 200 OK\r\nVia: %s\r\nCall-ID: %s\r\nCSeq: %s\r\nContent-Length: 0\r\n\r\n", h.Get("Via"), h.Get("Call-ID"), h.Get("CSeq"))
+		server.WriteTo([]byte(ok), addr)
+		// The 200 OK is retransmitted as if the ACK got lost.
+		server.WriteTo([]byte(ok), addr)
+	}()
+
+	transport := &UDPTransport{T1: 10 * time.Millisecond}
+
+	req := NewRequest("INVITE", uri)
+	req.Header.Set("Call-ID", "c1")
+	req.Header.Set("CSeq", "1 INVITE")
+
+	con, err := transport.Send(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	res, err := con.Recv(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != 180 {
+		t.Errorf("expected 180 but got %d", res.StatusCode)
+	}
+
+	res, err = RecvFinal(context.Background(), con)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != StatusOK {
+		t.Errorf("expected 200 but got %d", res.StatusCode)
+	}
+
+	// Retransmissions of a 2xx response to an INVITE are passed on so that the ACK can be resent.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	res, err = con.Recv(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != StatusOK {
+		t.Errorf("expected retransmitted 200 but got %d", res.StatusCode)
+	}
+}
+
+func TestUDPTransport_timeout(t *testing.T) {
+	server, uri := listenUDP(t)
+
+	received := make(chan int, 1)
+	go func() {
+		buf := make([]byte, maxDatagramSize)
+		count := 0
+		for {
+			server.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+			if _, _, err := server.ReadFrom(buf); err != nil {
+				received <- count
+				return
+			}
+			count++
+		}
+	}()
+
+	transport := &UDPTransport{T1: 5 * time.Millisecond, T2: 20 * time.Millisecond}
+
+	req := NewRequest("OPTIONS", uri)
+	req.Header.Set("Call-ID", "c1")
+	req.Header.Set("CSeq", "1 OPTIONS")
+
+	con, err := transport.Send(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	_, err = con.Recv(context.Background())
+	if !errors.Is(err, ErrTransactionTimeout) {
+		t.Errorf("expected timeout but got %v", err)
+	}
+
+	// Timer F fires after 64*T1 = 320ms; Timer E fires after 5, 10, 20 and then every 20ms.
+	if count := <-received; count < 10 {
+		t.Errorf("expected at least 10 transmissions but got %d", count)
+	}
+}
+
+func TestUDPTransport_answersRequests(t *testing.T) {
+	server, uri := listenUDP(t)
+
+	answered := make(chan string, 1)
+	go func() {
+		buf := make([]byte, maxDatagramSize)
+
+		n, addr, err := server.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		h := requestHeader(string(buf[:n]))
+
+		server.WriteTo([]byte(fmt.Sprintf("BYE sip:caller@%s SIP/2.0\r\nVia: SIP/2.0/UDP %s;branch=z9hG4bKbye\r\nCall-ID: %s\r\nCSeq: 1 BYE\r\nContent-Length: 0\r\n\r\n", addr, server.LocalAddr(), h.Get("Call-ID"))), addr)
+
+		n, _, err = server.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		answered <- strings.SplitN(string(buf[:n]), "\r\n", 2)[0]
+
+		server.WriteTo([]byte(fmt.Sprintf("SIP/2.0 200 OK\r\nVia: %s\r\nCall-ID: %s\r\nCSeq: %s\r\nContent-Length: 0\r\n\r\n", h.Get("Via"), h.Get("Call-ID"), h.Get("CSeq"))), addr)
+	}()
+
+	transport := &UDPTransport{}
+
+	req := NewRequest("OPTIONS", uri)
+	req.Header.Set("Call-ID", "c1")
+	req.Header.Set("CSeq", "1 OPTIONS")
+
+	con, err := transport.Dial(context.Background(), uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	con.Handle(func(req *Request) *Response {
+		return NewResponse(req, StatusOK, "OK")
+	})
+
+	if err := con.Send(req); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := RecvFinal(context.Background(), con)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != StatusOK {
+		t.Errorf("expected 200 but got %d", res.StatusCode)
+	}
+
+	if l := <-answered; l != "SIP/2.0 200 OK" {
+		t.Errorf("expected BYE to be answered with 200 but got %s", l)
+	}
+}
+
+func listenUDP(t *testing.T) (net.PacketConn, URI) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	addr := server.LocalAddr().(*net.UDPAddr)
+
+	return server, NewURI("sip", "callee", addr.IP.String(), addr.Port)
+}
+
+func requestHeader(msg string) Header {
+	h := Header{}
+	lines := strings.Split(msg, "\r\n")
+	for _, l := range lines[1:] {
+		if l == "" {
+			break
+		}
+		h.ParseHeader(l)
+	}
+	return h
+}
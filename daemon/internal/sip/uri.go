@@ -3,19 +3,84 @@ package sip
 import (
 	"errors"
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
 )
 
+const (
+	SchemeSIP  = "sip"
+	SchemeSIPS = "sips"
+
+	DefaultPort    = 5060
+	DefaultTLSPort = 5061
+)
+
 var (
 	ErrInvaldURI = errors.New("invalid uri")
 )
 
+// Param is a single URI, URI header or header field parameter. A parameter with an empty value is a flag
+// parameter such as "lr".
+type Param struct {
+	Name  string
+	Value string
+}
+
+// Params is an ordered list of parameters.
+type Params []Param
+
+// Get returns the value of the first parameter named name. Names are compared case-insensitively. ok reports
+// whether such a parameter exists.
+func (p Params) Get(name string) (value string, ok bool) {
+	for _, param := range p {
+		if strings.EqualFold(param.Name, name) {
+			return param.Value, true
+		}
+	}
+	return "", false
+}
+
+// Has reports whether p contains a parameter named name.
+func (p Params) Has(name string) bool {
+	_, ok := p.Get(name)
+	return ok
+}
+
+// Set returns p with the value of the first parameter named name replaced by value. If p does not contain
+// such a parameter, it is appended.
+func (p Params) Set(name, value string) Params {
+	for i, param := range p {
+		if strings.EqualFold(param.Name, name) {
+			p[i].Value = value
+			return p
+		}
+	}
+	return append(p, Param{Name: name, Value: value})
+}
+
+// Del returns p with all parameters named name removed.
+func (p Params) Del(name string) Params {
+	var result Params
+	for _, param := range p {
+		if !strings.EqualFold(param.Name, name) {
+			result = append(result, param)
+		}
+	}
+	return result
+}
+
+// URI implements a SIP or SIPS URI as defined in RFC 3261 section 19.1. Address, Password and parameter
+// values are kept unescaped. Host contains IPv6 addresses without the enclosing brackets. A Port of 0
+// denotes a URI without an explicit port.
 type URI struct {
-	Scheme  string
-	Address string
-	Host    string
-	Port    int
+	Scheme   string
+	Address  string
+	Password string
+	Host     string
+	Port     int
+	Params   Params
+	Headers  Params
 }
 
 func NewURI(scheme, address, host string, port int) URI {
@@ -27,49 +92,488 @@ func NewURI(scheme, address, host string, port int) URI {
 	}
 }
 
+// ParseURI parses uri according to the SIP-URI and SIPS-URI grammar defined in RFC 3261 section 25.1.
 func ParseURI(uri string) (URI, error) {
 	uri = strings.TrimSpace(uri)
 
-	p := strings.SplitN(uri, ":", 2)
-	if len(p) != 2 {
+	i := strings.IndexByte(uri, ':')
+	if i < 0 {
 		return URI{}, fmt.Errorf("%w: missing scheme", ErrInvaldURI)
 	}
-	if p[0] != "sip" {
-		return URI{}, fmt.Errorf("%w: missing scheme: %s", ErrInvaldURI, p[0])
+
+	u := URI{
+		Scheme: strings.ToLower(uri[:i]),
+	}
+	if u.Scheme != SchemeSIP && u.Scheme != SchemeSIPS {
+		return URI{}, fmt.Errorf("%w: unsupported scheme: %s", ErrInvaldURI, uri[:i])
 	}
 
-	scheme := p[0]
+	rest := uri[i+1:]
+	var err error
 
-	p = strings.Split(p[1], "@")
-	if len(p) != 2 {
-		return URI{}, fmt.Errorf("%w: missing host", ErrInvaldURI)
+	if i := strings.IndexByte(rest, '@'); i >= 0 {
+		user, password, hasPassword := strings.Cut(rest[:i], ":")
+		rest = rest[i+1:]
+
+		if user == "" {
+			return URI{}, fmt.Errorf("%w: empty user", ErrInvaldURI)
+		}
+		if u.Address, err = unescape(user, userChars); err != nil {
+			return URI{}, fmt.Errorf("%w: invalid user: %s", ErrInvaldURI, err)
+		}
+		if hasPassword {
+			if u.Password, err = unescape(password, passwordChars); err != nil {
+				return URI{}, fmt.Errorf("%w: invalid password: %s", ErrInvaldURI, err)
+			}
+		}
 	}
 
-	address := p[0]
+	if i := strings.IndexByte(rest, '?'); i >= 0 {
+		if u.Headers, err = parseURIHeaders(rest[i+1:]); err != nil {
+			return URI{}, err
+		}
+		rest = rest[:i]
+	}
 
-	p = strings.Split(p[1], ":")
-	var host string
-	var port int64
-	var err error
-	if len(p) == 1 {
-		host = p[0]
-		port = 5060
+	if i := strings.IndexByte(rest, ';'); i >= 0 {
+		if u.Params, err = parseURIParams(rest[i+1:]); err != nil {
+			return URI{}, err
+		}
+		rest = rest[:i]
+	}
+
+	if u.Host, u.Port, err = parseHostPort(rest); err != nil {
+		return URI{}, err
+	}
+
+	return u, nil
+}
+
+// parseHostPort parses the hostport production of a URI.
+func parseHostPort(s string) (host string, port int, err error) {
+	var p string
+	var hasPort bool
+
+	if strings.HasPrefix(s, "[") {
+		i := strings.IndexByte(s, ']')
+		if i < 0 {
+			return "", 0, fmt.Errorf("%w: unterminated IPv6 reference: %s", ErrInvaldURI, s)
+		}
+		host = s[1:i]
+		if ip := net.ParseIP(host); ip == nil || !strings.Contains(host, ":") {
+			return "", 0, fmt.Errorf("%w: invalid IPv6 address: %s", ErrInvaldURI, host)
+		}
+
+		switch rest := s[i+1:]; {
+		case rest == "":
+		case rest[0] == ':':
+			p, hasPort = rest[1:], true
+		default:
+			return "", 0, fmt.Errorf("%w: invalid host: %s", ErrInvaldURI, s)
+		}
 	} else {
-		host = p[0]
-		port, err = strconv.ParseInt(p[1], 10, 32)
+		host, p, hasPort = strings.Cut(s, ":")
+		if host == "" {
+			return "", 0, fmt.Errorf("%w: missing host", ErrInvaldURI)
+		}
+		if !isHostname(host) {
+			return "", 0, fmt.Errorf("%w: invalid host: %s", ErrInvaldURI, host)
+		}
+	}
+
+	if hasPort {
+		if !isDigits(p) {
+			return "", 0, fmt.Errorf("%w: invalid port: %s", ErrInvaldURI, p)
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 || n > 65535 {
+			return "", 0, fmt.Errorf("%w: invalid port: %s", ErrInvaldURI, p)
+		}
+		port = n
+	}
+
+	return host, port, nil
+}
+
+func parseURIParams(s string) (Params, error) {
+	var params Params
+	for _, p := range strings.Split(s, ";") {
+		name, value, _ := strings.Cut(p, "=")
+		if name == "" {
+			return nil, fmt.Errorf("%w: empty parameter name", ErrInvaldURI)
+		}
+
+		param, err := unescapeParam(name, value, paramChars)
 		if err != nil {
-			return URI{}, fmt.Errorf("%w: invalid host: %s", ErrInvaldURI, err)
+			return nil, err
 		}
+		params = append(params, param)
 	}
+	return params, nil
+}
 
-	return URI{
-		Scheme:  scheme,
-		Address: address,
-		Host:    host,
-		Port:    int(port),
-	}, nil
+func parseURIHeaders(s string) (Params, error) {
+	var headers Params
+	for _, h := range strings.Split(s, "&") {
+		name, value, ok := strings.Cut(h, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%w: invalid header: %s", ErrInvaldURI, h)
+		}
+
+		header, err := unescapeParam(name, value, headerChars)
+		if err != nil {
+			return nil, err
+		}
+		headers = append(headers, header)
+	}
+	return headers, nil
+}
+
+func unescapeParam(name, value string, allowed func(byte) bool) (Param, error) {
+	var p Param
+	var err error
+	if p.Name, err = unescape(name, allowed); err != nil {
+		return Param{}, fmt.Errorf("%w: invalid parameter name: %s", ErrInvaldURI, err)
+	}
+	if p.Name == "" {
+		return Param{}, fmt.Errorf("%w: empty parameter name", ErrInvaldURI)
+	}
+	if p.Value, err = unescape(value, allowed); err != nil {
+		return Param{}, fmt.Errorf("%w: invalid parameter value: %s", ErrInvaldURI, err)
+	}
+	return p, nil
+}
+
+// Secure reports whether u uses the sips scheme and thus requires a TLS transport.
+func (u URI) Secure() bool {
+	return u.Scheme == SchemeSIPS
 }
 
 func (u URI) String() string {
-	return fmt.Sprintf("%s:%s@%s:%d", u.Scheme, u.Address, u.Host, u.Port)
+	var b strings.Builder
+
+	b.WriteString(u.Scheme)
+	b.WriteByte(':')
+
+	if u.Address != "" {
+		b.WriteString(escape(u.Address, userChars))
+		if u.Password != "" {
+			b.WriteByte(':')
+			b.WriteString(escape(u.Password, passwordChars))
+		}
+		b.WriteByte('@')
+	}
+
+	if strings.Contains(u.Host, ":") {
+		b.WriteByte('[')
+		b.WriteString(u.Host)
+		b.WriteByte(']')
+	} else {
+		b.WriteString(u.Host)
+	}
+
+	if u.Port != 0 {
+		b.WriteByte(':')
+		b.WriteString(strconv.Itoa(u.Port))
+	}
+
+	for _, p := range u.Params {
+		b.WriteByte(';')
+		b.WriteString(escape(p.Name, paramChars))
+		if p.Value != "" {
+			b.WriteByte('=')
+			b.WriteString(escape(p.Value, paramChars))
+		}
+	}
+
+	for i, h := range u.Headers {
+		if i == 0 {
+			b.WriteByte('?')
+		} else {
+			b.WriteByte('&')
+		}
+		b.WriteString(escape(h.Name, headerChars))
+		b.WriteByte('=')
+		b.WriteString(escape(h.Value, headerChars))
+	}
+
+	return b.String()
+}
+
+// NameAddr implements the name-addr and addr-spec forms used by header fields such as From, To and Contact
+// (RFC 3261 section 20.10). Params contains the header field parameters such as tag; their values are kept
+// as given, i.e. quoted strings include the quotes.
+type NameAddr struct {
+	DisplayName string
+	URI         URI
+	Params      Params
+}
+
+// ParseNameAddr parses a header field value given either as name-addr such as
+// "Alice" <sip:alice@example.com>;tag=1234 or as addr-spec such as sip:alice@example.com;tag=1234.
+func ParseNameAddr(v string) (NameAddr, error) {
+	v = strings.TrimSpace(v)
+
+	var a NameAddr
+	var uri, params string
+
+	switch {
+	case strings.HasPrefix(v, `"`):
+		display, rest, err := parseQuotedString(v)
+		if err != nil {
+			return NameAddr{}, err
+		}
+		a.DisplayName = display
+
+		rest = strings.TrimLeft(rest, " \t")
+		if !strings.HasPrefix(rest, "<") {
+			return NameAddr{}, fmt.Errorf("%w: missing addr-spec after display name: %s", ErrInvaldURI, v)
+		}
+		if uri, params, err = splitAngleAddr(rest); err != nil {
+			return NameAddr{}, err
+		}
+
+	case strings.Contains(v, "<"):
+		i := strings.IndexByte(v, '<')
+		display := strings.TrimSpace(v[:i])
+		for j := 0; j < len(display); j++ {
+			if !isTokenChar(display[j]) && display[j] != ' ' && display[j] != '\t' {
+				return NameAddr{}, fmt.Errorf("%w: invalid display name: %s", ErrInvaldURI, display)
+			}
+		}
+		a.DisplayName = display
+
+		var err error
+		if uri, params, err = splitAngleAddr(v[i:]); err != nil {
+			return NameAddr{}, err
+		}
+
+	default:
+		// An addr-spec must not contain a semicolon, comma or question mark (RFC 3261 section 20) so the
+		// first semicolon starts the header field parameters.
+		uri = v
+		if i := strings.IndexByte(v, ';'); i >= 0 {
+			uri, params = v[:i], v[i:]
+		}
+	}
+
+	var err error
+	if a.URI, err = ParseURI(uri); err != nil {
+		return NameAddr{}, err
+	}
+
+	if a.Params, err = parseHeaderParams(params); err != nil {
+		return NameAddr{}, err
+	}
+
+	return a, nil
+}
+
+func (a NameAddr) String() string {
+	var b strings.Builder
+
+	if a.DisplayName != "" {
+		b.WriteString(quote(a.DisplayName))
+		b.WriteByte(' ')
+	}
+
+	b.WriteByte('<')
+	b.WriteString(a.URI.String())
+	b.WriteByte('>')
+
+	for _, p := range a.Params {
+		b.WriteByte(';')
+		b.WriteString(p.Name)
+		if p.Value != "" {
+			b.WriteByte('=')
+			b.WriteString(p.Value)
+		}
+	}
+
+	return b.String()
+}
+
+// splitAngleAddr splits "<uri>;params" into the URI and the remaining parameters.
+func splitAngleAddr(s string) (uri, params string, err error) {
+	i := strings.IndexByte(s, '>')
+	if i < 0 {
+		return "", "", fmt.Errorf("%w: unterminated name-addr: %s", ErrInvaldURI, s)
+	}
+	return s[1:i], strings.TrimSpace(s[i+1:]), nil
+}
+
+// parseHeaderParams parses a sequence of ;name[=value] header field parameters. Values may be tokens,
+// hosts or quoted strings.
+func parseHeaderParams(s string) (Params, error) {
+	var params Params
+
+	for s != "" {
+		if s[0] != ';' {
+			return nil, fmt.Errorf("%w: invalid header parameters: %s", ErrInvaldURI, s)
+		}
+		s = strings.TrimLeft(s[1:], " \t")
+
+		i := 0
+		for i < len(s) && isTokenChar(s[i]) {
+			i++
+		}
+		if i == 0 {
+			return nil, fmt.Errorf("%w: empty parameter name", ErrInvaldURI)
+		}
+		p := Param{Name: s[:i]}
+		s = strings.TrimLeft(s[i:], " \t")
+
+		if strings.HasPrefix(s, "=") {
+			s = strings.TrimLeft(s[1:], " \t")
+
+			if strings.HasPrefix(s, `"`) {
+				_, rest, err := parseQuotedString(s)
+				if err != nil {
+					return nil, err
+				}
+				p.Value = s[:len(s)-len(rest)]
+				s = rest
+			} else {
+				i := 0
+				for i < len(s) && (isTokenChar(s[i]) || s[i] == '[' || s[i] == ']' || s[i] == ':') {
+					i++
+				}
+				if i == 0 {
+					return nil, fmt.Errorf("%w: empty value of parameter %s", ErrInvaldURI, p.Name)
+				}
+				p.Value = s[:i]
+				s = s[i:]
+			}
+			s = strings.TrimLeft(s, " \t")
+		}
+
+		params = append(params, p)
+	}
+
+	return params, nil
+}
+
+// parseQuotedString parses the quoted string at the beginning of s and returns its unquoted content and
+// the remainder of s.
+func parseQuotedString(s string) (value, rest string, err error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return b.String(), s[i+1:], nil
+		case '\\':
+			i++
+			if i == len(s) {
+				break
+			}
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", "", fmt.Errorf("%w: unterminated quoted string: %s", ErrInvaldURI, s)
+}
+
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// escape returns s with all characters not allowed escaped as %XX.
+func escape(s string, allowed func(byte) bool) string {
+	const hex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if allowed(c) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0xf])
+	}
+	return b.String()
+}
+
+// unescape decodes all %XX sequences of s. All other characters must be allowed.
+func unescape(s string, allowed func(byte) bool) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '%' {
+			if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+				return "", fmt.Errorf("invalid escape sequence in %q", s)
+			}
+			n, _ := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			b.WriteByte(byte(n))
+			i += 2
+			continue
+		}
+		if !allowed(c) {
+			return "", fmt.Errorf("invalid character %q in %q", c, s)
+		}
+		b.WriteByte(c)
+	}
+	return b.String(), nil
+}
+
+func isAlphaNum(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isHostname(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isAlphaNum(s[i]) && s[i] != '-' && s[i] != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// unreservedChars reports whether c is an unreserved character as defined in RFC 3261 section 25.1.
+func unreservedChars(c byte) bool {
+	return isAlphaNum(c) || strings.IndexByte("-_.!~*'()", c) >= 0
+}
+
+func userChars(c byte) bool {
+	return unreservedChars(c) || strings.IndexByte("&=+$,;?/", c) >= 0
+}
+
+func passwordChars(c byte) bool {
+	return unreservedChars(c) || strings.IndexByte("&=+$,", c) >= 0
+}
+
+func paramChars(c byte) bool {
+	return unreservedChars(c) || strings.IndexByte("[]/:&+$", c) >= 0
+}
+
+func headerChars(c byte) bool {
+	return unreservedChars(c) || strings.IndexByte("[]/?:+$", c) >= 0
+}
+
+func isTokenChar(c byte) bool {
+	return isAlphaNum(c) || strings.IndexByte("-.!%*_+`'~", c) >= 0
 }
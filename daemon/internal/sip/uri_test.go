@@ -0,0 +1,296 @@
+package sip
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestParseURI(t *testing.T) {
+	uri, err := ParseURI("sip:**612@192.168.1.1:5060")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(uri, URI{
+		Scheme:  "sip",
+		Address: "**612",
+		Host:    "192.168.1.1",
+		Port:    5060,
+	}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestParseURI_sips(t *testing.T) {
+	uri, err := ParseURI("sips:door@pbx.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(uri, URI{
+		Scheme:  "sips",
+		Address: "door",
+		Host:    "pbx.example.com",
+	}); diff != nil {
+		t.Error(diff)
+	}
+
+	if !uri.Secure() {
+		t.Error("expected sips uri to be secure")
+	}
+
+	if got := uri.String(); got != "sips:door@pbx.example.com" {
+		t.Errorf("unexpected string representation: %s", got)
+	}
+}
+
+func TestParseURI_unsupportedScheme(t *testing.T) {
+	if _, err := ParseURI("tel:+4930123456"); !errors.Is(err, ErrInvaldURI) {
+		t.Errorf("expected invalid uri error but got %v", err)
+	}
+}
+
+func TestParseURI_grammar(t *testing.T) {
+	tests := map[string]URI{
+		"sip:alice@example.com":       {Scheme: "sip", Address: "alice", Host: "example.com"},
+		"SIP:alice@example.com:5070":  {Scheme: "sip", Address: "alice", Host: "example.com", Port: 5070},
+		"sips:example.com":            {Scheme: "sips", Host: "example.com"},
+		"sip:door@[2001:db8::1]:5060": {Scheme: "sip", Address: "door", Host: "2001:db8::1", Port: 5060},
+		"sip:[::1]":                   {Scheme: "sip", Host: "::1"},
+		"sip:100@pbx;transport=tcp":   {Scheme: "sip", Address: "100", Host: "pbx", Params: Params{{Name: "transport", Value: "tcp"}}},
+		"sip:alice:secret@example.com;lr;maddr=[::1]?subject=hello%20world&priority=urgent": {
+			Scheme:   "sip",
+			Address:  "alice",
+			Password: "secret",
+			Host:     "example.com",
+			Params:   Params{{Name: "lr"}, {Name: "maddr", Value: "[::1]"}},
+			Headers:  Params{{Name: "subject", Value: "hello world"}, {Name: "priority", Value: "urgent"}},
+		},
+		"sip:+49-30-1234;phone-context=example.com@gateway;user=phone": {
+			Scheme:  "sip",
+			Address: "+49-30-1234;phone-context=example.com",
+			Host:    "gateway",
+			Params:  Params{{Name: "user", Value: "phone"}},
+		},
+		"sip:**1%23@192.168.1.1": {Scheme: "sip", Address: "**1#", Host: "192.168.1.1"},
+	}
+
+	for in, want := range tests {
+		got, err := ParseURI(in)
+		if err != nil {
+			t.Errorf("%s: %s", in, err)
+			continue
+		}
+		if diff := deep.Equal(got, want); diff != nil {
+			t.Errorf("%s: %v", in, diff)
+		}
+	}
+}
+
+func TestParseURI_invalid(t *testing.T) {
+	tests := []string{
+		"",
+		"alice@example.com",
+		"tel:+49301234",
+		"sip:",
+		"sip:alice@",
+		"sip:@example.com",
+		"sip:example.com:",
+		"sip:example.com:0",
+		"sip:example.com:65536",
+		"sip:example.com:50a",
+		"sip:2001:db8::1",
+		"sip:[2001:db8::1",
+		"sip:[example.com]",
+		"sip:[::1]x",
+		"sip:exa mple.com",
+		"sip:alice#1@example.com",
+		"sip:alice%2@example.com",
+		"sip:example.com;=tcp",
+		"sip:example.com;",
+		"sip:example.com?subject",
+	}
+
+	for _, in := range tests {
+		if u, err := ParseURI(in); !errors.Is(err, ErrInvaldURI) {
+			t.Errorf("%q: expected invalid uri error but got %v (%#v)", in, err, u)
+		}
+	}
+}
+
+func TestURI_String(t *testing.T) {
+	tests := map[string]URI{
+		"sip:alice@example.com":       NewURI("sip", "alice", "example.com", 0),
+		"sips:example.com:5061":       NewURI("sips", "", "example.com", 5061),
+		"sip:door@[2001:db8::1]:5060": NewURI("sip", "door", "2001:db8::1", 5060),
+		"sip:**1%23@192.168.1.1":      NewURI("sip", "**1#", "192.168.1.1", 0),
+		"sip:a%40b:p%3Aw@example.com": {Scheme: "sip", Address: "a@b", Password: "p:w", Host: "example.com"},
+		"sip:pbx;transport=tcp;lr":    {Scheme: "sip", Host: "pbx", Params: Params{{Name: "transport", Value: "tcp"}, {Name: "lr"}}},
+		"sip:pbx;x=a%3Bb?h=a%26b&s=?": {Scheme: "sip", Host: "pbx", Params: Params{{Name: "x", Value: "a;b"}}, Headers: Params{{Name: "h", Value: "a&b"}, {Name: "s", Value: "?"}}},
+	}
+
+	for want, u := range tests {
+		if got := u.String(); got != want {
+			t.Errorf("expected %s but got %s", want, got)
+		}
+	}
+}
+
+func TestParams(t *testing.T) {
+	p := Params{{Name: "transport", Value: "udp"}, {Name: "lr"}}
+
+	if v, ok := p.Get("Transport"); !ok || v != "udp" {
+		t.Errorf("expected transport=udp but got %q, %v", v, ok)
+	}
+	if !p.Has("lr") || p.Has("maddr") {
+		t.Errorf("unexpected flags: %v", p)
+	}
+
+	p = p.Set("transport", "tcp").Set("maddr", "10.0.0.1").Del("lr")
+	if diff := deep.Equal(p, Params{{Name: "transport", Value: "tcp"}, {Name: "maddr", Value: "10.0.0.1"}}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestParseNameAddr(t *testing.T) {
+	tests := map[string]NameAddr{
+		"sip:alice@example.com": {URI: NewURI("sip", "alice", "example.com", 0)},
+		"<sip:alice@example.com:5070;transport=tcp>": {
+			URI: URI{Scheme: "sip", Address: "alice", Host: "example.com", Port: 5070, Params: Params{{Name: "transport", Value: "tcp"}}},
+		},
+		`"Alice" <sip:alice@example.com>;tag=1234`: {
+			DisplayName: "Alice",
+			URI:         NewURI("sip", "alice", "example.com", 0),
+			Params:      Params{{Name: "tag", Value: "1234"}},
+		},
+		"sip:alice@example.com;tag=1234": {
+			URI:    NewURI("sip", "alice", "example.com", 0),
+			Params: Params{{Name: "tag", Value: "1234"}},
+		},
+		"Front Door <sips:door@pbx?subject=hello>;tag=123 ; expires=60": {
+			DisplayName: "Front Door",
+			URI:         URI{Scheme: "sips", Address: "door", Host: "pbx", Headers: Params{{Name: "subject", Value: "hello"}}},
+			Params:      Params{{Name: "tag", Value: "123"}, {Name: "expires", Value: "60"}},
+		},
+		`"Front \"Door\"" <sip:door@[2001:db8::1]>;received=[2001:db8::2];note="a;b"`: {
+			DisplayName: `Front "Door"`,
+			URI:         NewURI("sip", "door", "2001:db8::1", 0),
+			Params:      Params{{Name: "received", Value: "[2001:db8::2]"}, {Name: "note", Value: `"a;b"`}},
+		},
+		"<sip:pbx;lr>;ob": {
+			URI:    URI{Scheme: "sip", Host: "pbx", Params: Params{{Name: "lr"}}},
+			Params: Params{{Name: "ob"}},
+		},
+	}
+
+	for in, want := range tests {
+		got, err := ParseNameAddr(in)
+		if err != nil {
+			t.Errorf("%s: %s", in, err)
+			continue
+		}
+		if diff := deep.Equal(got, want); diff != nil {
+			t.Errorf("%s: %v", in, diff)
+		}
+	}
+}
+
+func TestParseNameAddr_invalid(t *testing.T) {
+	tests := []string{
+		"",
+		"Alice",
+		`"Alice <sip:alice@example.com>`,
+		`"Alice" sip:alice@example.com`,
+		"Al\"ice <sip:alice@example.com>",
+		"<sip:alice@example.com",
+		"<sip:alice@example.com>tag=1",
+		"<sip:alice@example.com>;=1",
+		"<sip:alice@example.com>;tag=",
+	}
+
+	for _, in := range tests {
+		if a, err := ParseNameAddr(in); !errors.Is(err, ErrInvaldURI) {
+			t.Errorf("%q: expected invalid uri error but got %v (%#v)", in, err, a)
+		}
+	}
+}
+
+func TestNameAddr_String(t *testing.T) {
+	a := NameAddr{
+		DisplayName: `Front "Door"`,
+		URI:         URI{Scheme: "sip", Address: "door", Host: "pbx", Params: Params{{Name: "transport", Value: "tcp"}}},
+		Params:      Params{{Name: "tag", Value: "1234"}},
+	}
+
+	if got, want := a.String(), `"Front \"Door\"" <sip:door@pbx;transport=tcp>;tag=1234`; got != want {
+		t.Errorf("expected %s but got %s", want, got)
+	}
+
+	a = NameAddr{URI: NewURI("sip", "door", "pbx", 0)}
+	if got, want := a.String(), "<sip:door@pbx>"; got != want {
+		t.Errorf("expected %s but got %s", want, got)
+	}
+}
+
+func FuzzParseURI(f *testing.F) {
+	for _, s := range []string{
+		"sip:alice@example.com",
+		"sips:example.com:5061",
+		"sip:door@[2001:db8::1]:5060",
+		"sip:100@pbx;transport=tcp",
+		"sip:alice:secret@example.com;lr;maddr=[::1]?subject=hello%20world&priority=urgent",
+		"sip:+49-30-1234;phone-context=example.com@gateway;user=phone",
+	} {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		u, err := ParseURI(s)
+		if err != nil {
+			return
+		}
+
+		str := u.String()
+		got, err := ParseURI(str)
+		if err != nil {
+			t.Fatalf("%q: failed to parse serialized uri %q: %s", s, str, err)
+		}
+		if diff := deep.Equal(got, u); diff != nil {
+			t.Fatalf("%q: round trip via %q changed uri: %v", s, str, diff)
+		}
+		if got.String() != str {
+			t.Fatalf("%q: serialization is not stable: %q != %q", s, got.String(), str)
+		}
+	})
+}
+
+func FuzzParseNameAddr(f *testing.F) {
+	for _, s := range []string{
+		"sip:alice@example.com;tag=1234",
+		`"Alice" <sip:alice@example.com>;tag=1234`,
+		"Front Door <sips:door@pbx?subject=hello>;tag=123 ; expires=60",
+		`"Front \"Door\"" <sip:door@[2001:db8::1]>;received=[2001:db8::2];note="a;b"`,
+		"<sip:pbx;lr>;ob",
+	} {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		a, err := ParseNameAddr(s)
+		if err != nil {
+			return
+		}
+
+		str := a.String()
+		got, err := ParseNameAddr(str)
+		if err != nil {
+			t.Fatalf("%q: failed to parse serialized name-addr %q: %s", s, str, err)
+		}
+		if diff := deep.Equal(got, a); diff != nil {
+			t.Fatalf("%q: round trip via %q changed name-addr: %v", s, str, diff)
+		}
+		if got.String() != str {
+			t.Fatalf("%q: serialization is not stable: %q != %q", s, got.String(), str)
+		}
+	})
+}
@@ -77,7 +77,7 @@ func doMain() error {
 		logger.Err(err)
 	}
 
-	signalChan := make(chan os.Signal)
+	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT)
 	<-signalChan
 
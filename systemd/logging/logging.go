@@ -9,6 +9,7 @@ import (
 )
 
 type Logger interface {
+	Debug(string, ...interface{})
 	Info(string, ...interface{})
 	Warn(string, ...interface{})
 	Error(string, ...interface{})
@@ -31,6 +32,7 @@ func (l *writerLogger) log(level string, format string, args ...interface{}) {
 	fmt.Fprintf(l.w, "%s [%s] %s\n", time.Now().Format(time.RFC3339), level, msg)
 }
 
+func (l *writerLogger) Debug(format string, args ...interface{}) { l.log("DEBUG", format, args...) }
 func (l *writerLogger) Info(format string, args ...interface{})  { l.log("INFO", format, args...) }
 func (l *writerLogger) Warn(format string, args ...interface{})  { l.log("WARN", format, args...) }
 func (l *writerLogger) Error(format string, args ...interface{}) { l.log("ERROR", format, args...) }
@@ -59,6 +61,9 @@ type syslogLogger struct {
 
 var _ Logger = &syslogLogger{}
 
+func (l *syslogLogger) Debug(format string, args ...interface{}) {
+	l.w.Debug(fmt.Sprintf(format, args...))
+}
 func (l *syslogLogger) Info(format string, args ...interface{}) {
 	l.w.Info(fmt.Sprintf(format, args...))
 }
@@ -75,3 +80,14 @@ func (l *syslogLogger) Err(err error) {
 func (l *syslogLogger) Close() error {
 	return l.w.Close()
 }
+
+// DiscardDebug returns a Logger that forwards all messages to l except debug messages, which are dropped.
+func DiscardDebug(l Logger) Logger {
+	return &discardDebugLogger{l}
+}
+
+type discardDebugLogger struct {
+	Logger
+}
+
+func (l *discardDebugLogger) Debug(string, ...interface{}) {}
@@ -25,10 +25,21 @@ type (
 )
 
 func (c Config) NewLogger() (logging.Logger, error) {
+	var l logging.Logger
 	if c.Logging.Target == "syslog" {
-		return logging.Syslog("raspidoorwebapp")
+		var err error
+		l, err = logging.Syslog("raspidoorwebapp")
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		l = logging.Stdout()
 	}
-	return logging.Stdout(), nil
+
+	if !c.Logging.Debug {
+		l = logging.DiscardDebug(l)
+	}
+	return l, nil
 }
 
 func ReadConfig() (*Config, error) {
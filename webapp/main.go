@@ -14,6 +14,7 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -21,6 +22,8 @@ import (
 	"github.com/halimath/raspidoor/systemd/notify"
 	"github.com/halimath/raspidoor/webapp/internal/config"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var (
@@ -39,8 +42,46 @@ var (
 	indexTemplate *template.Template
 )
 
+// indexModel is the data rendered by the index template.
+type indexModel struct {
+	*controller.StateInfo
+
+	Messages []messageModel
+
+	// Calls contains the most recent calls placed by the phone bells, most recent first.
+	Calls []callModel
+
+	// Health contains the result of the SIP server's health probe; nil if it is not probed.
+	Health *controller.HealthState
+}
+
+type callModel struct {
+	Time     time.Time
+	Bell     string
+	BellPush string
+	Result   string
+	Callee   string
+	Error    string
+}
+
+// maxCalls is the number of calls shown on the index page.
+const maxCalls = 10
+
+type messageModel struct {
+	ID         string
+	RecordedAt time.Time
+	Duration   time.Duration
+}
+
 func init() {
-	indexTemplate = template.Must(template.New("index").Parse(indexTemplateString))
+	indexTemplate = template.Must(template.New("index").Funcs(template.FuncMap{
+		"remaining": remaining,
+	}).Parse(indexTemplateString))
+}
+
+// remaining returns the time left until a temporary state change of i is reverted.
+func remaining(i *controller.ItemState) time.Duration {
+	return (time.Duration(i.RemainingMillis) * time.Millisecond).Round(time.Second)
 }
 
 func main() {
@@ -98,10 +139,97 @@ func main() {
 			return
 		}
 
+		model := indexModel{StateInfo: info}
+
+		health, err := ctrl.Health(r.Context(), &controller.Empty{})
+		if err != nil {
+			logger.Error("Failed to load health: %s", err)
+		} else if health.Probing {
+			model.Health = health
+		}
+
+		history, err := ctrl.History(r.Context(), &controller.Empty{})
+		if err != nil {
+			logger.Error("Failed to load history: %s", err)
+		} else {
+			for i, c := range history.Calls {
+				if i == maxCalls {
+					break
+				}
+				model.Calls = append(model.Calls, callModel{
+					Time:     time.Unix(c.Time, 0),
+					Bell:     c.Bell,
+					BellPush: c.BellPush,
+					Result:   c.Result,
+					Callee:   c.Callee,
+					Error:    c.Error,
+				})
+			}
+		}
+
+		messages, err := ctrl.ListMessages(r.Context(), &controller.Empty{})
+		if err != nil {
+			logger.Error("Failed to list messages: %s", err)
+		} else {
+			for _, m := range messages.Messages {
+				model.Messages = append(model.Messages, messageModel{
+					ID:         m.Id,
+					RecordedAt: time.Unix(m.RecordedAt, 0),
+					Duration:   time.Duration(m.DurationMillis) * time.Millisecond,
+				})
+			}
+		}
+
 		w.Header().Set("Content-Type", "text/html")
 		w.WriteHeader(http.StatusOK)
 
-		indexTemplate.Execute(w, info)
+		indexTemplate.Execute(w, model)
+	})
+
+	mux.HandleFunc("/messages/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/messages/"), ".wav")
+
+		msg, err := ctrl.FetchMessage(r.Context(), &controller.MessageID{Id: id})
+		if status.Code(err) == codes.NotFound {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to fetch message %s: %s", id, err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "audio/wav")
+		w.Header().Set("Content-Length", strconv.Itoa(len(msg.Wav)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(msg.Wav)
+	})
+
+	mux.HandleFunc("/messages/delete", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			logger.Err(err)
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		res, err := ctrl.DeleteMessage(r.Context(), &controller.MessageID{Id: r.PostForm.Get("id")})
+		if err != nil {
+			logger.Err(err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !res.Ok {
+			logger.Error("Failed to delete message: %s", res.Error)
+		}
+
+		http.Redirect(w, r, "/", http.StatusSeeOther)
 	})
 
 	mux.HandleFunc("/update", func(w http.ResponseWriter, r *http.Request) {
@@ -139,6 +267,16 @@ func main() {
 			Index: int32(index),
 		}
 
+		if d := form.Get("duration"); d != "" {
+			duration, err := time.ParseDuration(d)
+			if err != nil {
+				logger.Err(err)
+				http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+				return
+			}
+			req.DurationMillis = duration.Milliseconds()
+		}
+
 		if target == "bell" {
 			req.Target = controller.Target_BELL
 		} else {
@@ -171,7 +309,7 @@ func main() {
 		logger.Err(err)
 	}
 
-	signalChan := make(chan os.Signal)
+	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT)
 
 	select {